@@ -0,0 +1,390 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/eliaseffects/qr-cli/internal/qr"
+)
+
+// decodeOne decodes the single code in the image at path.
+func decodeOne(t *testing.T, path string) string {
+	t.Helper()
+	texts, err := qr.DecodeFile(path)
+	if err != nil {
+		t.Fatalf("decode %s: %v", path, err)
+	}
+	if len(texts) != 1 {
+		t.Fatalf("decode %s: got %d codes, want 1", path, len(texts))
+	}
+	return texts[0]
+}
+
+type manifestRow struct {
+	Index   int    `json:"index"`
+	Line    int    `json:"line"`
+	Part    int    `json:"part"`
+	Payload string `json:"payload"`
+	Path    string `json:"path"`
+	Format  string `json:"format"`
+	Version string `json:"version"`
+	Level   string `json:"level"`
+	Bytes   int    `json:"bytes"`
+}
+
+func readManifest(t *testing.T, path string) []manifestRow {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rows []manifestRow
+	if err := json.Unmarshal(data, &rows); err != nil {
+		t.Fatalf("manifest is not a JSON array: %v\n%s", err, data)
+	}
+	return rows
+}
+
+func TestBatchResume(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "in.txt", "alpha\nbeta\ngamma\n")
+	out := filepath.Join(dir, "out")
+
+	if msg, code := runCLI(t, dir, "batch", "-f", "in.txt", "-d", "out", "--resume"); code != 0 {
+		t.Fatalf("first run: exit %d\n%s", code, msg)
+	}
+	if _, err := os.Stat(filepath.Join(out, ".qr-state.jsonl")); err != nil {
+		t.Fatalf("state file: %v", err)
+	}
+
+	msg, code := runCLI(t, dir, "batch", "-f", "in.txt", "-d", "out", "--resume")
+	if code != 0 || !strings.Contains(msg, "3 up to date, 0 rendered") {
+		t.Fatalf("unchanged run: exit %d\n%s", code, msg)
+	}
+
+	// A changed row is rendered again, and the manifest agrees with it.
+	writeFile(t, dir, "in.txt", "CHANGED\nbeta\ngamma\n")
+	msg, code = runCLI(t, dir, "batch", "-f", "in.txt", "-d", "out", "--resume", "--manifest", "m.json")
+	if code != 0 || !strings.Contains(msg, "2 up to date, 1 rendered") {
+		t.Fatalf("changed row: exit %d\n%s", code, msg)
+	}
+	if got := decodeOne(t, filepath.Join(out, "qr-001.png")); got != "CHANGED" {
+		t.Fatalf("qr-001.png decodes as %q, want CHANGED", got)
+	}
+	for i, row := range readManifest(t, filepath.Join(dir, "m.json")) {
+		if got := decodeOne(t, filepath.Join(dir, row.Path)); got != row.Payload {
+			t.Errorf("row %d: manifest payload %q, file decodes as %q", i+1, row.Payload, got)
+		}
+		if row.Version == "" || row.Level == "" || row.Bytes == 0 {
+			t.Errorf("row %d: incomplete manifest entry %+v", i+1, row)
+		}
+	}
+
+	// A damaged file is rendered again.
+	if err := os.Truncate(filepath.Join(out, "qr-002.png"), 10); err != nil {
+		t.Fatal(err)
+	}
+	msg, code = runCLI(t, dir, "batch", "-f", "in.txt", "-d", "out", "--resume")
+	if code != 0 || !strings.Contains(msg, "2 up to date, 1 rendered") {
+		t.Fatalf("damaged file: exit %d\n%s", code, msg)
+	}
+	if got := decodeOne(t, filepath.Join(out, "qr-002.png")); got != "beta" {
+		t.Fatalf("qr-002.png decodes as %q, want beta", got)
+	}
+}
+
+func TestBatchIncremental(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "in.txt", "alpha\nbeta\ngamma\n")
+	run := func(args ...string) string {
+		t.Helper()
+		msg, code := runCLI(t, dir, append([]string{"batch", "-f", "in.txt", "-d", "out", "--incremental"}, args...)...)
+		if code != 0 {
+			t.Fatalf("%v: exit %d\n%s", args, code, msg)
+		}
+		return msg
+	}
+
+	run("-s", "200")
+	if msg := run("-s", "200"); !strings.Contains(msg, "3 up to date, 0 rendered") {
+		t.Fatalf("unchanged options rendered again:\n%s", msg)
+	}
+	if msg := run("-s", "300"); strings.Contains(msg, "up to date") {
+		t.Fatalf("changed options skipped files:\n%s", msg)
+	}
+	for _, name := range []string{"qr-001.png", "qr-002.png", "qr-003.png"} {
+		file, err := os.Open(filepath.Join(dir, "out", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		config, _, err := image.DecodeConfig(file)
+		file.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if config.Width != 300 {
+			t.Errorf("%s is %dpx wide after --size 300", name, config.Width)
+		}
+	}
+
+	writeFile(t, dir, "in.txt", "alpha\nbeta\ndelta\n")
+	if msg := run("-s", "300"); !strings.Contains(msg, "2 up to date, 1 rendered") {
+		t.Fatalf("changed row:\n%s", msg)
+	}
+	if got := decodeOne(t, filepath.Join(dir, "out", "qr-003.png")); got != "delta" {
+		t.Fatalf("qr-003.png decodes as %q, want delta", got)
+	}
+}
+
+func TestBatchStateTornLine(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "in.txt", "alpha\nbeta\ngamma\n")
+	if msg, code := runCLI(t, dir, "batch", "-f", "in.txt", "-d", "out", "--resume", "-j", "1"); code != 0 {
+		t.Fatalf("first run: exit %d\n%s", code, msg)
+	}
+
+	// Cut the last entry off mid-line, as a crash while appending would.
+	statePath := filepath.Join(dir, "out", ".qr-state.jsonl")
+	data, err := os.ReadFile(statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := bytes.SplitAfter(bytes.TrimSpace(data), []byte("\n"))
+	last := lines[len(lines)-1]
+	torn := append(bytes.Join(lines[:len(lines)-1], nil), last[:len(last)/2]...)
+	if err := os.WriteFile(statePath, torn, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	msg, code := runCLI(t, dir, "batch", "-f", "in.txt", "-d", "out", "--resume")
+	if code != 0 || !strings.Contains(msg, "2 up to date, 1 rendered") {
+		t.Fatalf("after torn line: exit %d\n%s", code, msg)
+	}
+	data, err = os.ReadFile(statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for scanner := bufio.NewScanner(bytes.NewReader(data)); scanner.Scan(); n++ {
+		var entry map[string]any
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("state line %d: %v\n%s", n+1, err, data)
+		}
+	}
+	if n != 3 {
+		t.Fatalf("state has %d entries, want 3\n%s", n, data)
+	}
+}
+
+func TestBatchWithoutStateFlags(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "in.txt", "alpha\n")
+	if msg, code := runCLI(t, dir, "batch", "-f", "in.txt", "-d", "out"); code != 0 {
+		t.Fatalf("exit %d\n%s", code, msg)
+	}
+	entries, err := os.ReadDir(filepath.Join(dir, "out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".") {
+			t.Errorf("plain batch run left %s in --dir", e.Name())
+		}
+	}
+}
+
+func TestBatchMalformedCSVRow(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "in.csv", "data,id\na,1\nb\" x,2\nc,3\n")
+
+	msg, code := runCLI(t, dir, "batch", "--csv", "in.csv", "-d", "out", "--continue-on-error")
+	if code != 3 {
+		t.Fatalf("exit %d, want 3\n%s", code, msg)
+	}
+	report, err := os.ReadFile(filepath.Join(dir, "out", "qr-errors.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "index,line,reason\n2,3,\"column 2: bare \"\" in non-quoted-field\"\n"
+	if string(report) != want {
+		t.Fatalf("errors report:\n%s\nwant:\n%s", report, want)
+	}
+	for name, want := range map[string]string{"qr-001.png": "a", "qr-003.png": "c"} {
+		if got := decodeOne(t, filepath.Join(dir, "out", name)); got != want {
+			t.Errorf("%s decodes as %q, want %q", name, got, want)
+		}
+	}
+
+	msg, code = runCLI(t, dir, "batch", "--csv", "in.csv", "-d", "out2")
+	if code != 1 || !strings.Contains(msg, "line 3: column 2:") {
+		t.Fatalf("without --continue-on-error: exit %d\n%s", code, msg)
+	}
+}
+
+func TestBatchContinueOnError(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "in.jsonl", strings.Join([]string{
+		`{"data":"ok"}`,
+		`{bad`,
+		`{"data":"` + strings.Repeat("x", 8000) + `"}`,
+		`{"id":1}`,
+		`{"data":"fine"}`,
+	}, "\n")+"\n")
+
+	msg, code := runCLI(t, dir, "batch", "--jsonl", "in.jsonl", "-d", "out", "--continue-on-error", "--errors-file", "report.csv")
+	if code != 3 {
+		t.Fatalf("exit %d, want 3\n%s", code, msg)
+	}
+	if !strings.Contains(msg, "3 of 5 rows failed, 2 succeeded; see report.csv") {
+		t.Fatalf("missing summary:\n%s", msg)
+	}
+	report, err := os.ReadFile(filepath.Join(dir, "report.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Join([]string{
+		"index,line,reason",
+		"2,2,invalid character 'b' looking for beginning of object key string",
+		"3,3,data too long for a QR code at level M",
+		"4,4,no data column; add one or give --template",
+	}, "\n") + "\n"
+	if string(report) != want {
+		t.Fatalf("errors report:\n%s\nwant:\n%s", report, want)
+	}
+	for name, want := range map[string]string{"qr-001.png": "ok", "qr-005.png": "fine"} {
+		if got := decodeOne(t, filepath.Join(dir, "out", name)); got != want {
+			t.Errorf("%s decodes as %q, want %q", name, got, want)
+		}
+	}
+
+	// Without the flag the first failure stops the run with status 1.
+	if msg, code := runCLI(t, dir, "batch", "--jsonl", "in.jsonl", "-d", "out2"); code != 1 {
+		t.Fatalf("without --continue-on-error: exit %d\n%s", code, msg)
+	}
+
+	// A clean run rewrites the report with no rows.
+	writeFile(t, dir, "in.jsonl", `{"data":"ok"}`+"\n")
+	if msg, code := runCLI(t, dir, "batch", "--jsonl", "in.jsonl", "-d", "out", "--continue-on-error", "--errors-file", "report.csv"); code != 0 {
+		t.Fatalf("clean run: exit %d\n%s", code, msg)
+	}
+	if report, _ := os.ReadFile(filepath.Join(dir, "report.csv")); string(report) != "index,line,reason\n" {
+		t.Fatalf("report after clean run:\n%s", report)
+	}
+}
+
+func TestBatchManifest(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "people.csv", "id,last\n7,Lovelace\n9,Hopper\n")
+
+	msg, code := runCLI(t, dir, "batch", "--csv", "people.csv", "-d", "out",
+		"--template", "https://ex.com/u/{{.id}}", "--name", "{{.last}}", "--manifest", "m.json")
+	if code != 0 {
+		t.Fatalf("exit %d\n%s", code, msg)
+	}
+	rows := readManifest(t, filepath.Join(dir, "m.json"))
+	want := []struct{ payload, path string }{
+		{"https://ex.com/u/7", filepath.Join("out", "Lovelace.png")},
+		{"https://ex.com/u/9", filepath.Join("out", "Hopper.png")},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("manifest has %d rows, want %d", len(rows), len(want))
+	}
+	for i, row := range rows {
+		if row.Index != i+1 || row.Line != i+2 || row.Payload != want[i].payload || row.Path != want[i].path || row.Format != "png" {
+			t.Errorf("row %d = %+v", i+1, row)
+		}
+		info, err := os.Stat(filepath.Join(dir, row.Path))
+		if err != nil || info.Size() != int64(row.Bytes) {
+			t.Errorf("row %d: %s is not %d bytes (%v)", i+1, row.Path, row.Bytes, err)
+		}
+		if got := decodeOne(t, filepath.Join(dir, row.Path)); got != row.Payload {
+			t.Errorf("row %d: file decodes as %q", i+1, got)
+		}
+	}
+
+	if msg, code := runCLI(t, dir, "batch", "--csv", "people.csv", "-d", "out",
+		"--template", "https://ex.com/u/{{.id}}", "--manifest", "m.csv", "--redact"); code != 0 {
+		t.Fatalf("redacted run: exit %d\n%s", code, msg)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "m.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 3 || lines[0] != "index,line,part,payload,path,format,version,level,bytes" {
+		t.Fatalf("csv manifest:\n%s", data)
+	}
+	if strings.Contains(string(data), "ex.com") || !strings.Contains(lines[1], ",sha256:") {
+		t.Fatalf("csv manifest is not redacted:\n%s", data)
+	}
+
+	if msg, code := runCLIEnv(t, dir, []string{"QR_BATCH_REDACT_KEY=secret"}, "batch", "--csv", "people.csv", "-d", "out",
+		"--template", "https://ex.com/u/{{.id}}", "--manifest", "m.csv"); code != 0 {
+		t.Fatalf("keyed run: exit %d\n%s", code, msg)
+	}
+	if data, err = os.ReadFile(filepath.Join(dir, "m.csv")); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "ex.com") || !strings.Contains(string(data), ",hmac-sha256:") {
+		t.Fatalf("csv manifest is not keyed:\n%s", data)
+	}
+}
+
+func TestBatchJobsDeterministic(t *testing.T) {
+	dir := t.TempDir()
+	var lines []string
+	for i := 1; i <= 24; i++ {
+		lines = append(lines, fmt.Sprintf("https://example.com/item/%d", i))
+	}
+	writeFile(t, dir, "in.txt", strings.Join(lines, "\n")+"\n")
+	logo, err := filepath.Abs(filepath.Join("testdata", "logo.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, jobs := range []string{"1", "8"} {
+		msg, code := runCLI(t, dir, "batch", "-f", "in.txt", "-d", "out-"+jobs, "--logo", logo,
+			"--jobs", jobs, "--manifest", "m-"+jobs+".json")
+		if code != 0 {
+			t.Fatalf("--jobs %s: exit %d\n%s", jobs, code, msg)
+		}
+	}
+
+	serial := readManifest(t, filepath.Join(dir, "m-1.json"))
+	parallel := readManifest(t, filepath.Join(dir, "m-8.json"))
+	if len(serial) != len(lines) || len(parallel) != len(lines) {
+		t.Fatalf("manifests have %d and %d rows, want %d", len(serial), len(parallel), len(lines))
+	}
+	for i := range serial {
+		s, p := serial[i], parallel[i]
+		if filepath.Base(s.Path) != filepath.Base(p.Path) || s.Payload != p.Payload || s.Index != p.Index ||
+			s.Version != p.Version || s.Level != p.Level || s.Bytes != p.Bytes {
+			t.Errorf("row %d differs: --jobs 1 %+v, --jobs 8 %+v", i+1, s, p)
+		}
+		if want := fmt.Sprintf("qr-%03d.png", i+1); filepath.Base(p.Path) != want || p.Payload != lines[i] {
+			t.Errorf("row %d: %s for %q, want %s for %q", i+1, p.Path, p.Payload, want, lines[i])
+		}
+
+		a, err := os.ReadFile(filepath.Join(dir, s.Path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := os.ReadFile(filepath.Join(dir, p.Path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(a, b) {
+			t.Errorf("%s differs between --jobs 1 and --jobs 8", filepath.Base(p.Path))
+		}
+	}
+	if got := decodeOne(t, filepath.Join(dir, "out-8", "qr-024.png")); got != lines[23] {
+		t.Errorf("qr-024.png decodes as %q, want %q", got, lines[23])
+	}
+}
@@ -1,12 +1,19 @@
 package cmd
 
 import (
-	"bufio"
+	"bytes"
+	"encoding/csv"
 	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"text/template"
+	"time"
 
 	"github.com/eliaseffects/qr-cli/internal/output"
 	"github.com/eliaseffects/qr-cli/internal/qr"
@@ -15,11 +22,55 @@ import (
 
 type batchFlags struct {
 	File   string
+	CSV    string
+	JSONL  string
 	Dir    string
-	Size   int
+	Size   string
+	DPI    int
+	Fit    string
 	Format string
 	Prefix string
 	Quiet  bool
+	Split  bool
+	Verify bool
+
+	// PDF page size and physical symbol width in millimetres.
+	PageSize string
+	Physical float64
+
+	// Sheet lays the codes out on label sheets; Caption is a template
+	// for a caption under each code.
+	Sheet   string
+	Caption string
+
+	// Delimiter and Columns describe CSV input; Template and Name render
+	// the payload and file name from each row.
+	Delimiter string
+	Columns   string
+	Template  string
+	Name      string
+	Type      string
+
+	// Jobs is the number of codes rendered at once.
+	Jobs int
+
+	LogoPath  string
+	LogoScale float64
+
+	// ContinueOnError skips rows that fail and lists them in ErrorsFile.
+	ContinueOnError bool
+	ErrorsFile      string
+
+	// Resume and Incremental skip files an earlier run rendered from the
+	// same payload and options; Resume also checks each file's hash.
+	Resume      bool
+	Incremental bool
+
+	// Manifest is a CSV or JSON file listing every code; Redact puts a
+	// hash there in place of each payload, keyed with RedactKey if set.
+	Manifest  string
+	Redact    bool
+	RedactKey string
 }
 
 var (
@@ -27,92 +78,473 @@ var (
 
 	batchCmd = &cobra.Command{
 		Use:   "batch",
-		Short: "Generate QR codes from a file of data (one per line)",
+		Short: "Generate QR codes from a file of data (one per line) or CSV/JSONL rows",
 		RunE:  runBatch,
 	}
 )
 
 func init() {
-	batchCmd.Flags().StringVarP(&batchCfg.File, "file", "f", "", "Input file with data (one per line, required)")
+	batchCmd.Flags().StringVarP(&batchCfg.File, "file", "f", "", "Input file with data (one per line)")
+	batchCmd.Flags().StringVar(&batchCfg.CSV, "csv", "", "Input CSV or TSV file with a header row")
+	batchCmd.Flags().StringVar(&batchCfg.JSONL, "jsonl", "", "Input file with one JSON object per line")
+	batchCmd.Flags().StringVar(&batchCfg.Delimiter, "delimiter", "", "CSV field delimiter: one character, or \"tab\" (default: tab for .tsv, else comma)")
+	batchCmd.Flags().StringVar(&batchCfg.Columns, "columns", "", "Comma-separated column names for a CSV without a header row")
+	batchCmd.Flags().StringVar(&batchCfg.Template, "template", "", "Payload template over the row's fields, e.g. \"https://ex.com/u/{{.id}}\" (default: {{.data}})")
+	batchCmd.Flags().StringVar(&batchCfg.Type, "type", "text", "Payload type of each row: "+strings.Join(batchTypes, ", ")+"; wifi and vcard map columns onto fields")
+	batchCmd.Flags().StringVar(&batchCfg.Name, "name", "", "File name template without extension, e.g. \"{{.last}}-{{.id}}\" (default: prefix and index)")
 	batchCmd.Flags().StringVarP(&batchCfg.Dir, "dir", "d", "./qr-output", "Output directory")
-	batchCmd.Flags().IntVarP(&batchCfg.Size, "size", "s", 256, "Image size in pixels")
-	batchCmd.Flags().StringVar(&batchCfg.Format, "format", "png", "Output format: png, svg")
+	batchCmd.Flags().StringVarP(&batchCfg.Size, "size", "s", "256", "Image size in pixels, or a print size in mm, cm, in or pt (e.g. 25mm)")
+	batchCmd.Flags().IntVar(&batchCfg.DPI, "dpi", 0, "Print resolution for PNG pixels and metadata (default 300 with a print --size)")
+	batchCmd.Flags().StringVar(&batchCfg.Fit, "fit", "pad", "How PNG output meets --size: pad, exact, round-up, round-down")
+	batchCmd.Flags().StringVar(&batchCfg.Format, "format", "png", "Output format: png, svg, pdf, eps")
 	batchCmd.Flags().StringVar(&batchCfg.Prefix, "prefix", "qr-", "Filename prefix")
 	batchCmd.Flags().BoolVarP(&batchCfg.Quiet, "quiet", "q", false, "Suppress non-error output")
-	_ = batchCmd.MarkFlagRequired("file")
+	batchCmd.Flags().BoolVar(&batchCfg.Split, "split", false, "Split lines too long for one symbol into a Structured Append series")
+	batchCmd.Flags().StringVar(&batchCfg.PageSize, "page-size", "", "PDF page: a4, a5, a6, letter, legal or WIDTHxHEIGHT in mm (default: fit the symbol)")
+	batchCmd.Flags().Float64Var(&batchCfg.Physical, "physical-size", 0, "Symbol width in mm, quiet zone included, for PDF, EPS and SVG (default: --size)")
+	batchCmd.Flags().StringVar(&batchCfg.Sheet, "sheet", "", "Lay codes out on label sheets: "+sheetPresetNames()+", or a grid such as page=a4,cols=3,rows=7")
+	batchCmd.Flags().StringVar(&batchCfg.Caption, "caption", "", "Caption template under each code, e.g. \"{{.line}}: {{.data}}\"")
+	batchCmd.Flags().StringVar(&batchCfg.LogoPath, "logo", "", "Path to logo image to overlay on every code")
+	batchCmd.Flags().Float64Var(&batchCfg.LogoScale, "logo-scale", 0.2, "Logo size as fraction of QR (0.05-0.4)")
+	batchCmd.Flags().BoolVar(&batchCfg.ContinueOnError, "continue-on-error", false, "Skip rows that fail, list them in --errors-file and exit with status 3")
+	batchCmd.Flags().StringVar(&batchCfg.ErrorsFile, "errors-file", "", "CSV of rows skipped by --continue-on-error (default: <dir>/<prefix>errors.csv)")
+	batchCmd.Flags().StringVar(&batchCfg.Manifest, "manifest", "", "Write a manifest of every code to a .csv or .json file")
+	batchCmd.Flags().BoolVar(&batchCfg.Redact, "redact", false, "List a SHA-256 hash in the manifest instead of each payload; guessable payloads can be recovered from it without --redact-key")
+	batchCmd.Flags().StringVar(&batchCfg.RedactKey, "redact-key", "", "Key for an HMAC-SHA256 of each manifest payload in place of the plain hash; implies --redact")
+	batchCmd.Flags().BoolVar(&batchCfg.Resume, "resume", false, "Skip files an earlier run finished from the same inputs, checking each against its recorded hash")
+	batchCmd.Flags().BoolVar(&batchCfg.Incremental, "incremental", false, "Only regenerate files whose payload or rendering options changed since the last run")
+	batchCmd.Flags().IntVarP(&batchCfg.Jobs, "jobs", "j", 0, "Codes to render in parallel (default: the number of CPUs)")
+	batchCmd.Flags().BoolVar(&batchCfg.Verify, "verify", false, "Decode each rendered code and fail if it does not read back as its line")
 
+	completeBatchFlags(batchCmd)
 	bindBatchFlags(batchCmd)
 }
 
 func runBatch(cmd *cobra.Command, args []string) error {
 	applyBatchConfig(cmd)
 
-	if strings.TrimSpace(batchCfg.File) == "" {
-		return errors.New("input file is required")
+	input, err := resolveBatchInput()
+	if err != nil {
+		return err
 	}
 
 	format := strings.ToLower(strings.TrimSpace(batchCfg.Format))
 	if format == "" {
 		format = "png"
 	}
-	if format != "png" && format != "svg" {
+	if format != "png" && format != "svg" && format != "pdf" && format != "eps" {
 		return fmt.Errorf("unsupported format: %s", batchCfg.Format)
 	}
 
-	if batchCfg.Size <= 0 {
-		return errors.New("size must be greater than zero")
+	opts := qr.DefaultOptions()
+	if err := applySize(&opts, batchCfg.Size, batchCfg.DPI, batchCfg.Physical); err != nil {
+		return err
 	}
-
-	file, err := os.Open(batchCfg.File)
+	pageWidth, pageHeight, err := parsePageSize(batchCfg.PageSize)
 	if err != nil {
 		return err
 	}
-	defer file.Close()
+	if opts.Fit, err = parseFit(batchCfg.Fit); err != nil {
+		return err
+	}
 
-	scanner := bufio.NewScanner(file)
-	var lines []string
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" {
-			continue
+	var sheet *qr.SheetLayout
+	if strings.TrimSpace(batchCfg.Sheet) != "" {
+		layout, err := parseSheet(batchCfg.Sheet)
+		if err != nil {
+			return err
+		}
+		if format != "pdf" && format != "png" {
+			return fmt.Errorf("label sheets support pdf and png output, not %s", format)
+		}
+		if batchCfg.Resume || batchCfg.Incremental {
+			return errors.New("--resume and --incremental apply to one file per code, not --sheet")
 		}
-		lines = append(lines, line)
+		sheet = &layout
+	}
+	if batchCfg.Manifest != "" {
+		if _, err := manifestFormat(batchCfg.Manifest); err != nil {
+			return err
+		}
+	}
+	payload, err := parseBatchTemplate("template", batchCfg.Template)
+	if err != nil {
+		return err
 	}
-	if err := scanner.Err(); err != nil {
+	name, err := parseBatchTemplate("name", batchCfg.Name)
+	if err != nil {
+		return err
+	}
+	caption, err := parseBatchTemplate("caption", batchCfg.Caption)
+	if err != nil {
+		return err
+	}
+
+	kind, err := parseBatchType(batchCfg.Type)
+	if err != nil {
 		return err
 	}
-	if len(lines) == 0 {
-		return errors.New("no data found in input file")
+	records, err := readBatchRecords(input, kind, payload, name)
+	if err != nil {
+		return err
 	}
 
 	if err := os.MkdirAll(batchCfg.Dir, 0o755); err != nil {
 		return err
 	}
 
-	opts := qr.DefaultOptions()
-	opts.Size = batchCfg.Size
+	opts.PageWidth, opts.PageHeight = pageWidth, pageHeight
+	opts.LogoPath, opts.LogoScale = strings.TrimSpace(batchCfg.LogoPath), batchCfg.LogoScale
 
-	for i, line := range lines {
-		var payload []byte
-		if format == "svg" {
-			payload, err = qr.SVG(line, opts)
-		} else {
-			payload, err = qr.PNG(line, opts)
+	workers := batchCfg.Jobs
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	job := batchJob{
+		opts:        opts,
+		format:      format,
+		dir:         batchCfg.Dir,
+		caption:     caption,
+		split:       batchCfg.Split,
+		verify:      batchCfg.Verify,
+		sheet:       sheet != nil,
+		describe:    batchCfg.Manifest != "",
+		resume:      batchCfg.Resume,
+		incremental: batchCfg.Incremental,
+	}
+	if job.resume || job.incremental {
+		if job.state, err = openBatchState(batchCfg.Dir, batchCfg.Prefix); err != nil {
+			return err
 		}
-		if err != nil {
-			return fmt.Errorf("line %d: %w", i+1, err)
+	}
+	started := time.Now()
+	rendered, errs := job.renderAll(records, workers, batchCfg.ContinueOnError)
+	elapsed := time.Since(started)
+	if job.state != nil {
+		// Only a run that reached every row drops entries it did not keep.
+		complete := true
+		for _, err := range errs {
+			var row *rowError
+			if err != nil && (!batchCfg.ContinueOnError || !errors.As(err, &row)) {
+				complete = false
+			}
 		}
+		if err := job.state.close(complete); err != nil {
+			return err
+		}
+	}
 
-		filename := fmt.Sprintf("%s%03d.%s", batchCfg.Prefix, i+1, format)
-		path := filepath.Join(batchCfg.Dir, filename)
-		if err := output.WriteFile(path, payload); err != nil {
+	var outputs []batchOutput
+	var failures []batchFailure
+	for i, err := range errs {
+		if err == nil {
+			outputs = append(outputs, rendered[i]...)
+			continue
+		}
+		var row *rowError
+		if !batchCfg.ContinueOnError || !errors.As(err, &row) {
+			return err
+		}
+		failures = append(failures, batchFailure{Index: records[i].Index, Line: row.Line, Reason: row.Err.Error()})
+	}
+	errorsPath := ""
+	if batchCfg.ContinueOnError {
+		errorsPath = batchCfg.ErrorsFile
+		if errorsPath == "" {
+			errorsPath = filepath.Join(batchCfg.Dir, batchCfg.Prefix+"errors.csv")
+		}
+		if err := writeErrorsFile(errorsPath, failures); err != nil {
+			return err
+		}
+	}
+	pages := 0
+	if sheet != nil && len(outputs) > 0 {
+		if pages, err = writeSheets(outputs, *sheet, format); err != nil {
+			return err
+		}
+	}
+	if batchCfg.Manifest != "" {
+		redact := batchCfg.Redact || batchCfg.RedactKey != ""
+		if err := writeManifest(batchCfg.Manifest, outputs, redact, batchCfg.RedactKey); err != nil {
 			return err
 		}
 	}
 
 	if !batchCfg.Quiet {
-		fmt.Printf("✓ Generated %d QR codes in %s\n", len(lines), batchCfg.Dir)
+		if sheet != nil {
+			fmt.Printf("✓ Laid out %d QR codes on %d sheets in %s\n", len(outputs), pages, batchCfg.Dir)
+		} else {
+			fmt.Printf("✓ Generated %d QR codes in %s\n", len(outputs), batchCfg.Dir)
+			skipped := 0
+			for _, out := range outputs {
+				if out.Skipped {
+					skipped++
+				}
+			}
+			if skipped < len(outputs) {
+				fmt.Printf("  %s, %.0f codes/s with --jobs %d\n", elapsed.Round(time.Millisecond), float64(len(outputs)-skipped)/max(elapsed.Seconds(), 1e-9), workers)
+			}
+			if skipped > 0 {
+				fmt.Printf("  %d up to date, %d rendered\n", skipped, len(outputs)-skipped)
+			}
+		}
+		if batchCfg.Manifest != "" {
+			fmt.Printf("  manifest written to %s\n", batchCfg.Manifest)
+		}
 	}
 
+	if len(failures) > 0 {
+		cmd.SilenceUsage, cmd.SilenceErrors = true, true
+		return &ExitError{
+			Code: ExitPartialFailure,
+			Err: fmt.Errorf("✗ %d of %d rows failed, %d succeeded; see %s",
+				len(failures), len(records), len(records)-len(failures), errorsPath),
+		}
+	}
 	return nil
 }
+
+// batchFailure is a row skipped by --continue-on-error.
+type batchFailure struct {
+	Index, Line int
+	Reason      string
+}
+
+// writeErrorsFile lists failed rows as CSV. It is written even when no
+// row failed, so a stale report never outlives a clean run.
+func writeErrorsFile(path string, failures []batchFailure) error {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	_ = w.Write([]string{"index", "line", "reason"})
+	for _, f := range failures {
+		_ = w.Write([]string{strconv.Itoa(f.Index), strconv.Itoa(f.Line), f.Reason})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	return output.WriteFile(path, buf.Bytes())
+}
+
+// batchJob is what every record of a batch run shares.
+type batchJob struct {
+	opts     qr.Options
+	format   string
+	dir      string
+	caption  *template.Template
+	split    bool // split long payloads into a Structured Append series
+	verify   bool // decode each code and fail if it does not read back
+	sheet    bool // collect labels for a sheet rather than writing files
+	describe bool // record the symbol version and level of each code
+
+	// state records written files under --resume or --incremental. Both
+	// keep files an earlier run rendered from the same inputs; resume also
+	// re-hashes each file, where incremental trusts its size.
+	state       *batchState
+	resume      bool
+	incremental bool
+}
+
+// batchOutput is one code rendered from a record: a file written to Path,
+// or a label placed on the sheet file at Path. Bytes is the size of that
+// file.
+type batchOutput struct {
+	Index, Line, Part int
+	Path              string
+	Data              string
+	Options           qr.Options
+	Bytes             int
+
+	Version string
+	Level   qr.RecoveryLevel
+
+	Skipped bool // an earlier run's file was kept
+}
+
+// renderAll renders records on a pool of workers and returns their outputs
+// and errors in record order, so file names and sheet order do not depend
+// on scheduling. Rows that failed to read keep their error. After a
+// failure no new records are started, unless keepGoing is set and the
+// failure belongs to its row.
+func (j batchJob) renderAll(records []batchRecord, workers int, keepGoing bool) ([][]batchOutput, []error) {
+	outputs := make([][]batchOutput, len(records))
+	errs := make([]error, len(records))
+
+	var stop atomic.Bool
+	fail := func(err error) {
+		var row *rowError
+		if !keepGoing || !errors.As(err, &row) {
+			stop.Store(true)
+		}
+	}
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for range min(workers, len(records)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				if outputs[i], errs[i] = j.render(records[i]); errs[i] != nil {
+					fail(errs[i])
+				}
+			}
+		}()
+	}
+	for i, rec := range records {
+		if stop.Load() {
+			break
+		}
+		if rec.Err != nil {
+			errs[i] = rec.Err
+			fail(rec.Err)
+			continue
+		}
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	return outputs, errs
+}
+
+// render renders one record, split into a Structured Append series when
+// --split asks, and writes its files unless they are sheet labels.
+func (j batchJob) render(rec batchRecord) ([]batchOutput, error) {
+	opts := j.opts
+	opts.GS1 = rec.GS1
+	if j.caption != nil {
+		var err error
+		if opts.Caption, err = executeTemplate(j.caption, rec.Fields); err != nil {
+			return nil, &rowError{Line: rec.Line, Err: err}
+		}
+	}
+
+	parts := []qr.Part{{Data: rec.Data}}
+	if j.split {
+		var err error
+		if parts, err = qr.Split(rec.Data, opts); err != nil {
+			return nil, &rowError{Line: rec.Line, Err: err}
+		}
+	}
+
+	outputs := make([]batchOutput, 0, len(parts))
+	for i, part := range parts {
+		out := batchOutput{Index: rec.Index, Line: rec.Line, Part: i + 1, Data: part.Data, Options: part.Options(opts)}
+		if j.sheet {
+			if err := j.describeOutput(&out); err != nil {
+				return nil, &rowError{Line: rec.Line, Err: err}
+			}
+			if j.verify {
+				if err := verifyRendered(out.Data, out.Options, "", nil); err != nil {
+					return nil, &rowError{Line: rec.Line, Err: err}
+				}
+			}
+			outputs = append(outputs, out)
+			continue
+		}
+
+		out.Path = filepath.Join(j.dir, rec.Name+"."+j.format)
+		if len(parts) > 1 {
+			out.Path = numberedPath(out.Path, i+1)
+		}
+		var key string
+		if j.state != nil {
+			var err error
+			if key, err = outputKey(out.Data, out.Options, j.format); err != nil {
+				return nil, &rowError{Line: rec.Line, Err: err}
+			}
+			// The key covers the payload, so a kept file holds out.Data.
+			if e, ok := j.state.reusable(out.Path, key, j.resume); ok {
+				j.state.keep(e)
+				out.Bytes, out.Version, out.Level, out.Skipped = e.Bytes, e.Version, e.Level, true
+				if err := j.describeOutput(&out); err != nil {
+					return nil, &rowError{Line: rec.Line, Err: err}
+				}
+				outputs = append(outputs, out)
+				continue
+			}
+		}
+
+		if err := j.describeOutput(&out); err != nil {
+			return nil, &rowError{Line: rec.Line, Err: err}
+		}
+		payload, err := render(out.Data, out.Options, j.format)
+		if err != nil {
+			return nil, &rowError{Line: rec.Line, Err: err}
+		}
+		if j.verify {
+			if err := verifyRendered(out.Data, out.Options, j.format, payload); err != nil {
+				return nil, &rowError{Line: rec.Line, Err: err}
+			}
+		}
+
+		if err := output.WriteFile(out.Path, payload); err != nil {
+			return nil, err
+		}
+		if j.state != nil {
+			if err := j.state.record(out, key, payload); err != nil {
+				return nil, err
+			}
+		}
+		out.Bytes = len(payload)
+		outputs = append(outputs, out)
+	}
+	return outputs, nil
+}
+
+// describeOutput fills in the symbol version and level of out for the
+// manifest, unless they are known already.
+func (j batchJob) describeOutput(out *batchOutput) error {
+	if !j.describe || out.Version != "" {
+		return nil
+	}
+	code, err := qr.Generate(out.Data, out.Options)
+	if err != nil {
+		return err
+	}
+	out.Version, out.Level = code.VersionName(), code.Level
+	return nil
+}
+
+// writeSheets renders labels onto sheets of layout, as one PDF or a PNG
+// per page, records the file each label landed in, and returns the number
+// of pages.
+func writeSheets(outputs []batchOutput, layout qr.SheetLayout, format string) (int, error) {
+	labels := make([]qr.SheetLabel, len(outputs))
+	for i, out := range outputs {
+		labels[i] = qr.SheetLabel{Data: out.Data, Options: out.Options}
+	}
+
+	if format == "pdf" {
+		payload, err := qr.SheetPDF(labels, layout)
+		if err != nil {
+			return 0, err
+		}
+		path := filepath.Join(batchCfg.Dir, batchCfg.Prefix+"sheet.pdf")
+		if err := output.WriteFile(path, payload); err != nil {
+			return 0, err
+		}
+		for i := range outputs {
+			outputs[i].Path, outputs[i].Bytes = path, len(payload)
+		}
+		return (len(labels) + layout.PerPage() - 1) / layout.PerPage(), nil
+	}
+
+	dpi := batchCfg.DPI
+	if dpi <= 0 {
+		dpi = defaultPrintDPI
+	}
+	pages, err := qr.SheetPNG(labels, layout, dpi)
+	if err != nil {
+		return 0, err
+	}
+	for i, payload := range pages {
+		path := filepath.Join(batchCfg.Dir, fmt.Sprintf("%ssheet-%03d.png", batchCfg.Prefix, i+1))
+		if err := output.WriteFile(path, payload); err != nil {
+			return 0, err
+		}
+		for k := i * layout.PerPage(); k < min((i+1)*layout.PerPage(), len(outputs)); k++ {
+			outputs[k].Path, outputs[k].Bytes = path, len(payload)
+		}
+	}
+	return len(pages), nil
+}
@@ -0,0 +1,366 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+	"unicode/utf8"
+
+	"github.com/eliaseffects/qr-cli/internal/qr"
+)
+
+// batchRecord is one code to generate: a row of the input with the line
+// it starts on, its fields for templates, and the payload and file name
+// rendered from them.
+type batchRecord struct {
+	Index  int // position among the records, from 1
+	Line   int
+	Fields map[string]any
+	Data   string
+	Name   string
+	GS1    bool // Data is a GS1 element string, encoded with FNC1
+
+	// Err is why the row cannot be generated, if it cannot.
+	Err error
+}
+
+// rowError is the failure of one input row, which --continue-on-error
+// skips; other errors stop the run.
+type rowError struct {
+	Line int
+	Err  error
+}
+
+func (e *rowError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *rowError) Unwrap() error {
+	return e.Err
+}
+
+// batchInput names the input file and how to read it.
+type batchInput struct {
+	path   string
+	format string // lines, csv or jsonl
+}
+
+// resolveBatchInput picks the one input given by --file, --csv or --jsonl.
+func resolveBatchInput() (batchInput, error) {
+	var inputs []batchInput
+	for _, in := range []batchInput{
+		{strings.TrimSpace(batchCfg.File), "lines"},
+		{strings.TrimSpace(batchCfg.CSV), "csv"},
+		{strings.TrimSpace(batchCfg.JSONL), "jsonl"},
+	} {
+		if in.path != "" {
+			inputs = append(inputs, in)
+		}
+	}
+	switch len(inputs) {
+	case 0:
+		return batchInput{}, errors.New("input file is required (--file, --csv or --jsonl)")
+	case 1:
+		return inputs[0], nil
+	default:
+		return batchInput{}, errors.New("use only one of --file, --csv and --jsonl")
+	}
+}
+
+// readBatchRecords reads in and builds each record's payload of type
+// kind, and renders its file name from the name template. A row that
+// cannot be generated carries its error in Err. A wifi or vcard
+// payload comes from the row's columns; otherwise it is rendered from the
+// payload template, or with none, plain lines are their own payload and a
+// CSV or JSONL row uses its data field.
+func readBatchRecords(in batchInput, kind string, payload, name *template.Template) ([]batchRecord, error) {
+	if kind == "wifi" || kind == "vcard" {
+		if in.format == "lines" {
+			return nil, fmt.Errorf("--type %s needs --csv or --jsonl input with a column per field", kind)
+		}
+		if payload != nil {
+			return nil, fmt.Errorf("--type %s builds the payload from columns and takes no --template", kind)
+		}
+	}
+
+	var delimiter rune
+	if in.format == "csv" {
+		var err error
+		if delimiter, err = csvDelimiter(in.path); err != nil {
+			return nil, err
+		}
+	}
+
+	file, err := os.Open(in.path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var records []batchRecord
+	switch in.format {
+	case "csv":
+		records, err = readCSVRecords(file, delimiter)
+	case "jsonl":
+		records, err = readJSONLRecords(file)
+	default:
+		records, err = readLineRecords(file)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, errors.New("no data found in input file")
+	}
+
+	names := make(map[string]int)
+	for i := range records {
+		rec := &records[i]
+		rec.Index = i + 1
+		if rec.Err != nil {
+			continue
+		}
+		if err := rec.prepare(in, kind, payload, name); err != nil {
+			rec.Err = &rowError{Line: rec.Line, Err: err}
+			continue
+		}
+		if prev, ok := names[rec.Name]; ok {
+			rec.Err = &rowError{Line: rec.Line, Err: fmt.Errorf("file name %q is already used by line %d", rec.Name, prev)}
+			continue
+		}
+		names[rec.Name] = rec.Line
+	}
+	return records, nil
+}
+
+// builtinFields are the template fields every record has, which no
+// column may take.
+var builtinFields = []string{"line", "index"}
+
+// prepare builds the payload and file name of a record read from in.
+func (rec *batchRecord) prepare(in batchInput, kind string, payload, name *template.Template) error {
+	for _, field := range builtinFields {
+		if _, ok := rec.Fields[field]; ok {
+			return fmt.Errorf("field %q clashes with the built-in {{.%s}}; rename it", field, field)
+		}
+	}
+	rec.Fields["line"] = rec.Line
+	rec.Fields["index"] = rec.Index
+	_, dataColumn := rec.Fields["data"]
+	dataColumn = dataColumn && in.format != "lines"
+	built := kind == "wifi" || kind == "vcard" || payload != nil
+
+	var err error
+	switch {
+	case kind == "wifi" || kind == "vcard":
+		if rec.Data, err = typedPayload(kind, rec.Fields); err != nil {
+			return fmt.Errorf("%s: %w", kind, err)
+		}
+	case payload != nil:
+		if rec.Data, err = executeTemplate(payload, rec.Fields); err != nil {
+			return err
+		}
+	case in.format != "lines":
+		data, ok := rec.Fields["data"]
+		if !ok {
+			return errors.New("no data column; add one or give --template")
+		}
+		rec.Data = fmt.Sprint(data)
+	}
+	if rec.Data == "" {
+		return errors.New("payload is empty")
+	}
+	if kind == "gs1" {
+		data, err := qr.ParseGS1(rec.Data)
+		if err != nil {
+			return fmt.Errorf("gs1: %w", err)
+		}
+		rec.Data, rec.GS1 = data.Payload(), data.URI == ""
+	}
+	// A data column the payload was not built from keeps its value.
+	if !dataColumn || !built {
+		rec.Fields["data"] = rec.Data
+	}
+
+	rec.Name = fmt.Sprintf("%s%03d", batchCfg.Prefix, rec.Index)
+	if name != nil {
+		base, err := executeTemplate(name, rec.Fields)
+		if err != nil {
+			return err
+		}
+		if rec.Name, err = safeFileName(base); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readLineRecords reads one payload per non-blank line.
+func readLineRecords(r io.Reader) ([]batchRecord, error) {
+	scanner := bufio.NewScanner(r)
+	var records []batchRecord
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		records = append(records, batchRecord{Line: n, Data: line, Fields: map[string]any{"data": line}})
+	}
+	return records, scanner.Err()
+}
+
+// readCSVRecords reads rows keyed by the header row, or by --columns when
+// the file has no header. Quoted fields may hold delimiters, quotes and
+// line breaks. Rows that cannot be parsed, or have the wrong number of
+// fields, carry a row error.
+func readCSVRecords(r io.Reader, delimiter rune) ([]batchRecord, error) {
+	reader := csv.NewReader(r)
+	reader.Comma = delimiter
+	reader.FieldsPerRecord = -1
+
+	var header []string
+	if batchCfg.Columns != "" {
+		header = strings.Split(batchCfg.Columns, ",")
+	} else {
+		row, err := reader.Read()
+		if err == io.EOF {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		header = row
+	}
+	seen := make(map[string]bool)
+	for i, col := range header {
+		col = strings.TrimSpace(strings.TrimPrefix(col, "\ufeff"))
+		if col == "" {
+			return nil, fmt.Errorf("column %d has no name", i+1)
+		}
+		if seen[col] {
+			return nil, fmt.Errorf("column %q appears twice", col)
+		}
+		if slices.Contains(builtinFields, col) {
+			return nil, fmt.Errorf("column %q clashes with the built-in {{.%s}}; rename it", col, col)
+		}
+		seen[col] = true
+		header[i] = col
+	}
+
+	var records []batchRecord
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		// A malformed row fails on its own; the reader carries on after it.
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			line := parseErr.StartLine
+			err := fmt.Errorf("column %d: %w", parseErr.Column, parseErr.Err)
+			records = append(records, batchRecord{Line: line, Err: &rowError{Line: line, Err: err}})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		if len(row) != len(header) {
+			err := fmt.Errorf("%d fields, want %d (%s)", len(row), len(header), strings.Join(header, ", "))
+			records = append(records, batchRecord{Line: line, Err: &rowError{Line: line, Err: err}})
+			continue
+		}
+		fields := make(map[string]any, len(header)+3)
+		for i, col := range header {
+			fields[col] = row[i]
+		}
+		records = append(records, batchRecord{Line: line, Fields: fields})
+	}
+	return records, nil
+}
+
+// readJSONLRecords reads one JSON object per non-blank line. Numbers keep
+// their written form.
+func readJSONLRecords(r io.Reader) ([]batchRecord, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 16<<20)
+	var records []batchRecord
+	for n := 1; scanner.Scan(); n++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		dec := json.NewDecoder(bytes.NewReader(line))
+		dec.UseNumber()
+		var fields map[string]any
+		if err := dec.Decode(&fields); err != nil || fields == nil {
+			if err == nil {
+				err = errors.New("not a JSON object")
+			}
+			records = append(records, batchRecord{Line: n, Err: &rowError{Line: n, Err: err}})
+			continue
+		}
+		records = append(records, batchRecord{Line: n, Fields: fields})
+	}
+	return records, scanner.Err()
+}
+
+// csvDelimiter resolves --delimiter, defaulting to a tab for .tsv files
+// and a comma otherwise. A delimiter is one character that encoding/csv
+// accepts: not a quote, line break or invalid UTF-8.
+func csvDelimiter(path string) (rune, error) {
+	switch d := batchCfg.Delimiter; {
+	case d == "tab" || d == `\t`:
+		return '\t', nil
+	case d != "":
+		r, size := utf8.DecodeRuneInString(d)
+		if size != len(d) || r == utf8.RuneError || r == '"' || r == '\r' || r == '\n' || r == 0 {
+			return 0, fmt.Errorf("invalid --delimiter %q (want one character other than a quote or line break, or tab)", d)
+		}
+		return r, nil
+	case strings.EqualFold(filepath.Ext(path), ".tsv"):
+		return '\t', nil
+	default:
+		return ',', nil
+	}
+}
+
+// parseBatchTemplate parses a --template, --name or --caption template;
+// fields missing from a row are errors rather than "<no value>".
+func parseBatchTemplate(flag, text string) (*template.Template, error) {
+	if text == "" {
+		return nil, nil
+	}
+	tmpl, err := template.New(flag).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --%s template: %w", flag, err)
+	}
+	return tmpl, nil
+}
+
+func executeTemplate(tmpl *template.Template, fields map[string]any) (string, error) {
+	var b strings.Builder
+	if err := tmpl.Execute(&b, fields); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(b.String()), nil
+}
+
+// safeFileName checks that a rendered --name stays a single file inside
+// the output directory.
+func safeFileName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`+"\x00") {
+		return "", fmt.Errorf("invalid file name %q from --name", name)
+	}
+	return name, nil
+}
@@ -0,0 +1,269 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// row is what a test expects of a batch record: its line, payload and
+// file name, or part of its error.
+type row struct {
+	line int
+	data string
+	name string
+	err  string
+}
+
+func TestReadBatchRecords(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		content  string
+		columns  string
+		template string
+		nameTmpl string
+		want     []row
+	}{
+		{
+			name:    "lines skip blanks",
+			file:    "in.txt",
+			content: "alpha\n\n  beta  \n",
+			want:    []row{{line: 1, data: "alpha", name: "qr-001"}, {line: 3, data: "beta", name: "qr-002"}},
+		},
+		{
+			name:    "csv with quoted delimiter",
+			file:    "in.csv",
+			content: "id,data\n1,x\n2,\"y,z\"\n",
+			want:    []row{{line: 2, data: "x", name: "qr-001"}, {line: 3, data: "y,z", name: "qr-002"}},
+		},
+		{
+			name:    "csv multi-line field keeps its starting line",
+			file:    "in.csv",
+			content: "data\n\"a\nb\"\nc\n",
+			want:    []row{{line: 2, data: "a\nb", name: "qr-001"}, {line: 4, data: "c", name: "qr-002"}},
+		},
+		{
+			name:    "tsv by extension",
+			file:    "in.tsv",
+			content: "id\tdata\n1\ta,b\n",
+			want:    []row{{line: 2, data: "a,b", name: "qr-001"}},
+		},
+		{
+			name:    "byte order mark on the header",
+			file:    "in.csv",
+			content: "\ufeffdata\nx\n",
+			want:    []row{{line: 2, data: "x", name: "qr-001"}},
+		},
+		{
+			name:     "columns without a header",
+			file:     "in.csv",
+			content:  "7,x\n8,y\n",
+			columns:  "id,data",
+			nameTmpl: "item-{{.id}}",
+			want:     []row{{line: 1, data: "x", name: "item-7"}, {line: 2, data: "y", name: "item-8"}},
+		},
+		{
+			name:    "wrong field count",
+			file:    "in.csv",
+			content: "id,data\n1\n2,x\n",
+			want:    []row{{line: 2, err: "line 2: 1 fields, want 2 (id, data)"}, {line: 3, data: "x", name: "qr-002"}},
+		},
+		{
+			name:    "malformed csv row",
+			file:    "in.csv",
+			content: "data\na\"b\nc\n",
+			want:    []row{{line: 2, err: "line 2: column 2: bare \" in non-quoted-field"}, {line: 3, data: "c", name: "qr-002"}},
+		},
+		{
+			name:     "jsonl keeps numbers as written",
+			file:     "in.jsonl",
+			content:  "{\"id\":1.50,\"data\":\"x\"}\n\n{oops\n",
+			template: "https://ex.com/{{.id}}/{{.data}}",
+			want:     []row{{line: 1, data: "https://ex.com/1.50/x", name: "qr-001"}, {line: 3, err: "line 3: invalid character"}},
+		},
+		{
+			name:    "jsonl without a data field",
+			file:    "in.jsonl",
+			content: "{\"id\":1}\n",
+			want:    []row{{line: 1, err: "line 1: no data column; add one or give --template"}},
+		},
+		{
+			name:     "data column keeps its value beside a template",
+			file:     "in.csv",
+			content:  "id,data\n7,x\n",
+			template: "https://ex.com/{{.data}}",
+			nameTmpl: "{{.data}}-{{.index}}",
+			want:     []row{{line: 2, data: "https://ex.com/x", name: "x-1"}},
+		},
+		{
+			name:     "lines name from the templated payload",
+			file:     "in.txt",
+			content:  "7\n",
+			template: "id{{.data}}",
+			nameTmpl: "{{.data}}",
+			want:     []row{{line: 1, data: "id7", name: "id7"}},
+		},
+		{
+			name:    "jsonl field clashing with a built-in",
+			file:    "in.jsonl",
+			content: "{\"index\":9,\"data\":\"x\"}\n{\"data\":\"y\"}\n",
+			want:    []row{{line: 1, err: `field "index" clashes with the built-in {{.index}}`}, {line: 2, data: "y", name: "qr-002"}},
+		},
+		{
+			name:     "template with a missing key",
+			file:     "in.csv",
+			content:  "id\n1\n",
+			template: "{{.nope}}",
+			want:     []row{{line: 2, err: `map has no entry for key "nope"`}},
+		},
+		{
+			name:     "name leaving the output directory",
+			file:     "in.csv",
+			content:  "id,data\n../x,a\n",
+			nameTmpl: "{{.id}}",
+			want:     []row{{line: 2, err: `invalid file name "../x" from --name`}},
+		},
+		{
+			name:     "duplicate names",
+			file:     "in.csv",
+			content:  "id,data\na,1\na,2\n",
+			nameTmpl: "{{.id}}",
+			want:     []row{{line: 2, data: "1", name: "a"}, {line: 3, err: `line 3: file name "a" is already used by line 2`}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			saved := batchCfg
+			defer func() { batchCfg = saved }()
+			batchCfg = batchFlags{Prefix: "qr-", Columns: tt.columns}
+
+			path := filepath.Join(t.TempDir(), tt.file)
+			if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			format := "lines"
+			switch filepath.Ext(tt.file) {
+			case ".csv", ".tsv":
+				format = "csv"
+			case ".jsonl":
+				format = "jsonl"
+			}
+			payload, err := parseBatchTemplate("template", tt.template)
+			if err != nil {
+				t.Fatal(err)
+			}
+			name, err := parseBatchTemplate("name", tt.nameTmpl)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			records, err := readBatchRecords(batchInput{path: path, format: format}, "text", payload, name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(records) != len(tt.want) {
+				t.Fatalf("got %d records, want %d: %+v", len(records), len(tt.want), records)
+			}
+			for i, want := range tt.want {
+				rec := records[i]
+				if rec.Line != want.line {
+					t.Errorf("record %d: line %d, want %d", i+1, rec.Line, want.line)
+				}
+				if want.err != "" {
+					if rec.Err == nil || !strings.Contains(rec.Err.Error(), want.err) {
+						t.Errorf("record %d: error %v, want %q", i+1, rec.Err, want.err)
+					}
+					continue
+				}
+				if rec.Err != nil {
+					t.Errorf("record %d: unexpected error %v", i+1, rec.Err)
+					continue
+				}
+				if rec.Data != want.data || rec.Name != want.name {
+					t.Errorf("record %d: data %q name %q, want %q and %q", i+1, rec.Data, rec.Name, want.data, want.name)
+				}
+			}
+		})
+	}
+}
+
+func TestReadBatchRecordsFileErrors(t *testing.T) {
+	saved := batchCfg
+	defer func() { batchCfg = saved }()
+	batchCfg = batchFlags{Prefix: "qr-"}
+
+	dir := t.TempDir()
+	tests := []struct {
+		content string
+		want    string
+	}{
+		{"", "no data found in input file"},
+		{"id,id\n1,2\n", `column "id" appears twice`},
+		{"id,,data\n", "column 2 has no name"},
+		{"line,data\n1,x\n", `column "line" clashes with the built-in {{.line}}`},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(dir, "in.csv")
+		if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := readBatchRecords(batchInput{path: path, format: "csv"}, "text", nil, nil)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("content %q: error %v, want %q", tt.content, err, tt.want)
+		}
+	}
+}
+
+func TestCSVDelimiter(t *testing.T) {
+	tests := []struct {
+		flag, path string
+		want       rune // 0 for an error
+	}{
+		{"", "in.csv", ','},
+		{"", "IN.TSV", '\t'},
+		{"tab", "in.csv", '\t'},
+		{`\t`, "in.csv", '\t'},
+		{";", "in.tsv", ';'},
+		{"§", "in.csv", '§'},
+		{";;", "in.csv", 0},
+		{`"`, "in.csv", 0},
+		{"\n", "in.csv", 0},
+		{"\r", "in.csv", 0},
+		{"\xff", "in.csv", 0},
+		{"\ufffd", "in.csv", 0},
+	}
+	for _, tt := range tests {
+		saved := batchCfg
+		batchCfg.Delimiter = tt.flag
+		got, err := csvDelimiter(tt.path)
+		if got != tt.want || (err == nil) != (tt.want != 0) {
+			t.Errorf("csvDelimiter(%q) with --delimiter %q = %q, %v, want %q", tt.path, tt.flag, got, err, tt.want)
+		}
+		batchCfg = saved
+	}
+}
+
+func TestSafeFileName(t *testing.T) {
+	tests := []struct {
+		name string
+		ok   bool
+	}{
+		{"badge-42", true},
+		{"  spaced  ", true},
+		{"", false},
+		{".", false},
+		{"..", false},
+		{"a/b", false},
+		{`a\b`, false},
+		{"a\x00b", false},
+	}
+	for _, tt := range tests {
+		_, err := safeFileName(tt.name)
+		if (err == nil) != tt.ok {
+			t.Errorf("safeFileName(%q) error = %v, want ok %v", tt.name, err, tt.ok)
+		}
+	}
+}
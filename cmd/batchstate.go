@@ -0,0 +1,198 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/eliaseffects/qr-cli/internal/qr"
+)
+
+// stateEntry records a file a batch run wrote: a hash of everything that
+// went into rendering it, and a hash of what was written.
+type stateEntry struct {
+	Path   string `json:"path"` // relative to the output directory
+	Key    string `json:"key"`
+	SHA256 string `json:"sha256"`
+	Bytes  int    `json:"bytes"`
+
+	// Version and Level describe the symbol when the run that wrote it
+	// had a manifest.
+	Version string           `json:"version,omitempty"`
+	Level   qr.RecoveryLevel `json:"level"`
+}
+
+// batchState is the sidecar file in the output directory that lets a
+// later run skip files that are already done. Entries are appended as
+// each file is written, so the state survives a run that dies, and the
+// file is compacted when a run finishes.
+type batchState struct {
+	mu      sync.Mutex
+	path    string
+	log     *os.File
+	prev    map[string]stateEntry // from earlier runs
+	entries map[string]stateEntry // written or kept by this run
+}
+
+// openBatchState opens the state file of a batch writing to dir and
+// reads the entries of earlier runs.
+func openBatchState(dir, prefix string) (*batchState, error) {
+	s := &batchState{
+		path:    filepath.Join(dir, "."+prefix+"state.jsonl"),
+		prev:    make(map[string]stateEntry),
+		entries: make(map[string]stateEntry),
+	}
+	data, err := os.ReadFile(s.path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	// Later entries replace earlier ones; a line torn by a crash is
+	// skipped, and its file is regenerated.
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		var e stateEntry
+		if json.Unmarshal(scanner.Bytes(), &e) == nil && e.Path != "" {
+			s.prev[e.Path] = e
+		}
+	}
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		// Start appending on a line of its own.
+		data = append(data, '\n')
+		if err := os.WriteFile(s.path, data, 0o644); err != nil {
+			return nil, err
+		}
+	}
+
+	if s.log, err = os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644); err != nil {
+		return nil, fmt.Errorf("failed to open batch state: %w", err)
+	}
+	return s, nil
+}
+
+// reusable returns the earlier entry for the file at path when it was
+// rendered from key and the file still has the size it was written with.
+// With verify set, its contents must also match the recorded hash. An
+// empty key matches nothing.
+func (s *batchState) reusable(path, key string, verify bool) (stateEntry, bool) {
+	if key == "" {
+		return stateEntry{}, false
+	}
+	s.mu.Lock()
+	e, ok := s.prev[filepath.Base(path)]
+	s.mu.Unlock()
+	if !ok || e.Key != key {
+		return stateEntry{}, false
+	}
+	if !verify {
+		info, err := os.Stat(path)
+		if err != nil || !info.Mode().IsRegular() || info.Size() != int64(e.Bytes) {
+			return stateEntry{}, false
+		}
+		return e, true
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || len(data) != e.Bytes || contentHash(data) != e.SHA256 {
+		return stateEntry{}, false
+	}
+	return e, true
+}
+
+// keep carries an earlier entry over to this run.
+func (s *batchState) keep(e stateEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[e.Path] = e
+}
+
+// record notes that data was written for out from key.
+func (s *batchState) record(out batchOutput, key string, data []byte) error {
+	e := stateEntry{
+		Path:    filepath.Base(out.Path),
+		Key:     key,
+		SHA256:  contentHash(data),
+		Bytes:   len(data),
+		Version: out.Version,
+		Level:   out.Level,
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[e.Path] = e
+	_, err = s.log.Write(append(line, '\n'))
+	return err
+}
+
+// close ends the append log. With compact set, the state is rewritten
+// to hold only this run's files; a run that stopped early leaves the log
+// as it is, so entries it never reached stay reusable.
+func (s *batchState) close(compact bool) error {
+	if err := s.log.Close(); err != nil || !compact {
+		return err
+	}
+
+	paths := make([]string, 0, len(s.entries))
+	for path := range s.entries {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	var buf bytes.Buffer
+	for _, path := range paths {
+		line, err := json.Marshal(s.entries[path])
+		if err != nil {
+			return err
+		}
+		buf.Write(append(line, '\n'))
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// outputKey hashes everything that decides the rendered file: the
+// payload, format and options, the logo and font files they name, and the
+// version of qr-cli that renders them. Options JSON cannot hold, such as
+// a NaN logo scale, are an error rather than a key shared by every file.
+func outputKey(data string, opts qr.Options, format string) (string, error) {
+	h := sha256.New()
+	err := json.NewEncoder(h).Encode(struct {
+		Version, Format, Data string
+		Options               qr.Options
+		Logo, Font            string
+	}{Version, format, data, opts, fileStamp(opts.LogoPath), fileStamp(opts.FontPath)})
+	if err != nil {
+		return "", fmt.Errorf("cannot record rendering options: %w", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// fileStamp identifies the version of a file by its size and
+// modification time, without reading it.
+func fileStamp(path string) string {
+	if path == "" {
+		return ""
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return "missing"
+	}
+	return fmt.Sprintf("%d %d", info.Size(), info.ModTime().UnixNano())
+}
+
+func contentHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/eliaseffects/qr-cli/internal/qr"
+)
+
+func TestOutputKey(t *testing.T) {
+	opts := qr.DefaultOptions()
+	key, err := outputKey("x", opts, "png")
+	if err != nil || key == "" {
+		t.Fatalf("outputKey() = %q, %v", key, err)
+	}
+	if other, _ := outputKey("y", opts, "png"); other == key {
+		t.Error("payloads x and y share a key")
+	}
+
+	for _, bad := range []float64{math.NaN(), math.Inf(1), math.Inf(-1)} {
+		opts := qr.DefaultOptions()
+		opts.LogoScale = bad
+		if key, err := outputKey("x", opts, "png"); err == nil || key != "" {
+			t.Errorf("logo scale %v: outputKey() = %q, %v, want an error", bad, key, err)
+		}
+	}
+}
+
+func TestReusableIgnoresEmptyKey(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "qr-001.png")
+	if err := os.WriteFile(path, []byte("png"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// An entry recorded without a key must not match a lookup without one.
+	state := `{"path":"qr-001.png","key":"","sha256":"` + contentHash([]byte("png")) + `","bytes":3,"level":0}` + "\n"
+	if err := os.WriteFile(filepath.Join(dir, ".qr-state.jsonl"), []byte(state), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := openBatchState(dir, "qr-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.close(false)
+	for _, verify := range []bool{false, true} {
+		if _, ok := s.reusable(path, "", verify); ok {
+			t.Errorf("verify %v: a file recorded under an empty key was reused", verify)
+		}
+	}
+}
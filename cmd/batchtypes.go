@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/eliaseffects/qr-cli/internal/qr"
+)
+
+// batchTypes are the payload types --type accepts, mapping a row's
+// fields onto a payload. text uses the payload template or data column
+// as is.
+var batchTypes = []string{"text", "wifi", "vcard", "gs1"}
+
+func parseBatchType(s string) (string, error) {
+	kind := strings.ToLower(strings.TrimSpace(s))
+	if kind == "" {
+		return "text", nil
+	}
+	for _, t := range batchTypes {
+		if kind == t {
+			return kind, nil
+		}
+	}
+	return "", fmt.Errorf("invalid batch type: %s (want %s)", s, strings.Join(batchTypes, ", "))
+}
+
+// typedPayload builds the payload of a wifi or vcard row from its columns,
+// validating it as the wifi and vcard commands do. A column named exactly
+// as a field wins, then the one column matching it case-insensitively; a
+// row with several such columns is ambiguous. Other columns are left for
+// templates; the first of several names for a field that a row has wins.
+func typedPayload(kind string, fields map[string]any) (string, error) {
+	var ambiguous error
+	get := func(names ...string) string {
+		for _, name := range names {
+			if value, ok := fields[name]; ok && value != nil {
+				return strings.TrimSpace(fmt.Sprint(value))
+			}
+			var keys []string
+			for key, value := range fields {
+				if strings.EqualFold(key, name) && value != nil {
+					keys = append(keys, key)
+				}
+			}
+			switch {
+			case len(keys) == 1:
+				return strings.TrimSpace(fmt.Sprint(fields[keys[0]]))
+			case len(keys) > 1:
+				if ambiguous == nil {
+					sort.Strings(keys)
+					ambiguous = fmt.Errorf("columns %s all match %s; rename all but one", strings.Join(keys, ", "), name)
+				}
+				return ""
+			}
+		}
+		return ""
+	}
+
+	switch kind {
+	case "wifi":
+		config := qr.WifiConfig{
+			SSID:     get("ssid", "network"),
+			Password: get("password", "pass"),
+		}
+		security, hidden := get("security", "type", "auth"), get("hidden")
+		if ambiguous != nil {
+			return "", ambiguous
+		}
+		var err error
+		if config.Security, err = qr.ParseWifiSecurity(security); err != nil {
+			return "", err
+		}
+		if config.Security == "nopass" {
+			config.Password = ""
+		}
+		if hidden != "" {
+			if config.Hidden, err = strconv.ParseBool(hidden); err != nil {
+				return "", fmt.Errorf("invalid hidden value: %s (want true or false)", hidden)
+			}
+		}
+		if err := config.Validate(); err != nil {
+			return "", err
+		}
+		return config.String(), nil
+	case "vcard":
+		card := qr.VCard{
+			Name:    get("name", "fn"),
+			Phone:   get("phone", "tel"),
+			Email:   get("email"),
+			Org:     get("org", "organization", "company"),
+			Title:   get("title"),
+			URL:     get("url", "website"),
+			Address: get("address", "adr"),
+		}
+		if ambiguous != nil {
+			return "", ambiguous
+		}
+		if err := card.Validate(); err != nil {
+			return "", err
+		}
+		return card.String(), nil
+	}
+	return "", fmt.Errorf("invalid batch type: %s", kind)
+}
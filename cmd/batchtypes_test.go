@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTypedPayload(t *testing.T) {
+	tests := []struct {
+		name   string
+		kind   string
+		fields map[string]any
+		want   string // part of the payload, or of the error
+		ok     bool
+	}{
+		{"wifi", "wifi", map[string]any{"SSID": "Home", "Password": "hunter22", "room": "4"}, "S:Home;", true},
+		{"exact name wins", "vcard", map[string]any{"name": "Ada", "NAME": "Grace"}, "FN:Ada", true},
+		{"one case-insensitive match", "vcard", map[string]any{"Name": "Ada"}, "FN:Ada", true},
+		{"null column is absent", "vcard", map[string]any{"name": nil, "Name": "Ada"}, "FN:Ada", true},
+		{"several case-insensitive matches", "vcard", map[string]any{"Name": "Ada", "NAME": "Grace"}, "columns NAME, Name all match name", false},
+		{"ambiguous security", "wifi", map[string]any{"ssid": "Home", "Type": "WPA", "TYPE": "nopass"}, "columns TYPE, Type all match type", false},
+	}
+	for _, tt := range tests {
+		// Map order varies between runs; the result must not.
+		for range 20 {
+			got, err := typedPayload(tt.kind, tt.fields)
+			if tt.ok && (err != nil || !strings.Contains(got, tt.want)) {
+				t.Fatalf("%s: typedPayload() = %q, %v, want %q in the payload", tt.name, got, err, tt.want)
+			}
+			if !tt.ok && (err == nil || !strings.Contains(err.Error(), tt.want)) {
+				t.Fatalf("%s: typedPayload() error = %v, want %q", tt.name, err, tt.want)
+			}
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// Values of enumerated flags offered by shell completion. The scripts in
+// scripts/completions ask the binary for them at completion time.
+var (
+	symbolValues = []string{"qr", "micro", "rmqr"}
+	maskValues   = []string{"auto", "0", "1", "2", "3", "4", "5", "6", "7"}
+	modeValues   = []string{"auto", "numeric", "alphanumeric", "byte", "kanji"}
+	fitValues    = []string{"pad", "exact", "round-up", "round-down"}
+)
+
+// completeValues offers fixed values for a flag in shell completion.
+func completeValues(cmd *cobra.Command, flag string, values ...string) {
+	_ = cmd.RegisterFlagCompletionFunc(flag, cobra.FixedCompletions(values, cobra.ShellCompDirectiveNoFileComp))
+}
+
+// completeSymbolFlags offers values for the encoder flags that
+// generation and inspect share.
+func completeSymbolFlags(cmd *cobra.Command) {
+	completeValues(cmd, "symbol", symbolValues...)
+	completeValues(cmd, "mask", maskValues...)
+	completeValues(cmd, "mode", modeValues...)
+}
+
+// completeOutputFlags offers values for the flags addOutputFlags adds.
+func completeOutputFlags(cmd *cobra.Command) {
+	completeSymbolFlags(cmd)
+	completeValues(cmd, "module-style", "square", "dot", "rounded", "vertical-bars", "horizontal-bars", "connected")
+	completeValues(cmd, "eye-frame", "square", "rounded", "circle")
+	completeValues(cmd, "eye-pupil", "square", "rounded", "circle")
+	completeValues(cmd, "gradient-type", "linear", "radial")
+	completeValues(cmd, "fit", fitValues...)
+	completeValues(cmd, "format", "png", "svg", "pdf", "eps", "terminal")
+	completeValues(cmd, "page-size", pageSizeNames()...)
+	completeValues(cmd, "svg-geometry", "path", "use", "rects")
+	completeValues(cmd, "caption-position", "top", "bottom")
+	completeValues(cmd, "frame", "none", "square", "rounded", "banner")
+	_ = cmd.MarkFlagFilename("font", "ttf", "otf")
+}
+
+// completeBatchFlags offers values and file types for the batch flags.
+func completeBatchFlags(cmd *cobra.Command) {
+	completeValues(cmd, "fit", fitValues...)
+	completeValues(cmd, "sheet", strings.Split(sheetPresetNames(), ", ")...)
+	completeValues(cmd, "delimiter", ",", ";", "|", "tab")
+	_ = cmd.MarkFlagFilename("csv", "csv", "tsv", "txt")
+	_ = cmd.MarkFlagFilename("jsonl", "jsonl", "ndjson", "json")
+	completeValues(cmd, "type", batchTypes...)
+	_ = cmd.MarkFlagFilename("logo", "png", "jpg", "jpeg", "gif")
+	_ = cmd.MarkFlagFilename("manifest", "csv", "json")
+	_ = cmd.MarkFlagFilename("errors-file", "csv")
+}
+
+// pageSizeNames lists the named --page-size values in order.
+func pageSizeNames() []string {
+	names := make([]string, 0, len(pageSizes))
+	for name := range pageSizes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -15,6 +15,15 @@ var (
 	configErr error
 )
 
+// inEnv reports whether the QR_ environment variable for key is set,
+// which viper.IsSet cannot tell apart from a default.
+func inEnv(key string) bool {
+	_, ok := os.LookupEnv("QR_" + strings.ToUpper(envKeyReplacer.Replace(key)))
+	return ok
+}
+
+var envKeyReplacer = strings.NewReplacer(".", "_", "-", "_")
+
 func initConfig() {
 	if cfgFile != "" {
 		viper.SetConfigFile(cfgFile)
@@ -28,7 +37,7 @@ func initConfig() {
 	}
 
 	viper.SetEnvPrefix("QR")
-	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_", "-", "_"))
+	viper.SetEnvKeyReplacer(envKeyReplacer)
 	viper.AutomaticEnv()
 
 	setConfigDefaults()
@@ -42,12 +51,35 @@ func initConfig() {
 
 func setConfigDefaults() {
 	viper.SetDefault("output", "")
-	viper.SetDefault("size", 256)
+	viper.SetDefault("size", "256")
+	viper.SetDefault("dpi", 0)
+	viper.SetDefault("fit", "pad")
 	viper.SetDefault("format", "png")
+	viper.SetDefault("symbol", "qr")
 	viper.SetDefault("level", "M")
+	viper.SetDefault("symbol-version", 0)
+	viper.SetDefault("mask", "auto")
+	viper.SetDefault("mode", "auto")
+	viper.SetDefault("eci", 0)
+	viper.SetDefault("charset", "")
 	viper.SetDefault("fg", "#000000")
 	viper.SetDefault("bg", "#ffffff")
+	viper.SetDefault("fg-gradient", "")
+	viper.SetDefault("gradient-type", "linear")
+	viper.SetDefault("gradient-angle", 0.0)
 	viper.SetDefault("border", 4)
+	viper.SetDefault("module-style", "square")
+	viper.SetDefault("svg-geometry", "path")
+	viper.SetDefault("eye-frame", "square")
+	viper.SetDefault("eye-pupil", "square")
+	viper.SetDefault("eye-color", "")
+	viper.SetDefault("eye-pupil-color", "")
+	viper.SetDefault("page-size", "")
+	viper.SetDefault("physical-size", 0.0)
+	viper.SetDefault("caption", "")
+	viper.SetDefault("caption-position", "bottom")
+	viper.SetDefault("font", "")
+	viper.SetDefault("frame", "none")
 	viper.SetDefault("logo", "")
 	viper.SetDefault("logo-scale", 0.2)
 	viper.SetDefault("invert", false)
@@ -56,6 +88,8 @@ func setConfigDefaults() {
 	viper.SetDefault("open", false)
 	viper.SetDefault("copy", false)
 	viper.SetDefault("quiet", false)
+	viper.SetDefault("split", false)
+	viper.SetDefault("verify", false)
 
 	viper.SetDefault("wifi.ssid", "")
 	viper.SetDefault("wifi.pass", "")
@@ -71,21 +105,72 @@ func setConfigDefaults() {
 	viper.SetDefault("vcard.address", "")
 
 	viper.SetDefault("batch.file", "")
+	viper.SetDefault("batch.csv", "")
+	viper.SetDefault("batch.jsonl", "")
+	viper.SetDefault("batch.delimiter", "")
+	viper.SetDefault("batch.columns", "")
+	viper.SetDefault("batch.template", "")
+	viper.SetDefault("batch.name", "")
+	viper.SetDefault("batch.type", "text")
+	viper.SetDefault("batch.jobs", 0)
+	viper.SetDefault("batch.logo", "")
+	viper.SetDefault("batch.logo-scale", 0.2)
+	viper.SetDefault("batch.continue-on-error", false)
+	viper.SetDefault("batch.errors-file", "")
+	viper.SetDefault("batch.manifest", "")
+	viper.SetDefault("batch.redact", false)
+	viper.SetDefault("batch.redact-key", "")
+	viper.SetDefault("batch.resume", false)
+	viper.SetDefault("batch.incremental", false)
 	viper.SetDefault("batch.dir", "./qr-output")
-	viper.SetDefault("batch.size", 256)
+	viper.SetDefault("batch.size", "256")
+	viper.SetDefault("batch.dpi", 0)
+	viper.SetDefault("batch.fit", "pad")
 	viper.SetDefault("batch.format", "png")
 	viper.SetDefault("batch.prefix", "qr-")
 	viper.SetDefault("batch.quiet", false)
+	viper.SetDefault("batch.split", false)
+	viper.SetDefault("batch.verify", false)
+	viper.SetDefault("batch.page-size", "")
+	viper.SetDefault("batch.physical-size", 0.0)
+	viper.SetDefault("batch.sheet", "")
+	viper.SetDefault("batch.caption", "")
+
+	viper.SetDefault("inspect.dpi", 300)
+	viper.SetDefault("inspect.min-module", 0.25)
 }
 
 func bindOutputFlags(cmd *cobra.Command) {
 	bindFlag(cmd, "output", "output")
 	bindFlag(cmd, "size", "size")
+	bindFlag(cmd, "dpi", "dpi")
+	bindFlag(cmd, "fit", "fit")
 	bindFlag(cmd, "format", "format")
+	bindFlag(cmd, "symbol", "symbol")
 	bindFlag(cmd, "level", "level")
+	bindFlag(cmd, "symbol-version", "symbol-version")
+	bindFlag(cmd, "mask", "mask")
+	bindFlag(cmd, "mode", "mode")
+	bindFlag(cmd, "eci", "eci")
+	bindFlag(cmd, "charset", "charset")
 	bindFlag(cmd, "fg", "fg")
 	bindFlag(cmd, "bg", "bg")
+	bindFlag(cmd, "fg-gradient", "fg-gradient")
+	bindFlag(cmd, "gradient-type", "gradient-type")
+	bindFlag(cmd, "gradient-angle", "gradient-angle")
 	bindFlag(cmd, "border", "border")
+	bindFlag(cmd, "module-style", "module-style")
+	bindFlag(cmd, "svg-geometry", "svg-geometry")
+	bindFlag(cmd, "eye-frame", "eye-frame")
+	bindFlag(cmd, "eye-pupil", "eye-pupil")
+	bindFlag(cmd, "eye-color", "eye-color")
+	bindFlag(cmd, "eye-pupil-color", "eye-pupil-color")
+	bindFlag(cmd, "page-size", "page-size")
+	bindFlag(cmd, "physical-size", "physical-size")
+	bindFlag(cmd, "caption", "caption")
+	bindFlag(cmd, "caption-position", "caption-position")
+	bindFlag(cmd, "font", "font")
+	bindFlag(cmd, "frame", "frame")
 	bindFlag(cmd, "logo", "logo")
 	bindFlag(cmd, "logo-scale", "logo-scale")
 	bindFlag(cmd, "invert", "invert")
@@ -94,6 +179,8 @@ func bindOutputFlags(cmd *cobra.Command) {
 	bindFlag(cmd, "open", "open")
 	bindFlag(cmd, "copy", "copy")
 	bindFlag(cmd, "quiet", "quiet")
+	bindFlag(cmd, "split", "split")
+	bindFlag(cmd, "verify", "verify")
 }
 
 func bindFlag(cmd *cobra.Command, key, flag string) {
@@ -107,23 +194,94 @@ func applyOutputConfig(cmd *cobra.Command, flags *OutputFlags) {
 		flags.OutputPath = viper.GetString("output")
 	}
 	if !cmd.Flags().Changed("size") && viper.IsSet("size") {
-		flags.Size = viper.GetInt("size")
+		flags.Size = viper.GetString("size")
+	}
+	if !cmd.Flags().Changed("dpi") && viper.IsSet("dpi") {
+		flags.DPI = viper.GetInt("dpi")
+	}
+	if !cmd.Flags().Changed("fit") && viper.IsSet("fit") {
+		flags.Fit = viper.GetString("fit")
 	}
 	if !cmd.Flags().Changed("format") && viper.IsSet("format") {
 		flags.Format = viper.GetString("format")
 	}
+	if !cmd.Flags().Changed("symbol") && viper.IsSet("symbol") {
+		flags.Symbol = viper.GetString("symbol")
+	}
 	if !cmd.Flags().Changed("level") && viper.IsSet("level") {
 		flags.Level = viper.GetString("level")
 	}
+	if !cmd.Flags().Changed("symbol-version") && viper.IsSet("symbol-version") {
+		flags.Version = viper.GetInt("symbol-version")
+	}
+	if !cmd.Flags().Changed("mask") && viper.IsSet("mask") {
+		flags.Mask = viper.GetString("mask")
+	}
+	if !cmd.Flags().Changed("mode") && viper.IsSet("mode") {
+		flags.Mode = viper.GetString("mode")
+	}
+	if !cmd.Flags().Changed("eci") && viper.IsSet("eci") {
+		flags.ECI = viper.GetInt("eci")
+	}
+	if !cmd.Flags().Changed("charset") && viper.IsSet("charset") {
+		flags.Charset = viper.GetString("charset")
+	}
 	if !cmd.Flags().Changed("fg") && viper.IsSet("fg") {
 		flags.FgColor = viper.GetString("fg")
 	}
 	if !cmd.Flags().Changed("bg") && viper.IsSet("bg") {
 		flags.BgColor = viper.GetString("bg")
 	}
+	if !cmd.Flags().Changed("fg-gradient") && viper.IsSet("fg-gradient") {
+		flags.Gradient = viper.GetString("fg-gradient")
+	}
+	if !cmd.Flags().Changed("gradient-type") && viper.IsSet("gradient-type") {
+		flags.GradType = viper.GetString("gradient-type")
+	}
+	if !cmd.Flags().Changed("gradient-angle") && viper.IsSet("gradient-angle") {
+		flags.GradAngle = viper.GetFloat64("gradient-angle")
+	}
 	if !cmd.Flags().Changed("border") && viper.IsSet("border") {
 		flags.Border = viper.GetInt("border")
 	}
+	// An unset border falls back to the quiet zone of the chosen symbol.
+	flags.BorderSet = cmd.Flags().Changed("border") || viper.InConfig("border") || inEnv("border")
+	if !cmd.Flags().Changed("module-style") && viper.IsSet("module-style") {
+		flags.ModStyle = viper.GetString("module-style")
+	}
+	if !cmd.Flags().Changed("svg-geometry") && viper.IsSet("svg-geometry") {
+		flags.SVGGeom = viper.GetString("svg-geometry")
+	}
+	if !cmd.Flags().Changed("eye-frame") && viper.IsSet("eye-frame") {
+		flags.EyeFrame = viper.GetString("eye-frame")
+	}
+	if !cmd.Flags().Changed("eye-pupil") && viper.IsSet("eye-pupil") {
+		flags.EyePupil = viper.GetString("eye-pupil")
+	}
+	if !cmd.Flags().Changed("eye-color") && viper.IsSet("eye-color") {
+		flags.EyeColor = viper.GetString("eye-color")
+	}
+	if !cmd.Flags().Changed("eye-pupil-color") && viper.IsSet("eye-pupil-color") {
+		flags.PupilColor = viper.GetString("eye-pupil-color")
+	}
+	if !cmd.Flags().Changed("page-size") && viper.IsSet("page-size") {
+		flags.PageSize = viper.GetString("page-size")
+	}
+	if !cmd.Flags().Changed("physical-size") && viper.IsSet("physical-size") {
+		flags.Physical = viper.GetFloat64("physical-size")
+	}
+	if !cmd.Flags().Changed("caption") && viper.IsSet("caption") {
+		flags.Caption = viper.GetString("caption")
+	}
+	if !cmd.Flags().Changed("caption-position") && viper.IsSet("caption-position") {
+		flags.CaptionPos = viper.GetString("caption-position")
+	}
+	if !cmd.Flags().Changed("font") && viper.IsSet("font") {
+		flags.FontPath = viper.GetString("font")
+	}
+	if !cmd.Flags().Changed("frame") && viper.IsSet("frame") {
+		flags.Frame = viper.GetString("frame")
+	}
 	if !cmd.Flags().Changed("logo") && viper.IsSet("logo") {
 		flags.LogoPath = viper.GetString("logo")
 	}
@@ -148,6 +306,12 @@ func applyOutputConfig(cmd *cobra.Command, flags *OutputFlags) {
 	if !cmd.Flags().Changed("quiet") && viper.IsSet("quiet") {
 		flags.Quiet = viper.GetBool("quiet")
 	}
+	if !cmd.Flags().Changed("split") && viper.IsSet("split") {
+		flags.Split = viper.GetBool("split")
+	}
+	if !cmd.Flags().Changed("verify") && viper.IsSet("verify") {
+		flags.Verify = viper.GetBool("verify")
+	}
 }
 
 func applyWifiConfig(cmd *cobra.Command) {
@@ -193,11 +357,68 @@ func applyBatchConfig(cmd *cobra.Command) {
 	if !cmd.Flags().Changed("file") && viper.IsSet("batch.file") {
 		batchCfg.File = viper.GetString("batch.file")
 	}
+	if !cmd.Flags().Changed("csv") && viper.IsSet("batch.csv") {
+		batchCfg.CSV = viper.GetString("batch.csv")
+	}
+	if !cmd.Flags().Changed("jsonl") && viper.IsSet("batch.jsonl") {
+		batchCfg.JSONL = viper.GetString("batch.jsonl")
+	}
+	if !cmd.Flags().Changed("delimiter") && viper.IsSet("batch.delimiter") {
+		batchCfg.Delimiter = viper.GetString("batch.delimiter")
+	}
+	if !cmd.Flags().Changed("columns") && viper.IsSet("batch.columns") {
+		batchCfg.Columns = viper.GetString("batch.columns")
+	}
+	if !cmd.Flags().Changed("template") && viper.IsSet("batch.template") {
+		batchCfg.Template = viper.GetString("batch.template")
+	}
+	if !cmd.Flags().Changed("name") && viper.IsSet("batch.name") {
+		batchCfg.Name = viper.GetString("batch.name")
+	}
+	if !cmd.Flags().Changed("type") && viper.IsSet("batch.type") {
+		batchCfg.Type = viper.GetString("batch.type")
+	}
+	if !cmd.Flags().Changed("jobs") && viper.IsSet("batch.jobs") {
+		batchCfg.Jobs = viper.GetInt("batch.jobs")
+	}
+	if !cmd.Flags().Changed("logo") && viper.IsSet("batch.logo") {
+		batchCfg.LogoPath = viper.GetString("batch.logo")
+	}
+	if !cmd.Flags().Changed("logo-scale") && viper.IsSet("batch.logo-scale") {
+		batchCfg.LogoScale = viper.GetFloat64("batch.logo-scale")
+	}
+	if !cmd.Flags().Changed("continue-on-error") && viper.IsSet("batch.continue-on-error") {
+		batchCfg.ContinueOnError = viper.GetBool("batch.continue-on-error")
+	}
+	if !cmd.Flags().Changed("errors-file") && viper.IsSet("batch.errors-file") {
+		batchCfg.ErrorsFile = viper.GetString("batch.errors-file")
+	}
+	if !cmd.Flags().Changed("manifest") && viper.IsSet("batch.manifest") {
+		batchCfg.Manifest = viper.GetString("batch.manifest")
+	}
+	if !cmd.Flags().Changed("redact") && viper.IsSet("batch.redact") {
+		batchCfg.Redact = viper.GetBool("batch.redact")
+	}
+	if !cmd.Flags().Changed("redact-key") && viper.IsSet("batch.redact-key") {
+		batchCfg.RedactKey = viper.GetString("batch.redact-key")
+	}
+	if !cmd.Flags().Changed("resume") && viper.IsSet("batch.resume") {
+		batchCfg.Resume = viper.GetBool("batch.resume")
+	}
+	if !cmd.Flags().Changed("incremental") && viper.IsSet("batch.incremental") {
+		batchCfg.Incremental = viper.GetBool("batch.incremental")
+	}
 	if !cmd.Flags().Changed("dir") && viper.IsSet("batch.dir") {
 		batchCfg.Dir = viper.GetString("batch.dir")
 	}
 	if !cmd.Flags().Changed("size") && viper.IsSet("batch.size") {
-		batchCfg.Size = viper.GetInt("batch.size")
+		batchCfg.Size = viper.GetString("batch.size")
+	}
+	if !cmd.Flags().Changed("dpi") && viper.IsSet("batch.dpi") {
+		batchCfg.DPI = viper.GetInt("batch.dpi")
+	}
+	if !cmd.Flags().Changed("fit") && viper.IsSet("batch.fit") {
+		batchCfg.Fit = viper.GetString("batch.fit")
 	}
 	if !cmd.Flags().Changed("format") && viper.IsSet("batch.format") {
 		batchCfg.Format = viper.GetString("batch.format")
@@ -208,6 +429,33 @@ func applyBatchConfig(cmd *cobra.Command) {
 	if !cmd.Flags().Changed("quiet") && viper.IsSet("batch.quiet") {
 		batchCfg.Quiet = viper.GetBool("batch.quiet")
 	}
+	if !cmd.Flags().Changed("split") && viper.IsSet("batch.split") {
+		batchCfg.Split = viper.GetBool("batch.split")
+	}
+	if !cmd.Flags().Changed("verify") && viper.IsSet("batch.verify") {
+		batchCfg.Verify = viper.GetBool("batch.verify")
+	}
+	if !cmd.Flags().Changed("page-size") && viper.IsSet("batch.page-size") {
+		batchCfg.PageSize = viper.GetString("batch.page-size")
+	}
+	if !cmd.Flags().Changed("physical-size") && viper.IsSet("batch.physical-size") {
+		batchCfg.Physical = viper.GetFloat64("batch.physical-size")
+	}
+	if !cmd.Flags().Changed("sheet") && viper.IsSet("batch.sheet") {
+		batchCfg.Sheet = viper.GetString("batch.sheet")
+	}
+	if !cmd.Flags().Changed("caption") && viper.IsSet("batch.caption") {
+		batchCfg.Caption = viper.GetString("batch.caption")
+	}
+}
+
+func applyInspectConfig(cmd *cobra.Command) {
+	if !cmd.Flags().Changed("dpi") && viper.IsSet("inspect.dpi") {
+		inspectDPI = viper.GetInt("inspect.dpi")
+	}
+	if !cmd.Flags().Changed("min-module") && viper.IsSet("inspect.min-module") {
+		inspectMinModule = viper.GetFloat64("inspect.min-module")
+	}
 }
 
 func bindWifiFlags(cmd *cobra.Command) {
@@ -229,9 +477,39 @@ func bindVCardFlags(cmd *cobra.Command) {
 
 func bindBatchFlags(cmd *cobra.Command) {
 	bindFlag(cmd, "batch.file", "file")
+	bindFlag(cmd, "batch.csv", "csv")
+	bindFlag(cmd, "batch.jsonl", "jsonl")
+	bindFlag(cmd, "batch.delimiter", "delimiter")
+	bindFlag(cmd, "batch.columns", "columns")
+	bindFlag(cmd, "batch.template", "template")
+	bindFlag(cmd, "batch.name", "name")
+	bindFlag(cmd, "batch.type", "type")
+	bindFlag(cmd, "batch.jobs", "jobs")
+	bindFlag(cmd, "batch.logo", "logo")
+	bindFlag(cmd, "batch.logo-scale", "logo-scale")
+	bindFlag(cmd, "batch.continue-on-error", "continue-on-error")
+	bindFlag(cmd, "batch.errors-file", "errors-file")
+	bindFlag(cmd, "batch.manifest", "manifest")
+	bindFlag(cmd, "batch.redact", "redact")
+	bindFlag(cmd, "batch.redact-key", "redact-key")
+	bindFlag(cmd, "batch.resume", "resume")
+	bindFlag(cmd, "batch.incremental", "incremental")
 	bindFlag(cmd, "batch.dir", "dir")
 	bindFlag(cmd, "batch.size", "size")
+	bindFlag(cmd, "batch.dpi", "dpi")
+	bindFlag(cmd, "batch.fit", "fit")
 	bindFlag(cmd, "batch.format", "format")
 	bindFlag(cmd, "batch.prefix", "prefix")
 	bindFlag(cmd, "batch.quiet", "quiet")
+	bindFlag(cmd, "batch.split", "split")
+	bindFlag(cmd, "batch.verify", "verify")
+	bindFlag(cmd, "batch.page-size", "page-size")
+	bindFlag(cmd, "batch.physical-size", "physical-size")
+	bindFlag(cmd, "batch.sheet", "sheet")
+	bindFlag(cmd, "batch.caption", "caption")
+}
+
+func bindInspectFlags(cmd *cobra.Command) {
+	bindFlag(cmd, "inspect.dpi", "dpi")
+	bindFlag(cmd, "inspect.min-module", "min-module")
 }
@@ -12,9 +12,9 @@ var (
 	decodeFile string
 
 	decodeCmd = &cobra.Command{
-		Use:   "decode <image>",
-		Short: "Decode QR code(s) from an image",
-		Args:  cobra.MaximumNArgs(1),
+		Use:   "decode <image>...",
+		Short: "Decode QR code(s) from images, joining Structured Append series",
+		Args:  cobra.ArbitraryArgs,
 		RunE:  runDecode,
 	}
 )
@@ -24,23 +24,62 @@ func init() {
 }
 
 func runDecode(cmd *cobra.Command, args []string) error {
-	path := decodeFile
-	if len(args) > 0 {
-		path = args[0]
+	paths := args
+	if decodeFile != "" {
+		paths = append([]string{decodeFile}, paths...)
 	}
-	if path == "" {
+	if len(paths) == 0 {
 		return errors.New("image file is required")
 	}
 
-	results, err := qr.DecodeFile(path)
-	if err != nil {
-		return err
+	// Plain symbols are printed as they are found; Structured Append
+	// parts are grouped by series and printed once reassembled.
+	var lines []string
+	series := make(map[qr.StructuredAppend][]qr.Symbol)
+	var order []qr.StructuredAppend
+	for _, path := range paths {
+		symbols, err := qr.DecodeFileSymbols(path)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		for _, sym := range symbols {
+			if sym.Append.Total == 0 {
+				lines = append(lines, payloadText(sym))
+				continue
+			}
+			key := qr.StructuredAppend{Total: sym.Append.Total, Parity: sym.Append.Parity}
+			if _, ok := series[key]; !ok {
+				order = append(order, key)
+			}
+			series[key] = append(series[key], sym)
+		}
+	}
+
+	for _, key := range order {
+		joined, err := qr.JoinStructuredAppend(series[key])
+		if err != nil {
+			return err
+		}
+		lines = append(lines, joined)
 	}
 
 	out := cmd.OutOrStdout()
-	for _, value := range results {
+	for _, value := range lines {
 		fmt.Fprintln(out, value)
 	}
 
 	return nil
 }
+
+// payloadText shows GS1 element strings in their bracketed form, since
+// the group separators they contain are invisible.
+func payloadText(sym qr.Symbol) string {
+	if !sym.GS1 {
+		return sym.Payload
+	}
+	data, err := qr.ParseGS1ElementString(sym.Payload)
+	if err != nil {
+		return sym.Payload
+	}
+	return data.String()
+}
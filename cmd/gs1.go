@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"github.com/eliaseffects/qr-cli/internal/qr"
+	"github.com/spf13/cobra"
+)
+
+var (
+	gs1Flags OutputFlags
+
+	gs1Cmd = &cobra.Command{
+		Use:   "gs1 [data]",
+		Short: "Generate GS1 QR code from an element string or Digital Link URI",
+		Long: `Generate a GS1 QR code. Application Identifiers are validated, including
+GTIN check digits and dates.
+
+Element strings are encoded behind FNC1 with group separators after
+variable-length fields; Digital Link URIs are encoded as plain text.
+
+Examples:
+  qr gs1 "(01)09506000134352(17)201225(10)ABC123"
+  qr gs1 "https://id.gs1.org/01/09506000134352/10/ABC123?17=201225"`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: runGS1,
+	}
+)
+
+func init() {
+	addOutputFlags(gs1Cmd, &gs1Flags, true)
+	bindOutputFlags(gs1Cmd)
+}
+
+func runGS1(cmd *cobra.Command, args []string) error {
+	applyOutputConfig(cmd, &gs1Flags)
+
+	input := ""
+	if len(args) > 0 {
+		input = args[0]
+	} else {
+		data, err := readStdin()
+		if err != nil {
+			return err
+		}
+		input = data
+	}
+
+	data, err := qr.ParseGS1(input)
+	if err != nil {
+		return err
+	}
+
+	flags := gs1Flags
+	flags.GS1 = data.URI == ""
+	return runGenerate(data.Payload(), flags, cmd.Flags().Changed("format"))
+}
@@ -0,0 +1,318 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/eliaseffects/qr-cli/internal/qr"
+	"github.com/spf13/cobra"
+)
+
+var (
+	inspectFlags     OutputFlags
+	inspectImage     string
+	inspectDPI       int
+	inspectMinModule float64
+	inspectJSON      bool
+
+	inspectCmd = &cobra.Command{
+		Use:   "inspect [data]",
+		Short: "Report symbol metadata for a payload or image",
+		Long: `Report the version, module count, error correction level, mask, encoding
+modes, data capacity and minimum print size of a symbol.
+
+Payloads are encoded with the same flags as generation. Images are decoded
+and report the segments read from each symbol; symbols the native decoder
+cannot read are re-encoded from their payload, and marked as such.
+
+Examples:
+  qr inspect "https://example.com" --level H
+  qr inspect --image code.png --json
+  qr inspect "SN-1234" --symbol micro --dpi 600`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: runInspect,
+	}
+)
+
+func init() {
+	inspectCmd.Flags().StringVar(&inspectImage, "image", "", "Inspect the symbols in an image instead of a payload")
+	inspectCmd.Flags().IntVar(&inspectDPI, "dpi", 300, "Printer resolution for the minimum size")
+	inspectCmd.Flags().Float64Var(&inspectMinModule, "min-module", 0.25, "Smallest module the printer and scanners manage, in millimetres")
+	inspectCmd.Flags().BoolVar(&inspectJSON, "json", false, "Print JSON instead of a table")
+
+	inspectCmd.Flags().StringVar(&inspectFlags.Symbol, "symbol", "qr", "Symbol type: qr, micro (Micro QR), rmqr (rectangular Micro QR)")
+	inspectCmd.Flags().StringVarP(&inspectFlags.Level, "level", "l", "M", "Error correction: L, M, Q, H")
+	inspectCmd.Flags().IntVar(&inspectFlags.Version, "symbol-version", 0, "Version: QR 1-40, Micro QR 1-4 (M1-M4), rMQR 1-32 (0 picks the smallest that fits)")
+	inspectCmd.Flags().StringVar(&inspectFlags.Mask, "mask", "auto", "Mask pattern: auto, 0-7 (0-3 for Micro QR)")
+	inspectCmd.Flags().StringVar(&inspectFlags.Mode, "mode", "auto", "Encoding mode: auto, numeric, alphanumeric, byte, kanji")
+	inspectCmd.Flags().IntVar(&inspectFlags.ECI, "eci", 0, "ECI designator to declare, with data transcoded to its charset (0 for none)")
+	inspectCmd.Flags().StringVar(&inspectFlags.Charset, "charset", "", "Charset to transcode data into and declare via ECI, e.g. ISO-8859-1, Shift_JIS, UTF-8")
+	inspectCmd.Flags().IntVar(&inspectFlags.Border, "border", 4, "Quiet zone in modules (2 by default for micro and rmqr)")
+
+	completeSymbolFlags(inspectCmd)
+	_ = inspectCmd.MarkFlagFilename("image", "png", "jpg", "jpeg", "gif")
+	bindInspectFlags(inspectCmd)
+}
+
+// inspectReport describes one symbol. Sizes are in modules unless the
+// field name says otherwise.
+type inspectReport struct {
+	Source       string   `json:"source,omitempty"`
+	Payload      string   `json:"payload,omitempty"`
+	Symbol       string   `json:"symbol"`
+	Version      string   `json:"version"`
+	Width        int      `json:"width"`
+	Height       int      `json:"height"`
+	Level        string   `json:"level"`
+	Mask         int      `json:"mask"`
+	Modes        []string `json:"modes"`
+	Reencoded    bool     `json:"reencoded,omitempty"`
+	ECI          int      `json:"eci,omitempty"`
+	GS1          bool     `json:"gs1,omitempty"`
+	Append       string   `json:"structured_append,omitempty"`
+	DataBits     int      `json:"data_bits"`
+	CapacityBits int      `json:"capacity_bits"`
+	UsedPercent  float64  `json:"used_percent"`
+	QuietZone    int      `json:"quiet_zone"`
+	DPI          int      `json:"dpi"`
+	ModuleDots   int      `json:"module_dots"`
+	MinWidthMM   float64  `json:"min_width_mm"`
+	MinHeightMM  float64  `json:"min_height_mm"`
+}
+
+func runInspect(cmd *cobra.Command, args []string) error {
+	applyOutputConfig(cmd, &inspectFlags)
+	applyInspectConfig(cmd)
+
+	if inspectDPI <= 0 {
+		return errors.New("dpi must be greater than zero")
+	}
+	if inspectMinModule <= 0 {
+		return errors.New("min-module must be greater than zero")
+	}
+	if inspectFlags.Border < 0 {
+		return errors.New("border size must be zero or positive")
+	}
+
+	opts, err := inspectOptions(inspectFlags)
+	if err != nil {
+		return err
+	}
+
+	if inspectImage != "" {
+		if len(args) > 0 {
+			return errors.New("pass either data or --image, not both")
+		}
+		reports, err := inspectImageFile(inspectImage, opts)
+		if err != nil {
+			return err
+		}
+		if inspectJSON {
+			return writeJSON(cmd.OutOrStdout(), reports)
+		}
+		for i, report := range reports {
+			if i > 0 {
+				fmt.Fprintln(cmd.OutOrStdout())
+			}
+			writeInspectTable(cmd.OutOrStdout(), report)
+		}
+		return nil
+	}
+
+	data := ""
+	if len(args) > 0 {
+		data = args[0]
+	} else {
+		input, err := readStdin()
+		if err != nil {
+			return err
+		}
+		data = input
+	}
+	if strings.TrimSpace(data) == "" {
+		return errors.New("no data provided")
+	}
+
+	code, err := qr.Generate(data, opts)
+	if err != nil {
+		return err
+	}
+	report := newInspectReport(code, opts.BorderSize)
+	if inspectJSON {
+		return writeJSON(cmd.OutOrStdout(), report)
+	}
+	writeInspectTable(cmd.OutOrStdout(), report)
+	return nil
+}
+
+// inspectOptions builds encoder options from the subset of output flags
+// that inspect accepts.
+func inspectOptions(flags OutputFlags) (qr.Options, error) {
+	opts := qr.DefaultOptions()
+
+	symbology, err := parseSymbol(flags.Symbol)
+	if err != nil {
+		return opts, err
+	}
+	opts.Symbology = symbology
+	opts.Level = parseLevel(flags.Level)
+	opts.Version = flags.Version
+
+	if opts.Mask, err = parseMask(flags.Mask); err != nil {
+		return opts, err
+	}
+	if opts.Mode, err = parseMode(flags.Mode); err != nil {
+		return opts, err
+	}
+	if opts.ECI, err = parseECI(flags.ECI, flags.Charset); err != nil {
+		return opts, err
+	}
+
+	opts.BorderSize = flags.Border
+	if !flags.BorderSet {
+		opts.BorderSize = symbology.QuietZone()
+	}
+	return opts, nil
+}
+
+// inspectImageFile decodes every symbol in an image. Sizes come from
+// re-encoding its payload with the version, level and mask read from it,
+// and modes and data bits from the segments it was read from. A symbol
+// the native decoder could not read is reported as re-encoded.
+func inspectImageFile(path string, opts qr.Options) ([]inspectReport, error) {
+	symbols, err := qr.DecodeFileSymbols(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	reports := make([]inspectReport, 0, len(symbols))
+	for _, sym := range symbols {
+		symOpts := opts
+		symOpts.Symbology = qr.SymbolQR
+		symOpts.ECI = sym.ECI
+		symOpts.GS1 = sym.GS1
+		symOpts.Append = sym.Append
+		symOpts.BorderSize = max(opts.BorderSize, qr.SymbolQR.QuietZone())
+		if sym.Version != 0 {
+			symOpts.Version = sym.Version
+			symOpts.Level = sym.Level
+			symOpts.Mask = sym.Mask
+		}
+
+		code, err := qr.Generate(sym.Payload, symOpts)
+		if err != nil {
+			return nil, fmt.Errorf("%s: re-encoding decoded payload: %w", path, err)
+		}
+		report := newInspectReport(code, symOpts.BorderSize)
+		if sym.Version != 0 {
+			report.Modes = modeNames(sym.Modes)
+			report.DataBits, report.CapacityBits = sym.DataBits, sym.CapacityBits
+			report.UsedPercent = usedPercent(sym.DataBits, sym.CapacityBits)
+		} else {
+			report.Reencoded = true
+		}
+		report.Source = path
+		report.Payload = payloadText(sym)
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
+
+func newInspectReport(code *qr.Code, quietZone int) inspectReport {
+	used, capacity := code.DataBits()
+	modes := make([]qr.Mode, len(code.Segments))
+	for i, seg := range code.Segments {
+		modes[i] = seg.Mode
+	}
+
+	report := inspectReport{
+		Symbol:       code.Symbology.String(),
+		Version:      code.VersionName(),
+		Width:        code.Size(),
+		Height:       code.Height(),
+		Level:        code.Level.String(),
+		Mask:         code.Mask,
+		Modes:        modeNames(modes),
+		ECI:          code.ECI,
+		GS1:          code.GS1,
+		DataBits:     used,
+		CapacityBits: capacity,
+		QuietZone:    quietZone,
+		DPI:          inspectDPI,
+		UsedPercent:  usedPercent(used, capacity),
+	}
+	if code.Append.Total > 0 {
+		report.Append = fmt.Sprintf("%d/%d", code.Append.Index+1, code.Append.Total)
+	}
+
+	// Modules are rounded up to whole printer dots so every module prints
+	// at the same size.
+	report.ModuleDots = max(1, int(math.Ceil(inspectMinModule*float64(inspectDPI)/25.4-1e-9)))
+	moduleMM := float64(report.ModuleDots) * 25.4 / float64(inspectDPI)
+	report.MinWidthMM = math.Round(float64(code.Size()+quietZone*2)*moduleMM*10) / 10
+	report.MinHeightMM = math.Round(float64(code.Height()+quietZone*2)*moduleMM*10) / 10
+	return report
+}
+
+// modeNames names segment modes in order, merging runs of the same mode.
+func modeNames(modes []qr.Mode) []string {
+	var names []string
+	for _, mode := range modes {
+		if name := mode.String(); len(names) == 0 || names[len(names)-1] != name {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func usedPercent(used, capacity int) float64 {
+	if capacity <= 0 {
+		return 0
+	}
+	return math.Round(float64(used)*1000/float64(capacity)) / 10
+}
+
+func writeInspectTable(out io.Writer, r inspectReport) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	if r.Source != "" {
+		fmt.Fprintf(w, "Source\t%s\n", r.Source)
+		fmt.Fprintf(w, "Payload\t%s\n", r.Payload)
+	}
+	fmt.Fprintf(w, "Symbol\t%s\n", r.Symbol)
+	fmt.Fprintf(w, "Version\t%s (%dx%d modules)\n", r.Version, r.Width, r.Height)
+	fmt.Fprintf(w, "Level\t%s\n", r.Level)
+	fmt.Fprintf(w, "Mask\t%d\n", r.Mask)
+	reencoded := ""
+	if r.Reencoded {
+		reencoded = " (as re-encoded)"
+	}
+	fmt.Fprintf(w, "Mode\t%s%s\n", strings.Join(r.Modes, ", "), reencoded)
+	if r.ECI != 0 {
+		eci := fmt.Sprint(r.ECI)
+		if c, ok := qr.CharsetForECI(r.ECI); ok {
+			eci += " (" + c.Name + ")"
+		}
+		fmt.Fprintf(w, "ECI\t%s\n", eci)
+	}
+	if r.GS1 {
+		fmt.Fprintf(w, "GS1\tFNC1 in first position\n")
+	}
+	if r.Append != "" {
+		fmt.Fprintf(w, "Structured append\t%s\n", r.Append)
+	}
+	fmt.Fprintf(w, "Data\t%d of %d bits (%.1f%%)%s\n", r.DataBits, r.CapacityBits, r.UsedPercent, reencoded)
+	fmt.Fprintf(w, "Minimum size\t%.1f x %.1f mm at %d dpi (%d dots per module, %d-module quiet zone)\n",
+		r.MinWidthMM, r.MinHeightMM, r.DPI, r.ModuleDots, r.QuietZone)
+	w.Flush()
+}
+
+func writeJSON(out io.Writer, v any) error {
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
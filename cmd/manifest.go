@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/eliaseffects/qr-cli/internal/output"
+)
+
+// manifestEntry describes one generated code in a batch manifest.
+type manifestEntry struct {
+	Index   int    `json:"index"`
+	Line    int    `json:"line"`
+	Part    int    `json:"part"`
+	Payload string `json:"payload"`
+	Path    string `json:"path"`
+	Format  string `json:"format"`
+	Version string `json:"version"`
+	Level   string `json:"level"`
+	Bytes   int    `json:"bytes"`
+}
+
+var manifestColumns = []string{"index", "line", "part", "payload", "path", "format", "version", "level", "bytes"}
+
+// manifestFormat picks CSV or JSON from the extension of the manifest path.
+func manifestFormat(path string) (string, error) {
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".csv", ".json":
+		return ext[1:], nil
+	default:
+		return "", fmt.Errorf("unsupported manifest file: %s (want .csv or .json)", path)
+	}
+}
+
+// redactPayload returns the SHA-256 of a payload, which identifies it
+// without listing it. Anyone who can guess a payload, such as a short
+// token or a sequential ID, can confirm it against a plain hash, so with a
+// key it returns an HMAC-SHA256 instead.
+func redactPayload(data, key string) string {
+	if key == "" {
+		return "sha256:" + contentHash([]byte(data))
+	}
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(data))
+	return "hmac-sha256:" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// writeManifest lists outputs, in order, as a CSV table or JSON array,
+// with each payload redacted under key when redact is set.
+func writeManifest(path string, outputs []batchOutput, redact bool, key string) error {
+	format, err := manifestFormat(path)
+	if err != nil {
+		return err
+	}
+
+	entries := make([]manifestEntry, len(outputs))
+	for i, out := range outputs {
+		payload := out.Data
+		if redact {
+			payload = redactPayload(out.Data, key)
+		}
+		entries[i] = manifestEntry{
+			Index:   out.Index,
+			Line:    out.Line,
+			Part:    out.Part,
+			Payload: payload,
+			Path:    out.Path,
+			Format:  strings.TrimPrefix(filepath.Ext(out.Path), "."),
+			Version: out.Version,
+			Level:   out.Level.String(),
+			Bytes:   out.Bytes,
+		}
+	}
+
+	var buf bytes.Buffer
+	if format == "json" {
+		enc := json.NewEncoder(&buf)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(entries); err != nil {
+			return err
+		}
+	} else {
+		w := csv.NewWriter(&buf)
+		_ = w.Write(manifestColumns)
+		for _, e := range entries {
+			_ = w.Write([]string{
+				strconv.Itoa(e.Index), strconv.Itoa(e.Line), strconv.Itoa(e.Part), e.Payload, e.Path,
+				e.Format, e.Version, e.Level, strconv.Itoa(e.Bytes),
+			})
+		}
+		w.Flush()
+		if err := w.Error(); err != nil {
+			return err
+		}
+	}
+	return output.WriteFile(path, buf.Bytes())
+}
@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/eliaseffects/qr-cli/internal/qr"
+)
+
+var manifestOutputs = []batchOutput{
+	{Index: 1, Line: 2, Part: 1, Path: "out/a.png", Data: "https://ex.com/a", Bytes: 812, Version: "2", Level: qr.Medium},
+	{Index: 2, Line: 3, Part: 1, Path: "out/b-1.svg", Data: "x,\"y\"", Bytes: 4096, Version: "M3", Level: qr.Low},
+	{Index: 2, Line: 3, Part: 2, Path: "out/b-2.svg", Data: "z", Bytes: 4000, Version: "M1", Level: qr.Low},
+}
+
+func TestWriteManifestCSV(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "manifest.csv")
+	if err := writeManifest(path, manifestOutputs, false, ""); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Join([]string{
+		"index,line,part,payload,path,format,version,level,bytes",
+		"1,2,1,https://ex.com/a,out/a.png,png,2,M,812",
+		`2,3,1,"x,""y""",out/b-1.svg,svg,M3,L,4096`,
+		"2,3,2,z,out/b-2.svg,svg,M1,L,4000",
+	}, "\n") + "\n"
+	if string(got) != want {
+		t.Fatalf("manifest:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestWriteManifestJSON(t *testing.T) {
+	for _, tt := range []struct {
+		redact bool
+		key    string
+	}{{false, ""}, {true, ""}, {true, "secret"}} {
+		redact := tt.redact
+		path := filepath.Join(t.TempDir(), "manifest.json")
+		if err := writeManifest(path, manifestOutputs, redact, tt.key); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var entries []manifestEntry
+		if err := json.Unmarshal(data, &entries); err != nil {
+			t.Fatalf("manifest is not a JSON array: %v\n%s", err, data)
+		}
+		if len(entries) != len(manifestOutputs) {
+			t.Fatalf("got %d entries, want %d", len(entries), len(manifestOutputs))
+		}
+		for i, e := range entries {
+			out := manifestOutputs[i]
+			payload := out.Data
+			if redact {
+				payload = redactPayload(out.Data, tt.key)
+			}
+			want := manifestEntry{
+				Index: out.Index, Line: out.Line, Part: out.Part, Payload: payload, Path: out.Path,
+				Format: strings.TrimPrefix(filepath.Ext(out.Path), "."), Version: out.Version,
+				Level: out.Level.String(), Bytes: out.Bytes,
+			}
+			if e != want {
+				t.Errorf("redact %v, entry %d = %+v, want %+v", redact, i+1, e, want)
+			}
+		}
+		if redact && strings.Contains(string(data), "ex.com") {
+			t.Errorf("redacted manifest still holds a payload:\n%s", data)
+		}
+	}
+}
+
+func TestRedactPayload(t *testing.T) {
+	tests := []struct {
+		data, key, want string
+	}{
+		// SHA-256 of "abc".
+		{"abc", "", "sha256:ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+		// RFC 4231 test case 2.
+		{"what do ya want for nothing?", "Jefe", "hmac-sha256:5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843"},
+	}
+	for _, tt := range tests {
+		if got := redactPayload(tt.data, tt.key); got != tt.want {
+			t.Errorf("redactPayload(%q, %q) = %s, want %s", tt.data, tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestManifestFormat(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"m.csv", "csv"},
+		{"out/M.JSON", "json"},
+		{"m.txt", ""},
+		{"manifest", ""},
+	}
+	for _, tt := range tests {
+		got, err := manifestFormat(tt.path)
+		if got != tt.want || (err == nil) != (tt.want != "") {
+			t.Errorf("manifestFormat(%q) = %q, %v, want %q", tt.path, got, err, tt.want)
+		}
+	}
+}
@@ -2,11 +2,13 @@ package cmd
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"strings"
 
+	"github.com/eliaseffects/qr-cli/internal/qr"
 	"github.com/spf13/cobra"
 )
 
@@ -24,7 +26,8 @@ Examples:
   qr "Hello world" -o hello.png         # Custom output path
   echo "secret" | qr -o secret.png      # Read from stdin
   qr "https://example.com" --terminal   # Render in terminal
-  qr "https://example.com" --open       # Open in viewer`,
+  qr "https://example.com" --open       # Open in viewer
+  qr "$(cat big.txt)" --split           # Spread across qr-1.png, qr-2.png, ...`,
 		Args: cobra.MaximumNArgs(1),
 		RunE: runRoot,
 	}
@@ -32,6 +35,7 @@ Examples:
 
 func init() {
 	addOutputFlags(rootCmd, &rootFlags, true)
+	rootCmd.Flags().BoolVar(&rootFlags.Split, "split", false, "Split data too long for one symbol into a Structured Append series")
 	rootCmd.Flags().BoolVarP(&showVersion, "version", "v", false, "Print version")
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "Config file path")
 	cobra.OnInitialize(initConfig)
@@ -46,8 +50,10 @@ func init() {
 
 	rootCmd.AddCommand(wifiCmd)
 	rootCmd.AddCommand(vcardCmd)
+	rootCmd.AddCommand(gs1Cmd)
 	rootCmd.AddCommand(batchCmd)
 	rootCmd.AddCommand(decodeCmd)
+	rootCmd.AddCommand(inspectCmd)
 	rootCmd.AddCommand(versionCmd)
 }
 
@@ -70,7 +76,13 @@ func runRoot(cmd *cobra.Command, args []string) error {
 		data = input
 	}
 
-	return runGenerate(data, rootFlags, cmd.Flags().Changed("format"))
+	err := runGenerate(data, rootFlags, cmd.Flags().Changed("format"))
+	// Only full QR symbols can be split, so only they get the hint.
+	symbol, _ := parseSymbol(rootFlags.Symbol)
+	if errors.Is(err, qr.ErrDataTooLong) && !rootFlags.Split && symbol == qr.SymbolQR {
+		return fmt.Errorf("%w (use --split to spread it across several symbols)", err)
+	}
+	return err
 }
 
 func readStdin() (string, error) {
@@ -82,6 +94,24 @@ func readStdin() (string, error) {
 	return strings.TrimSpace(string(input)), nil
 }
 
+// ExitPartialFailure is the exit status of a batch run that skipped
+// failing rows with --continue-on-error.
+const ExitPartialFailure = 3
+
+// ExitError is an error that asks for a particular exit status.
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
 // Execute runs the root command.
 func Execute() error {
 	return rootCmd.Execute()
@@ -1,26 +1,55 @@
 package cmd
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"image/color"
+	"image/png"
+	"math"
+	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/eliaseffects/qr-cli/internal/output"
 	"github.com/eliaseffects/qr-cli/internal/qr"
-	qrcode "github.com/skip2/go-qrcode"
 	"github.com/spf13/cobra"
+	"golang.org/x/image/colornames"
 )
 
 type OutputFlags struct {
 	OutputPath string
-	Size       int
+	Size       string
+	DPI        int
+	Fit        string
 	Format     string
+	Symbol     string
 	Level      string
+	Version    int
+	Mask       string
+	Mode       string
+	ECI        int
+	Charset    string
 	FgColor    string
 	BgColor    string
 	Border     int
+	BorderSet  bool
+	ModStyle   string
+	SVGGeom    string
+	EyeFrame   string
+	EyePupil   string
+	EyeColor   string
+	PupilColor string
+	Gradient   string
+	GradType   string
+	GradAngle  float64
+	PageSize   string
+	Physical   float64
+	Caption    string
+	CaptionPos string
+	FontPath   string
+	Frame      string
 	LogoPath   string
 	LogoScale  float64
 	Invert     bool
@@ -29,16 +58,42 @@ type OutputFlags struct {
 	OpenViewer bool
 	CopyClip   bool
 	Quiet      bool
+	Split      bool
+	Verify     bool
+	GS1        bool
 }
 
 func addOutputFlags(cmd *cobra.Command, flags *OutputFlags, includeTerminal bool) {
 	cmd.Flags().StringVarP(&flags.OutputPath, "output", "o", "", "Output file path")
-	cmd.Flags().IntVarP(&flags.Size, "size", "s", 256, "Image size in pixels")
-	cmd.Flags().StringVarP(&flags.Format, "format", "f", "png", "Output format: png, svg, terminal")
+	cmd.Flags().StringVarP(&flags.Size, "size", "s", "256", "Image size in pixels, or a print size in mm, cm, in or pt (e.g. 25mm)")
+	cmd.Flags().IntVar(&flags.DPI, "dpi", 0, "Print resolution for PNG pixels and metadata (default 300 with a print --size)")
+	cmd.Flags().StringVar(&flags.Fit, "fit", "pad", "How PNG output meets --size: pad, exact, round-up, round-down")
+	cmd.Flags().StringVarP(&flags.Format, "format", "f", "png", "Output format: png, svg, pdf, eps, terminal")
+	cmd.Flags().StringVar(&flags.Symbol, "symbol", "qr", "Symbol type: qr, micro (Micro QR), rmqr (rectangular Micro QR)")
 	cmd.Flags().StringVarP(&flags.Level, "level", "l", "M", "Error correction: L, M, Q, H")
-	cmd.Flags().StringVar(&flags.FgColor, "fg", "#000000", "Foreground color (hex)")
-	cmd.Flags().StringVar(&flags.BgColor, "bg", "#ffffff", "Background color (hex)")
-	cmd.Flags().IntVar(&flags.Border, "border", 4, "Border size in modules")
+	cmd.Flags().IntVar(&flags.Version, "symbol-version", 0, "Version: QR 1-40, Micro QR 1-4 (M1-M4), rMQR 1-32 (0 picks the smallest that fits)")
+	cmd.Flags().StringVar(&flags.Mask, "mask", "auto", "Mask pattern: auto, 0-7 (0-3 for Micro QR)")
+	cmd.Flags().StringVar(&flags.Mode, "mode", "auto", "Encoding mode: auto, numeric, alphanumeric, byte, kanji")
+	cmd.Flags().IntVar(&flags.ECI, "eci", 0, "ECI designator to declare, with data transcoded to its charset (0 for none)")
+	cmd.Flags().StringVar(&flags.Charset, "charset", "", "Charset to transcode data into and declare via ECI, e.g. ISO-8859-1, Shift_JIS, UTF-8")
+	cmd.Flags().StringVar(&flags.FgColor, "fg", "#000000", "Foreground color: #RRGGBB, #RRGGBBAA, cmyk(C,M,Y,K) or a CSS color name")
+	cmd.Flags().StringVar(&flags.BgColor, "bg", "#ffffff", "Background color: #RRGGBB, #RRGGBBAA, cmyk(C,M,Y,K), a CSS color name or transparent")
+	cmd.Flags().StringVar(&flags.Gradient, "fg-gradient", "", "Foreground gradient as comma-separated colors, e.g. \"#0b3d91,#c0392b\", replacing --fg")
+	cmd.Flags().StringVar(&flags.GradType, "gradient-type", "linear", "Gradient type: linear, radial")
+	cmd.Flags().Float64Var(&flags.GradAngle, "gradient-angle", 0, "Linear gradient direction in degrees, clockwise from left-to-right")
+	cmd.Flags().IntVar(&flags.Border, "border", 4, "Border size in modules (2 by default for micro and rmqr)")
+	cmd.Flags().StringVar(&flags.ModStyle, "module-style", "square", "Module shape: square, dot, rounded, vertical-bars, horizontal-bars, connected")
+	cmd.Flags().StringVar(&flags.SVGGeom, "svg-geometry", "path", "SVG square modules as one merged path, <use> references or rects: path, use, rects")
+	cmd.Flags().StringVar(&flags.EyeFrame, "eye-frame", "square", "Finder pattern frame shape: square, rounded, circle")
+	cmd.Flags().StringVar(&flags.EyePupil, "eye-pupil", "square", "Finder pattern pupil shape: square, rounded, circle")
+	cmd.Flags().StringVar(&flags.EyeColor, "eye-color", "", "Finder pattern color (hex, defaults to --fg)")
+	cmd.Flags().StringVar(&flags.PupilColor, "eye-pupil-color", "", "Finder pattern pupil color (hex, defaults to --eye-color)")
+	cmd.Flags().StringVar(&flags.PageSize, "page-size", "", "PDF page: a4, a5, a6, letter, legal or WIDTHxHEIGHT in mm (default: fit the symbol)")
+	cmd.Flags().Float64Var(&flags.Physical, "physical-size", 0, "Symbol width in mm, quiet zone included, for PDF, EPS and SVG (default: --size)")
+	cmd.Flags().StringVar(&flags.Caption, "caption", "", "Caption drawn below or above the code, e.g. \"Scan to join Wi-Fi\"")
+	cmd.Flags().StringVar(&flags.CaptionPos, "caption-position", "bottom", "Caption position: top, bottom")
+	cmd.Flags().StringVar(&flags.FontPath, "font", "", "TrueType or OpenType font file for the caption (default: Go Regular)")
+	cmd.Flags().StringVar(&flags.Frame, "frame", "none", "Frame around the code and caption: none, square, rounded, banner")
 	cmd.Flags().StringVar(&flags.LogoPath, "logo", "", "Path to logo image to overlay")
 	cmd.Flags().Float64Var(&flags.LogoScale, "logo-scale", 0.2, "Logo size as fraction of QR (0.05-0.4)")
 	cmd.Flags().BoolVar(&flags.Invert, "invert", false, "Invert terminal rendering colors")
@@ -49,6 +104,8 @@ func addOutputFlags(cmd *cobra.Command, flags *OutputFlags, includeTerminal bool
 	cmd.Flags().BoolVar(&flags.OpenViewer, "open", false, "Open in system viewer")
 	cmd.Flags().BoolVar(&flags.CopyClip, "copy", false, "Copy to clipboard")
 	cmd.Flags().BoolVarP(&flags.Quiet, "quiet", "q", false, "Suppress non-error output")
+	cmd.Flags().BoolVar(&flags.Verify, "verify", false, "Decode the rendered code and fail if it does not read back as the input")
+	completeOutputFlags(cmd)
 }
 
 func runGenerate(data string, flags OutputFlags, formatSet bool) error {
@@ -68,6 +125,10 @@ func runGenerate(data string, flags OutputFlags, formatSet bool) error {
 			format = "svg"
 		case ".png":
 			format = "png"
+		case ".pdf":
+			format = "pdf"
+		case ".eps":
+			format = "eps"
 		}
 	}
 
@@ -75,55 +136,125 @@ func runGenerate(data string, flags OutputFlags, formatSet bool) error {
 		return errors.New("terminal color/invert requires --terminal or --format terminal")
 	}
 
+	if !flags.Quiet {
+		for _, warning := range append(alphaWarnings(opts), gradientWarnings(opts)...) {
+			fmt.Fprintf(os.Stderr, "warning: %s\n", warning)
+		}
+	}
+
+	if flags.Verify && opts.Symbology != qr.SymbolQR {
+		return fmt.Errorf("--verify is not available for %s symbols, which the decoder cannot read", opts.Symbology)
+	}
+
+	parts := []qr.Part{{Data: data}}
+	if flags.Split {
+		parts, err = qr.Split(data, opts)
+		if err != nil {
+			return err
+		}
+	}
+
 	if flags.Terminal || format == "terminal" {
 		if flags.LogoPath != "" {
 			return errors.New("logo overlay is not supported for terminal rendering")
 		}
+		if opts.ModuleStyle != qr.StyleSquare || opts.EyeFrame != qr.EyeSquare || opts.EyePupil != qr.EyeSquare ||
+			opts.EyeFrameColor != nil || opts.EyePupilColor != nil {
+			return errors.New("module and eye styles are not supported for terminal rendering")
+		}
+		if opts.ForegroundGradient != nil {
+			return errors.New("gradients are not supported for terminal rendering")
+		}
+		if opts.Caption != "" || opts.Frame != qr.FrameNone {
+			return errors.New("captions and frames are not supported for terminal rendering")
+		}
 		if flags.CopyClip {
 			return errors.New("clipboard output is not supported for terminal rendering")
 		}
-		result, err := output.ToTerminal(data, opts, output.TerminalOptions{
-			UseColor: flags.TermColor,
-			Invert:   flags.Invert,
-		})
-		if err != nil {
-			return err
+		for i, part := range parts {
+			if flags.Verify {
+				if err := verifyRendered(part.Data, part.Options(opts), "terminal", nil); err != nil {
+					return err
+				}
+			}
+			result, err := output.ToTerminal(part.Data, part.Options(opts), output.TerminalOptions{
+				UseColor: flags.TermColor,
+				Invert:   flags.Invert,
+			})
+			if err != nil {
+				return err
+			}
+			if i > 0 {
+				fmt.Println()
+			}
+			fmt.Print(result)
 		}
-		fmt.Print(result)
 		return nil
 	}
 
 	switch format {
-	case "png", "svg":
+	case "png", "svg", "pdf", "eps":
 	default:
 		return fmt.Errorf("unsupported format: %s", flags.Format)
 	}
 
+	if flags.CopyClip && (format == "pdf" || format == "eps") {
+		return fmt.Errorf("clipboard output is not supported for %s", strings.ToUpper(format))
+	}
+
+	if flags.CopyClip && len(parts) > 1 {
+		return errors.New("clipboard output is not supported for split symbols")
+	}
+
 	outPath := flags.OutputPath
 	if outPath == "" {
-		if format == "svg" {
-			outPath = "qr.svg"
-		} else {
-			outPath = "qr.png"
-		}
+		outPath = "qr." + format
 	}
 
-	var payload []byte
-	if format == "svg" {
-		payload, err = qr.SVG(data, opts)
-	} else {
-		payload, err = qr.PNG(data, opts)
-	}
-	if err != nil {
-		return err
+	paths := []string{outPath}
+	if len(parts) > 1 {
+		paths = paths[:0]
+		for i := range parts {
+			paths = append(paths, numberedPath(outPath, i+1))
+		}
 	}
 
-	if err := output.WriteFile(outPath, payload); err != nil {
-		return err
+	var payload []byte
+	for i, part := range parts {
+		payload, err = render(part.Data, part.Options(opts), format)
+		if err != nil {
+			return err
+		}
+		if flags.Verify {
+			if err := verifyRendered(part.Data, part.Options(opts), format, payload); err != nil {
+				return err
+			}
+		}
+		if err := output.WriteFile(paths[i], payload); err != nil {
+			return err
+		}
 	}
 
 	if !flags.Quiet {
-		fmt.Printf("✓ QR code saved to %s\n", outPath)
+		if len(parts) > 1 {
+			fmt.Printf("✓ QR code split into %d symbols: %s … %s\n", len(parts), paths[0], paths[len(paths)-1])
+		} else {
+			fmt.Printf("✓ QR code saved to %s\n", outPath)
+		}
+		if format == "png" {
+			if w, h, err := qr.PNGSize(parts[0].Data, parts[0].Options(opts)); err == nil && max(w, h) != opts.Size {
+				fmt.Printf("  image is %dx%d px", w, h)
+				if opts.DPI > 0 {
+					fmt.Printf(", %.1f mm wide at %d dpi", float64(w)/float64(opts.DPI)*25.4, opts.DPI)
+				}
+				fmt.Println()
+			}
+		}
+		if opts.LogoPath != "" {
+			if code, err := qr.Generate(parts[0].Data, parts[0].Options(opts)); err == nil && code.Level != opts.Level {
+				fmt.Printf("  error correction raised from %s to %s to cover the logo\n", opts.Level, code.Level)
+			}
+		}
 	}
 
 	if flags.CopyClip {
@@ -139,25 +270,202 @@ func runGenerate(data string, flags OutputFlags, formatSet bool) error {
 	}
 
 	if flags.OpenViewer {
-		if err := output.OpenInViewer(outPath); err != nil {
-			return fmt.Errorf("failed to open viewer: %w", err)
+		for _, path := range paths {
+			if err := output.OpenInViewer(path); err != nil {
+				return fmt.Errorf("failed to open viewer: %w", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// render encodes data in a file format supported by runGenerate and batch.
+func render(data string, opts qr.Options, format string) ([]byte, error) {
+	switch format {
+	case "svg":
+		return qr.SVG(data, opts)
+	case "pdf":
+		return qr.PDF(data, opts)
+	case "eps":
+		return qr.EPS(data, opts)
+	}
+	return qr.PNG(data, opts)
+}
+
+// verifyRendered checks that a rendered code reads back as data. PNG
+// output is decoded as written; other formats are checked through a PNG
+// rendered with the same options.
+func verifyRendered(data string, opts qr.Options, format string, rendered []byte) error {
+	if err := checkContrast(opts); err != nil {
+		return err
+	}
+
+	pngData := rendered
+	if format != "png" {
+		var err error
+		if pngData, err = qr.PNG(data, opts); err != nil {
+			return err
 		}
 	}
+	img, err := png.Decode(bytes.NewReader(pngData))
+	if err != nil {
+		return fmt.Errorf("verification failed: %w", err)
+	}
+
+	results, err := qr.DecodeImage(img)
+	if err != nil {
+		hint := ""
+		if opts.LogoPath != "" {
+			hint = "; try a smaller --logo-scale or a higher --level"
+		} else if qr.Contrast(opts.ForegroundColor, opts.BackgroundColor) < 0 {
+			hint = "; --fg is lighter than --bg"
+		}
+		return fmt.Errorf("verification failed: the rendered code could not be decoded (%v)%s", err, hint)
+	}
+	for _, result := range results {
+		if result == data {
+			return nil
+		}
+	}
+	return fmt.Errorf("verification failed: decoded %q, want %q", results[0], data)
+}
+
+// namedColor is a colour drawn as dark modules, with the flag that set it.
+type namedColor struct {
+	name string
+	c    color.Color
+}
+
+// darkColors lists every colour drawn as dark modules: --fg or the
+// gradient stops, then any eye colours.
+func darkColors(opts qr.Options) []namedColor {
+	darks := []namedColor{{"--fg", opts.ForegroundColor}}
+	if g := opts.ForegroundGradient; g != nil {
+		darks = darks[:0]
+		for i, stop := range g.Stops {
+			darks = append(darks, namedColor{fmt.Sprintf("--fg-gradient stop %d", i+1), stop})
+		}
+	}
+	if opts.EyeFrameColor != nil {
+		darks = append(darks, namedColor{"--eye-color", opts.EyeFrameColor})
+	}
+	if opts.EyePupilColor != nil {
+		darks = append(darks, namedColor{"--eye-pupil-color", opts.EyePupilColor})
+	}
+	return darks
+}
+
+// darkContrast returns the contrast of each dark colour against --bg.
+// Inverted codes are allowed, but all dark colours must lie on the same
+// side of the background, so the sign follows the first of them.
+func darkContrast(opts qr.Options) ([]namedColor, []float64) {
+	darks := darkColors(opts)
+	contrasts := make([]float64, len(darks))
+	inverted := qr.Contrast(darks[0].c, opts.BackgroundColor) < 0
+	for i, d := range darks {
+		contrasts[i] = qr.Contrast(d.c, opts.BackgroundColor)
+		if inverted {
+			contrasts[i] = -contrasts[i]
+		}
+	}
+	return darks, contrasts
+}
 
+// checkContrast holds every dark colour, the gradient stops and eye
+// colours included, to the contrast scanners need against --bg.
+func checkContrast(opts qr.Options) error {
+	darks, contrasts := darkContrast(opts)
+	for i, d := range darks {
+		if contrasts[i] < qr.MinContrast {
+			return fmt.Errorf("verification failed: contrast between %s and --bg is %.0f%%, below the %.0f%% scanners need",
+				d.name, max(contrasts[i], 0)*100, qr.MinContrast*100)
+		}
+	}
 	return nil
 }
 
+// alphaWarnings explains how translucent colours weaken the code. A
+// translucent background leaves contrast to whatever the code is placed
+// over; a translucent dark colour blends into the background.
+func alphaWarnings(opts qr.Options) []string {
+	var warnings []string
+	if alpha(opts.BackgroundColor) < 0xff {
+		warnings = append(warnings, "--bg is translucent, so contrast depends on what the code is placed over; "+
+			"check it against the final artwork")
+	}
+	darks, contrasts := darkContrast(opts)
+	for i, d := range darks {
+		if alpha(d.c) < 0xff && contrasts[i] < qr.MinContrast {
+			warnings = append(warnings, fmt.Sprintf("%s is translucent and keeps only %.0f%% contrast against --bg, below the %.0f%% scanners need",
+				d.name, max(contrasts[i], 0)*100, qr.MinContrast*100))
+		}
+	}
+	return warnings
+}
+
+// gradientWarnings names the opaque gradient stops too close to --bg for
+// scanners to tell apart; alphaWarnings covers translucent ones.
+func gradientWarnings(opts qr.Options) []string {
+	g := opts.ForegroundGradient
+	if g == nil {
+		return nil
+	}
+	var warnings []string
+	darks, contrasts := darkContrast(opts)
+	for i, d := range darks[:len(g.Stops)] {
+		if alpha(d.c) == 0xff && contrasts[i] < qr.MinContrast {
+			warnings = append(warnings, fmt.Sprintf("%s keeps only %.0f%% contrast against --bg, below the %.0f%% scanners need",
+				d.name, max(contrasts[i], 0)*100, qr.MinContrast*100))
+		}
+	}
+	return warnings
+}
+
+func alpha(c color.Color) uint8 {
+	return color.NRGBAModel.Convert(c).(color.NRGBA).A
+}
+
+// numberedPath inserts a 1-based symbol number before the extension, so
+// qr.png becomes qr-1.png, qr-2.png and so on.
+func numberedPath(path string, n int) string {
+	ext := filepath.Ext(path)
+	return fmt.Sprintf("%s-%d%s", strings.TrimSuffix(path, ext), n, ext)
+}
+
 func (flags OutputFlags) toOptions() (qr.Options, error) {
 	opts := qr.DefaultOptions()
-	if flags.Size <= 0 {
-		return opts, errors.New("size must be greater than zero")
-	}
 	if flags.Border < 0 {
 		return opts, errors.New("border size must be zero or positive")
 	}
 
-	opts.Size = flags.Size
+	symbology, err := parseSymbol(flags.Symbol)
+	if err != nil {
+		return opts, err
+	}
+
+	opts.Symbology = symbology
 	opts.Level = parseLevel(flags.Level)
+	opts.Version = flags.Version
+
+	mask, err := parseMask(flags.Mask)
+	if err != nil {
+		return opts, err
+	}
+	opts.Mask = mask
+
+	mode, err := parseMode(flags.Mode)
+	if err != nil {
+		return opts, err
+	}
+	opts.Mode = mode
+
+	eci, err := parseECI(flags.ECI, flags.Charset)
+	if err != nil {
+		return opts, err
+	}
+	opts.ECI = eci
+	opts.GS1 = flags.GS1
 
 	fg, err := parseColor(flags.FgColor)
 	if err != nil {
@@ -170,38 +478,427 @@ func (flags OutputFlags) toOptions() (qr.Options, error) {
 
 	opts.ForegroundColor = fg
 	opts.BackgroundColor = bg
+	if opts.ForegroundGradient, err = parseGradient(flags.Gradient, flags.GradType, flags.GradAngle); err != nil {
+		return opts, err
+	}
 	opts.BorderSize = flags.Border
+	if !flags.BorderSet {
+		opts.BorderSize = symbology.QuietZone()
+	}
 	opts.LogoPath = strings.TrimSpace(flags.LogoPath)
 	opts.LogoScale = flags.LogoScale
 
+	opts.Caption = strings.TrimSpace(flags.Caption)
+	opts.FontPath = strings.TrimSpace(flags.FontPath)
+	if opts.CaptionPosition, err = parseCaptionPosition(flags.CaptionPos); err != nil {
+		return opts, err
+	}
+	if opts.Frame, err = parseFrame(flags.Frame); err != nil {
+		return opts, err
+	}
+
+	style, err := parseModuleStyle(flags.ModStyle)
+	if err != nil {
+		return opts, err
+	}
+	opts.ModuleStyle = style
+	if opts.SVGGeometry, err = parseSVGGeometry(flags.SVGGeom); err != nil {
+		return opts, err
+	}
+
+	if opts.EyeFrame, err = parseEyeShape(flags.EyeFrame); err != nil {
+		return opts, err
+	}
+	if opts.EyePupil, err = parseEyeShape(flags.EyePupil); err != nil {
+		return opts, err
+	}
+	if strings.TrimSpace(flags.EyeColor) != "" {
+		if opts.EyeFrameColor, err = parseColor(flags.EyeColor); err != nil {
+			return opts, err
+		}
+	}
+	if strings.TrimSpace(flags.PupilColor) != "" {
+		if opts.EyePupilColor, err = parseColor(flags.PupilColor); err != nil {
+			return opts, err
+		}
+	}
+
+	if err := applySize(&opts, flags.Size, flags.DPI, flags.Physical); err != nil {
+		return opts, err
+	}
+	if opts.Fit, err = parseFit(flags.Fit); err != nil {
+		return opts, err
+	}
+	if opts.PageWidth, opts.PageHeight, err = parsePageSize(flags.PageSize); err != nil {
+		return opts, err
+	}
+
+	if opts.LogoPath != "" && symbology != qr.SymbolQR {
+		return opts, fmt.Errorf("logo overlay is not supported for %s symbols", symbology)
+	}
+
 	return opts, nil
 }
 
-func parseLevel(s string) qrcode.RecoveryLevel {
+func parseSymbol(s string) (qr.Symbology, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "qr":
+		return qr.SymbolQR, nil
+	case "micro", "microqr":
+		return qr.SymbolMicro, nil
+	case "rmqr":
+		return qr.SymbolRMQR, nil
+	default:
+		return qr.SymbolQR, fmt.Errorf("invalid symbol: %s (want qr, micro or rmqr)", s)
+	}
+}
+
+func parseModuleStyle(s string) (qr.ModuleStyle, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "square":
+		return qr.StyleSquare, nil
+	case "dot", "dots":
+		return qr.StyleDot, nil
+	case "rounded":
+		return qr.StyleRounded, nil
+	case "vertical-bars":
+		return qr.StyleVerticalBars, nil
+	case "horizontal-bars":
+		return qr.StyleHorizontalBars, nil
+	case "connected":
+		return qr.StyleConnected, nil
+	default:
+		return qr.StyleSquare, fmt.Errorf("invalid module style: %s (want square, dot, rounded, vertical-bars, horizontal-bars or connected)", s)
+	}
+}
+
+func parseSVGGeometry(s string) (qr.SVGGeometry, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "path":
+		return qr.SVGPath, nil
+	case "use":
+		return qr.SVGUse, nil
+	case "rects":
+		return qr.SVGRects, nil
+	default:
+		return qr.SVGPath, fmt.Errorf("invalid SVG geometry: %s (want path, use or rects)", s)
+	}
+}
+
+func parseCaptionPosition(s string) (qr.CaptionPosition, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "bottom":
+		return qr.CaptionBottom, nil
+	case "top":
+		return qr.CaptionTop, nil
+	default:
+		return qr.CaptionBottom, fmt.Errorf("invalid caption position: %s (want top or bottom)", s)
+	}
+}
+
+func parseFrame(s string) (qr.Frame, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "none":
+		return qr.FrameNone, nil
+	case "square":
+		return qr.FrameSquare, nil
+	case "rounded":
+		return qr.FrameRounded, nil
+	case "banner":
+		return qr.FrameBanner, nil
+	default:
+		return qr.FrameNone, fmt.Errorf("invalid frame: %s (want none, square, rounded or banner)", s)
+	}
+}
+
+func parseFit(s string) (qr.Fit, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "pad":
+		return qr.FitPad, nil
+	case "exact":
+		return qr.FitExact, nil
+	case "round-up":
+		return qr.FitRoundUp, nil
+	case "round-down":
+		return qr.FitRoundDown, nil
+	default:
+		return qr.FitPad, fmt.Errorf("invalid fit: %s (want pad, exact, round-up or round-down)", s)
+	}
+}
+
+func parseEyeShape(s string) (qr.EyeShape, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "square":
+		return qr.EyeSquare, nil
+	case "rounded":
+		return qr.EyeRounded, nil
+	case "circle":
+		return qr.EyeCircle, nil
+	default:
+		return qr.EyeSquare, fmt.Errorf("invalid eye shape: %s (want square, rounded or circle)", s)
+	}
+}
+
+// parseGradient builds a gradient from --fg-gradient and its type and
+// angle; an empty list of colors means a solid --fg.
+func parseGradient(colors, typ string, angle float64) (*qr.Gradient, error) {
+	if strings.TrimSpace(colors) == "" {
+		return nil, nil
+	}
+
+	g := &qr.Gradient{Angle: angle}
+	switch strings.ToLower(strings.TrimSpace(typ)) {
+	case "", "linear":
+		g.Type = qr.GradientLinear
+	case "radial":
+		g.Type = qr.GradientRadial
+	default:
+		return nil, fmt.Errorf("invalid gradient type: %s (want linear or radial)", typ)
+	}
+
+	for _, stop := range splitColors(colors) {
+		c, err := parseColor(stop)
+		if err != nil {
+			return nil, err
+		}
+		g.Stops = append(g.Stops, c)
+	}
+	if len(g.Stops) < 2 {
+		return nil, errors.New("--fg-gradient needs at least two colors")
+	}
+	return g, nil
+}
+
+// splitColors splits a comma-separated colour list, leaving the commas
+// inside cmyk(...) and other functional colours alone.
+func splitColors(s string) []string {
+	var colors []string
+	depth, start := 0, 0
+	for i, r := range s {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth = max(depth-1, 0)
+		case ',':
+			if depth == 0 {
+				colors = append(colors, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(colors, s[start:])
+}
+
+// defaultPrintDPI is the resolution of a print --size without --dpi.
+const defaultPrintDPI = 300
+
+// sizeUnits converts the units of a print --size to millimetres. They
+// are matched as suffixes in order, so a longer suffix must come before
+// any unit it ends with.
+var sizeUnits = []struct {
+	suffix string
+	mm     float64
+}{
+	{"mm", 1},
+	{"cm", 10},
+	{"in", 25.4},
+	{"pt", 25.4 / 72},
+}
+
+// applySize sets the pixel size, physical size and resolution of opts from
+// --size, --dpi and --physical-size. A --size in mm, cm, in or pt is a
+// print size: PNG output meets it at dpi with a whole number of pixels per
+// module, and vector output is drawn at it.
+func applySize(opts *qr.Options, size string, dpi int, physical float64) error {
+	if dpi < 0 {
+		return errors.New("dpi must be zero or positive")
+	}
+	if physical < 0 {
+		return errors.New("physical size must be zero or positive")
+	}
+	opts.DPI = dpi
+	opts.PhysicalSize = physical
+
+	s := strings.ToLower(strings.TrimSpace(size))
+	for _, unit := range sizeUnits {
+		if !strings.HasSuffix(s, unit.suffix) {
+			continue
+		}
+		value, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(s, unit.suffix)), 64)
+		if err != nil || value <= 0 {
+			return fmt.Errorf("invalid size: %s (want pixels or a length in mm, cm, in or pt)", size)
+		}
+		if physical > 0 {
+			return errors.New("--physical-size conflicts with a print --size; use one or the other")
+		}
+		if opts.DPI == 0 {
+			opts.DPI = defaultPrintDPI
+		}
+		opts.PhysicalSize = value * unit.mm
+		opts.Size = int(math.Round(opts.PhysicalSize / 25.4 * float64(opts.DPI)))
+		return nil
+	}
+
+	pixels, err := strconv.Atoi(strings.TrimSpace(strings.TrimSuffix(s, "px")))
+	if err != nil {
+		return fmt.Errorf("invalid size: %s (want pixels or a length in mm, cm, in or pt)", size)
+	}
+	if pixels <= 0 {
+		return errors.New("size must be greater than zero")
+	}
+	opts.Size = pixels
+	return nil
+}
+
+// pageSizes are the named PDF page sizes, in millimetres.
+var pageSizes = map[string][2]float64{
+	"a4":     {210, 297},
+	"a5":     {148, 210},
+	"a6":     {105, 148},
+	"letter": {215.9, 279.4},
+	"legal":  {215.9, 355.6},
+}
+
+// parsePageSize resolves --page-size to millimetres; empty means a page
+// the size of the symbol.
+func parsePageSize(s string) (float64, float64, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" {
+		return 0, 0, nil
+	}
+	if size, ok := pageSizes[s]; ok {
+		return size[0], size[1], nil
+	}
+
+	w, h, ok := strings.Cut(strings.TrimSuffix(s, "mm"), "x")
+	width, errW := strconv.ParseFloat(strings.TrimSpace(w), 64)
+	height, errH := strconv.ParseFloat(strings.TrimSpace(h), 64)
+	if !ok || errW != nil || errH != nil || width <= 0 || height <= 0 {
+		return 0, 0, fmt.Errorf("invalid page size: %s (want a4, a5, a6, letter, legal or WIDTHxHEIGHT in mm)", s)
+	}
+	return width, height, nil
+}
+
+func parseLevel(s string) qr.RecoveryLevel {
 	switch strings.ToUpper(s) {
 	case "L":
-		return qrcode.Low
+		return qr.Low
 	case "M":
-		return qrcode.Medium
+		return qr.Medium
 	case "Q":
-		return qrcode.High
+		return qr.High
 	case "H":
-		return qrcode.Highest
+		return qr.Highest
+	default:
+		return qr.Medium
+	}
+}
+
+func parseMask(s string) (int, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" || s == "auto" {
+		return qr.MaskAuto, nil
+	}
+
+	mask, err := strconv.Atoi(s)
+	if err != nil || mask < 0 || mask > 7 {
+		return 0, fmt.Errorf("invalid mask: %s (want auto or 0-7)", s)
+	}
+	return mask, nil
+}
+
+func parseMode(s string) (qr.Mode, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "auto":
+		return qr.ModeAuto, nil
+	case "numeric":
+		return qr.ModeNumeric, nil
+	case "alphanumeric", "alnum":
+		return qr.ModeAlphanumeric, nil
+	case "byte":
+		return qr.ModeByte, nil
+	case "kanji":
+		return qr.ModeKanji, nil
 	default:
-		return qrcode.Medium
+		return qr.ModeAuto, fmt.Errorf("invalid mode: %s", s)
 	}
 }
 
-func parseColor(hex string) (color.Color, error) {
-	hex = strings.TrimSpace(strings.TrimPrefix(hex, "#"))
-	if len(hex) != 6 {
-		return nil, fmt.Errorf("invalid color: %s", hex)
+// parseECI resolves --eci and --charset to a single designator; when both
+// are given they must name the same charset.
+func parseECI(eci int, charset string) (int, error) {
+	if strings.TrimSpace(charset) == "" {
+		if eci == 0 {
+			return 0, nil
+		}
+		if _, ok := qr.CharsetForECI(eci); !ok {
+			return 0, fmt.Errorf("unsupported ECI designator: %d", eci)
+		}
+		return eci, nil
 	}
 
-	var r, g, b uint8
-	if _, err := fmt.Sscanf(hex, "%02x%02x%02x", &r, &g, &b); err != nil {
-		return nil, fmt.Errorf("invalid color: %s", hex)
+	c, err := qr.LookupCharset(charset)
+	if err != nil {
+		return 0, err
+	}
+	if eci != 0 && eci != c.ECI {
+		return 0, fmt.Errorf("--eci %d conflicts with --charset %s (ECI %d)", eci, c.Name, c.ECI)
 	}
+	return c.ECI, nil
+}
 
-	return color.RGBA{R: r, G: g, B: b, A: 255}, nil
+// parseColor accepts #RGB, #RGBA, #RRGGBB and #RRGGBBAA hex colours, with
+// or without the leading #, "transparent" and the CSS named colours.
+func parseColor(s string) (color.Color, error) {
+	name := strings.ToLower(strings.TrimSpace(s))
+	if name == "transparent" {
+		return color.NRGBA{}, nil
+	}
+	if c, ok := colornames.Map[name]; ok {
+		return c, nil
+	}
+	if strings.HasPrefix(name, "cmyk(") && strings.HasSuffix(name, ")") {
+		return parseCMYK(s, strings.TrimSuffix(strings.TrimPrefix(name, "cmyk("), ")"))
+	}
+
+	hex := strings.TrimPrefix(name, "#")
+	if len(hex) == 3 || len(hex) == 4 {
+		var long strings.Builder
+		for _, r := range hex {
+			long.WriteRune(r)
+			long.WriteRune(r)
+		}
+		hex = long.String()
+	}
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+	if len(hex) != 8 {
+		return nil, fmt.Errorf("invalid color: %s", s)
+	}
+
+	value, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid color: %s", s)
+	}
+	return color.NRGBA{R: uint8(value >> 24), G: uint8(value >> 16), B: uint8(value >> 8), A: uint8(value)}, nil
+}
+
+// parseCMYK parses the comma-separated percentages of a cmyk() colour,
+// which EPS output writes unconverted.
+func parseCMYK(s, values string) (color.Color, error) {
+	fields := strings.Split(values, ",")
+	if len(fields) != 4 {
+		return nil, fmt.Errorf("invalid color: %s (want cmyk(C,M,Y,K) percentages)", s)
+	}
+	var channels [4]uint8
+	for i, field := range fields {
+		pct, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(field), "%")), 64)
+		if err != nil || pct < 0 || pct > 100 {
+			return nil, fmt.Errorf("invalid color: %s (want cmyk(C,M,Y,K) percentages)", s)
+		}
+		channels[i] = uint8(math.Round(pct * 255 / 100))
+	}
+	return color.CMYK{C: channels[0], M: channels[1], Y: channels[2], K: channels[3]}, nil
 }
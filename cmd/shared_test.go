@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"math"
+	"testing"
+
+	"github.com/eliaseffects/qr-cli/internal/qr"
+)
+
+func TestApplySize(t *testing.T) {
+	tests := []struct {
+		size     string
+		dpi      int
+		physical float64
+		pixels   int
+		mm       float64
+		wantDPI  int
+		ok       bool
+	}{
+		{"256", 0, 0, 256, 0, 0, true},
+		{"256px", 0, 0, 256, 0, 0, true},
+		{"25mm", 0, 0, 295, 25, 300, true},
+		{"2.5cm", 600, 0, 591, 25, 600, true},
+		{"1in", 0, 0, 300, 25.4, 300, true},
+		{" 1 IN ", 0, 0, 300, 25.4, 300, true},
+		{"72pt", 0, 0, 300, 25.4, 300, true},
+		{"0mm", 0, 0, 0, 0, 0, false},
+		{"25m", 0, 0, 0, 0, 0, false},
+		{"mm", 0, 0, 0, 0, 0, false},
+		{"25mm", 0, 30, 0, 0, 0, false},
+		{"-5", 0, 0, 0, 0, 0, false},
+	}
+	for _, tt := range tests {
+		opts := qr.DefaultOptions()
+		err := applySize(&opts, tt.size, tt.dpi, tt.physical)
+		if (err == nil) != tt.ok {
+			t.Errorf("applySize(%q) error = %v, want ok %v", tt.size, err, tt.ok)
+			continue
+		}
+		if !tt.ok {
+			continue
+		}
+		if opts.Size != tt.pixels || math.Abs(opts.PhysicalSize-tt.mm) > 1e-9 || opts.DPI != tt.wantDPI {
+			t.Errorf("applySize(%q) = %dpx, %gmm at %d dpi, want %dpx, %gmm at %d dpi",
+				tt.size, opts.Size, opts.PhysicalSize, opts.DPI, tt.pixels, tt.mm, tt.wantDPI)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/eliaseffects/qr-cli/internal/qr"
+)
+
+// sheetPadding is the margin kept clear inside each label, in millimetres.
+const sheetPadding = 1.5
+
+// sheetPresets are common Avery label stocks, in millimetres.
+var sheetPresets = map[string]qr.SheetLayout{
+	// 1" x 2-5/8" address labels, 30 per US Letter sheet.
+	"avery-5160": {PageWidth: 215.9, PageHeight: 279.4, MarginTop: 12.7, MarginLeft: 4.7625,
+		Cols: 3, Rows: 10, LabelWidth: 66.675, LabelHeight: 25.4, PitchX: 69.85, PitchY: 25.4},
+	// 2" x 4" shipping labels, 10 per US Letter sheet.
+	"avery-5163": {PageWidth: 215.9, PageHeight: 279.4, MarginTop: 12.7, MarginLeft: 3.96875,
+		Cols: 2, Rows: 5, LabelWidth: 101.6, LabelHeight: 50.8, PitchX: 106.3625, PitchY: 50.8},
+	// 63.5 x 38.1 mm address labels, 21 per A4 sheet.
+	"avery-l7160": {PageWidth: 210, PageHeight: 297, MarginTop: 15.15, MarginLeft: 7.25,
+		Cols: 3, Rows: 7, LabelWidth: 63.5, LabelHeight: 38.1, PitchX: 66.04, PitchY: 38.1},
+	// 99.1 x 38.1 mm parcel labels, 14 per A4 sheet.
+	"avery-l7163": {PageWidth: 210, PageHeight: 297, MarginTop: 15.15, MarginLeft: 4.65,
+		Cols: 2, Rows: 7, LabelWidth: 99.1, LabelHeight: 38.1, PitchX: 101.6, PitchY: 38.1},
+}
+
+// parseSheet resolves --sheet to a label layout. It takes a preset name,
+// a comma-separated list of key=value settings, or a preset followed by
+// settings that override it:
+//
+//	page=a4,cols=3,rows=7,pitch=66.04x38.1,label=63.5x38.1,margin=15.15x7.25
+//
+// Lengths are in millimetres; pairs are WIDTHxHEIGHT, except margin,
+// which is TOPxLEFT. The label size defaults to the pitch, the pitch to
+// the page divided evenly, and the margins to centring the grid.
+func parseSheet(s string) (qr.SheetLayout, error) {
+	layout := qr.SheetLayout{Padding: sheetPadding}
+	settings := strings.Split(strings.ToLower(strings.TrimSpace(s)), ",")
+	if preset, ok := sheetPresets[strings.TrimSpace(settings[0])]; ok {
+		layout = preset
+		layout.Padding = sheetPadding
+		settings = settings[1:]
+	}
+
+	var hasMargin, hasPitch, hasLabel bool
+	for _, setting := range settings {
+		key, value, ok := strings.Cut(strings.TrimSpace(setting), "=")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if !ok {
+			return layout, fmt.Errorf("invalid sheet setting: %q (want a preset such as %s, or key=value)", setting, sheetPresetNames())
+		}
+
+		var err error
+		switch key {
+		case "page":
+			layout.PageWidth, layout.PageHeight, err = parsePageSize(value)
+		case "cols":
+			layout.Cols, err = strconv.Atoi(value)
+		case "rows":
+			layout.Rows, err = strconv.Atoi(value)
+		case "margin":
+			layout.MarginTop, layout.MarginLeft, err = parseLengthPair(value)
+			hasMargin = true
+		case "pitch":
+			layout.PitchX, layout.PitchY, err = parseLengthPair(value)
+			hasPitch = true
+		case "label":
+			layout.LabelWidth, layout.LabelHeight, err = parseLengthPair(value)
+			hasLabel = true
+		case "padding":
+			layout.Padding, err = strconv.ParseFloat(value, 64)
+		default:
+			return layout, fmt.Errorf("unknown sheet setting: %s (want page, cols, rows, margin, pitch, label or padding)", key)
+		}
+		if err != nil {
+			return layout, fmt.Errorf("invalid sheet %s: %s", key, value)
+		}
+	}
+	if layout.Cols <= 0 || layout.Rows <= 0 || layout.PageWidth <= 0 {
+		return layout, fmt.Errorf("sheet needs page, cols and rows, or a preset: %s", sheetPresetNames())
+	}
+
+	// Fill in what a custom grid leaves out from what it gives.
+	if !hasPitch && layout.PitchX == 0 {
+		if hasLabel {
+			layout.PitchX, layout.PitchY = layout.LabelWidth, layout.LabelHeight
+		} else {
+			layout.PitchX = layout.PageWidth / float64(layout.Cols)
+			layout.PitchY = layout.PageHeight / float64(layout.Rows)
+		}
+	}
+	if !hasLabel && layout.LabelWidth == 0 {
+		layout.LabelWidth, layout.LabelHeight = layout.PitchX, layout.PitchY
+	}
+	if !hasMargin && layout.MarginTop == 0 && layout.MarginLeft == 0 {
+		gridW := float64(layout.Cols-1)*layout.PitchX + layout.LabelWidth
+		gridH := float64(layout.Rows-1)*layout.PitchY + layout.LabelHeight
+		layout.MarginTop = (layout.PageHeight - gridH) / 2
+		layout.MarginLeft = (layout.PageWidth - gridW) / 2
+	}
+	return layout, layout.Validate()
+}
+
+// parseLengthPair parses AxB in millimetres.
+func parseLengthPair(s string) (float64, float64, error) {
+	a, b, ok := strings.Cut(strings.TrimSuffix(s, "mm"), "x")
+	first, errA := strconv.ParseFloat(strings.TrimSpace(a), 64)
+	second, errB := strconv.ParseFloat(strings.TrimSpace(b), 64)
+	if !ok || errA != nil || errB != nil || first < 0 || second < 0 {
+		return 0, 0, fmt.Errorf("invalid length pair: %s", s)
+	}
+	return first, second, nil
+}
+
+func sheetPresetNames() string {
+	names := make([]string, 0, len(sheetPresets))
+	for name := range sheetPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
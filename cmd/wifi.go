@@ -1,9 +1,6 @@
 package cmd
 
 import (
-	"fmt"
-	"strings"
-
 	"github.com/eliaseffects/qr-cli/internal/qr"
 	"github.com/spf13/cobra"
 )
@@ -38,14 +35,12 @@ func runWifi(cmd *cobra.Command, args []string) error {
 	applyOutputConfig(cmd, &wifiFlags)
 	applyWifiConfig(cmd)
 
-	security := strings.ToUpper(strings.TrimSpace(wifiSecurity))
-	switch security {
-	case "NOPASS":
-		security = "nopass"
+	security, err := qr.ParseWifiSecurity(wifiSecurity)
+	if err != nil {
+		return err
+	}
+	if security == "nopass" {
 		wifiPassword = ""
-	case "WPA", "WEP":
-	default:
-		return fmt.Errorf("invalid security type: %s", wifiSecurity)
 	}
 
 	data := qr.WifiConfig{
@@ -1,14 +1,93 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"image"
 	_ "image/png"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
 )
 
+var (
+	cliDir   string
+	cliPath  string
+	cliOnce  sync.Once
+	cliBuild error
+)
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	if cliDir != "" {
+		os.RemoveAll(cliDir)
+	}
+	os.Exit(code)
+}
+
+// runCLI runs the qr binary, built once per test run, in dir with a
+// home directory of its own so no config file applies. It returns the
+// combined output and the exit status.
+func runCLI(t *testing.T, dir string, args ...string) (string, int) {
+	t.Helper()
+	return runCLIEnv(t, dir, nil, args...)
+}
+
+// runCLIEnv is runCLI with extra environment variables.
+func runCLIEnv(t *testing.T, dir string, env []string, args ...string) (string, int) {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+	cliOnce.Do(func() {
+		if cliDir, cliBuild = os.MkdirTemp("", "qr-cli-test"); cliBuild != nil {
+			return
+		}
+		cliPath = filepath.Join(cliDir, "qr")
+		if runtime.GOOS == "windows" {
+			cliPath += ".exe"
+		}
+		out, err := exec.Command("go", "build", "-o", cliPath, ".").CombinedOutput()
+		if err != nil {
+			cliBuild = errors.New(string(out))
+		}
+	})
+	if cliBuild != nil {
+		t.Fatalf("failed to build CLI: %v", cliBuild)
+	}
+
+	cmd := exec.Command(cliPath, args...)
+	cmd.Dir = dir
+	cmd.Env = append(append(os.Environ(), "HOME="+dir, "USERPROFILE="+dir), env...)
+	out, err := cmd.CombinedOutput()
+	var exit *exec.ExitError
+	switch {
+	case err == nil:
+		return string(out), 0
+	case errors.As(err, &exit):
+		return string(out), exit.ExitCode()
+	default:
+		t.Fatalf("failed to run CLI: %v", err)
+		return "", 0
+	}
+}
+
+// writeFile writes a test input file into dir and returns its path.
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
 func TestCLIEndToEnd(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skipping integration test in short mode")
@@ -36,3 +115,151 @@ func TestCLIEndToEnd(t *testing.T) {
 		t.Fatalf("expected png output, got %s", format)
 	}
 }
+
+// The wifi and vcard commands encode what they are given; the stricter
+// checks belong to typed batch rows.
+func TestWifiAndVCardAcceptLooseInput(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"wifi without password", []string{"wifi", "--ssid", "Home"}, "WIFI:T:WPA;S:Home;"},
+		{"vcard with extension", []string{"vcard", "--name", "Ada", "--phone", "555-1234 x12"}, "TEL:555-1234 x12"},
+		{"vcard with local email", []string{"vcard", "--name", "Ada", "--email", "ada@localhost"}, "EMAIL:ada@localhost"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			msg, code := runCLI(t, dir, append(tt.args, "-o", "out.png")...)
+			if code != 0 {
+				t.Fatalf("exit %d\n%s", code, msg)
+			}
+			if got := decodeOne(t, filepath.Join(dir, "out.png")); !strings.Contains(got, tt.want) {
+				t.Fatalf("decoded %q, want it to contain %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGradientStopContrast(t *testing.T) {
+	tests := []struct {
+		name     string
+		gradient string
+		warning  string
+	}{
+		{"low contrast stop", "#000000,#ffff00", "--fg-gradient stop 2 keeps only"},
+		{"dark stops", "#0b3d91,#6a1b9a", ""},
+		{"cmyk stops", "cmyk(0,0,0,100),cmyk(100,0,0,50)", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			msg, code := runCLI(t, dir, "https://example.com", "--fg-gradient", tt.gradient, "-o", "out.png")
+			if code != 0 {
+				t.Fatalf("exit %d\n%s", code, msg)
+			}
+			if got := strings.Contains(msg, "warning:"); got != (tt.warning != "") || !strings.Contains(msg, tt.warning) {
+				t.Fatalf("output:\n%s\nwant warning %q", msg, tt.warning)
+			}
+		})
+	}
+}
+
+func TestInspectImageReportsDecodedSegments(t *testing.T) {
+	dir := t.TempDir()
+	if msg, code := runCLI(t, dir, "12345678", "--mode", "byte", "-o", "code.png"); code != 0 {
+		t.Fatalf("generate: exit %d\n%s", code, msg)
+	}
+	msg, code := runCLI(t, dir, "inspect", "--image", "code.png", "--json")
+	if code != 0 {
+		t.Fatalf("inspect: exit %d\n%s", code, msg)
+	}
+	var reports []struct {
+		Modes     []string `json:"modes"`
+		DataBits  int      `json:"data_bits"`
+		Reencoded bool     `json:"reencoded"`
+	}
+	if err := json.Unmarshal([]byte(msg), &reports); err != nil {
+		t.Fatalf("inspect output is not JSON: %v\n%s", err, msg)
+	}
+	// Byte mode: 4-bit indicator, 8-bit count and 8 bits per digit.
+	if len(reports) != 1 || !slices.Equal(reports[0].Modes, []string{"byte"}) || reports[0].DataBits != 76 || reports[0].Reencoded {
+		t.Fatalf("inspect reported %+v, want the byte segment the code was written with", reports)
+	}
+}
+
+func TestBorderFromEnv(t *testing.T) {
+	dir := t.TempDir()
+	for _, run := range []struct {
+		out  string
+		env  []string
+		args []string
+	}{
+		{"default.png", nil, nil},
+		{"flag.png", nil, []string{"--border", "10"}},
+		{"env.png", []string{"QR_BORDER=10"}, nil},
+	} {
+		args := append([]string{"hi", "-o", run.out}, run.args...)
+		if msg, code := runCLIEnv(t, dir, run.env, args...); code != 0 {
+			t.Fatalf("%s: exit %d\n%s", run.out, code, msg)
+		}
+	}
+	read := func(name string) []byte {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+	if env := read("env.png"); !bytes.Equal(env, read("flag.png")) || bytes.Equal(env, read("default.png")) {
+		t.Fatal("QR_BORDER=10 should render like --border 10")
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	tests := []struct {
+		args []string
+		want []string
+	}{
+		{[]string{"--mask", ""}, []string{"auto", "7"}},
+		{[]string{"--mode", ""}, []string{"numeric", "kanji"}},
+		{[]string{"--symbol", ""}, []string{"qr", "micro", "rmqr"}},
+		{[]string{"inspect", "--symbol", ""}, []string{"qr", "micro", "rmqr"}},
+		{[]string{"inspect", "--mask", ""}, []string{"auto", "0"}},
+		{[]string{"inspect", "--image", ""}, []string{"png", "jpg", ":8"}},
+		{[]string{"--module-style", ""}, []string{"dot", "connected"}},
+		{[]string{"--eye-pupil", ""}, []string{"square", "circle"}},
+		{[]string{"--gradient-type", ""}, []string{"linear", "radial"}},
+		{[]string{"--page-size", ""}, []string{"a4", "letter"}},
+		{[]string{"--format", ""}, []string{"pdf", "eps"}},
+		{[]string{"--fit", ""}, []string{"pad", "exact", "round-up", "round-down"}},
+		{[]string{"batch", "--fit", ""}, []string{"exact"}},
+		{[]string{"--svg-geometry", ""}, []string{"path", "use", "rects"}},
+		{[]string{"--frame", ""}, []string{"none", "banner"}},
+		{[]string{"--font", ""}, []string{"ttf", "otf", ":8"}},
+		{[]string{"batch", "--sheet", ""}, []string{"avery-5160", "avery-l7163"}},
+		{[]string{"batch", "--delimiter", ""}, []string{";", "tab"}},
+		{[]string{"batch", "--jsonl", ""}, []string{"jsonl", ":8"}},
+		{[]string{"batch", "--type", ""}, []string{"text", "wifi", "vcard", "gs1"}},
+		{[]string{"batch", "--logo", ""}, []string{"png", ":8"}},
+		{[]string{"batch", "--manifest", ""}, []string{"csv", "json", ":8"}},
+		{[]string{"batch", "--errors-file", ""}, []string{"csv", ":8"}},
+	}
+	for _, tt := range tests {
+		msg, code := runCLI(t, t.TempDir(), append([]string{"__complete"}, tt.args...)...)
+		if code != 0 {
+			t.Fatalf("%v: exit %d\n%s", tt.args, code, msg)
+		}
+		offered := make(map[string]bool)
+		for _, line := range strings.Split(msg, "\n") {
+			value, _, _ := strings.Cut(line, "\t")
+			offered[value] = true
+		}
+		for _, want := range tt.want {
+			if !offered[want] {
+				t.Errorf("completing %v does not offer %s:\n%s", tt.args, want, msg)
+			}
+		}
+	}
+}
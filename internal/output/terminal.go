@@ -93,9 +93,11 @@ func ToTerminal(data string, opts qr.Options, term TerminalOptions) (string, err
 	return sb.String(), nil
 }
 
+// toRGB drops alpha, which terminals cannot show, so translucent colours
+// print opaque rather than darkened.
 func toRGB(c color.Color) [3]int {
-	rgba := color.RGBAModel.Convert(c).(color.RGBA)
-	return [3]int{int(rgba.R), int(rgba.G), int(rgba.B)}
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	return [3]int{int(n.R), int(n.G), int(n.B)}
 }
 
 func ansiFg(rgb [3]int) string {
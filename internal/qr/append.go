@@ -0,0 +1,187 @@
+package qr
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"unicode/utf8"
+)
+
+// MaxAppendSymbols is the longest Structured Append series allowed.
+const MaxAppendSymbols = 16
+
+// StructuredAppend places a symbol within a Structured Append series.
+// The zero value means the symbol stands alone.
+type StructuredAppend struct {
+	Index  int  // 0-based position in the series
+	Total  int  // number of symbols in the series
+	Parity byte // XOR of every byte of the full payload
+}
+
+func (a StructuredAppend) validate() error {
+	if a.Total == 0 {
+		return nil
+	}
+	if a.Total < 2 || a.Total > MaxAppendSymbols {
+		return fmt.Errorf("structured append total must be 2-%d, got %d", MaxAppendSymbols, a.Total)
+	}
+	if a.Index < 0 || a.Index >= a.Total {
+		return fmt.Errorf("structured append index %d out of range for %d symbols", a.Index, a.Total)
+	}
+	return nil
+}
+
+// AppendParity returns the Structured Append parity byte for a payload.
+func AppendParity(data string) byte {
+	var parity byte
+	for i := 0; i < len(data); i++ {
+		parity ^= data[i]
+	}
+	return parity
+}
+
+// Part is one symbol's share of a split payload.
+type Part struct {
+	Data   string
+	Append StructuredAppend
+}
+
+// Options returns opts with the part's Structured Append header applied.
+func (p Part) Options(opts Options) Options {
+	opts.Append = p.Append
+	return opts
+}
+
+// Split divides data into the fewest Structured Append parts that each fit
+// a symbol under opts. Data that already fits one symbol is returned as a
+// single part without a header.
+func Split(data string, opts Options) ([]Part, error) {
+	opts.Append = StructuredAppend{}
+	if _, _, err := planSymbol(data, opts); err == nil {
+		return []Part{{Data: data}}, nil
+	} else if !errors.Is(err, ErrDataTooLong) {
+		return nil, err
+	}
+
+	// The parity covers the bytes as encoded, so transcode first.
+	encoded, err := applyCharset(data, opts)
+	if err != nil {
+		return nil, err
+	}
+	parity := AppendParity(encoded)
+	for total := 2; total <= MaxAppendSymbols; total++ {
+		chunks := splitEven(data, total)
+		parts := make([]Part, 0, total)
+		for i, chunk := range chunks {
+			part := Part{Data: chunk, Append: StructuredAppend{Index: i, Total: total, Parity: parity}}
+			if _, _, err := planSymbol(chunk, part.Options(opts)); err != nil {
+				if !errors.Is(err, ErrDataTooLong) {
+					return nil, err
+				}
+				break
+			}
+			parts = append(parts, part)
+		}
+		if len(parts) == total {
+			return parts, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w to split across %d symbols at level %s", ErrDataTooLong, MaxAppendSymbols, opts.Level)
+}
+
+// splitEven cuts data into n chunks of similar byte length, moving each
+// cut forward to a rune boundary so no character straddles two symbols.
+func splitEven(data string, n int) []string {
+	chunks := make([]string, 0, n)
+	start := 0
+	for i := 1; i <= n; i++ {
+		end := len(data) * i / n
+		for end < len(data) && !utf8.RuneStart(data[end]) {
+			end++
+		}
+		if end < start {
+			end = start
+		}
+		chunks = append(chunks, data[start:end])
+		start = end
+	}
+	return chunks
+}
+
+// Symbol is a decoded QR payload and its Structured Append position, if
+// any. ECI is the first charset designator the symbol declared, or zero;
+// Payload has already been converted from that charset to UTF-8. GS1
+// reports FNC1 in first position, in which case Payload is an element
+// string with group separators. Version, Level and Mask are read from
+// the symbol; Version is zero when the decoder could not report them.
+// Modes lists the mode of each segment as it was encoded, and DataBits
+// and CapacityBits the bits its segments use and the symbol holds.
+type Symbol struct {
+	Payload string
+	Append  StructuredAppend
+	ECI     int
+	GS1     bool
+	Version int
+	Level   RecoveryLevel
+	Mask    int
+
+	Modes        []Mode
+	DataBits     int
+	CapacityBits int
+}
+
+// JoinStructuredAppend reassembles a complete Structured Append series,
+// given in any order, into the original payload.
+func JoinStructuredAppend(symbols []Symbol) (string, error) {
+	if len(symbols) == 0 {
+		return "", errors.New("no symbols to join")
+	}
+
+	first := symbols[0].Append
+	if first.Total == 0 {
+		return "", errors.New("symbol is not part of a structured append series")
+	}
+
+	sorted := append([]Symbol(nil), symbols...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Append.Index < sorted[j].Append.Index })
+
+	seen := make([]bool, first.Total)
+	var joined []byte
+	for _, sym := range sorted {
+		a := sym.Append
+		if a.Total != first.Total || a.Parity != first.Parity {
+			return "", errors.New("symbols belong to different structured append series")
+		}
+		if a.Index < 0 || a.Index >= a.Total {
+			return "", fmt.Errorf("structured append index %d out of range", a.Index)
+		}
+		if seen[a.Index] {
+			return "", fmt.Errorf("duplicate structured append symbol %d", a.Index+1)
+		}
+		seen[a.Index] = true
+		joined = append(joined, sym.Payload...)
+	}
+
+	for i, ok := range seen {
+		if !ok {
+			return "", fmt.Errorf("structured append series incomplete: missing symbol %d of %d", i+1, first.Total)
+		}
+	}
+
+	result := string(joined)
+	encoded := result
+	if eci := sorted[0].ECI; eci != 0 {
+		// Parity is taken over the bytes in the declared charset, not
+		// the UTF-8 the payloads were decoded into.
+		if c, ok := CharsetForECI(eci); ok {
+			if e, err := c.Encode(result); err == nil {
+				encoded = e
+			}
+		}
+	}
+	if AppendParity(encoded) != first.Parity {
+		return "", errors.New("structured append parity mismatch")
+	}
+	return result, nil
+}
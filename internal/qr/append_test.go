@@ -0,0 +1,129 @@
+package qr_test
+
+import (
+	"bytes"
+	"errors"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/eliaseffects/qr-cli/internal/qr"
+)
+
+func TestSplitFitsSingleSymbol(t *testing.T) {
+	parts, err := qr.Split("https://example.com", qr.DefaultOptions())
+	if err != nil {
+		t.Fatalf("Split() error = %v", err)
+	}
+	if len(parts) != 1 || parts[0].Append.Total != 0 {
+		t.Fatalf("expected a single part without header, got %+v", parts)
+	}
+}
+
+func TestSplitRoundTrip(t *testing.T) {
+	payload := strings.Repeat("structured append payload ", 200)
+	opts := qr.DefaultOptions()
+	opts.Size = 1024
+
+	if _, err := qr.Generate(payload, opts); !errors.Is(err, qr.ErrDataTooLong) {
+		t.Fatalf("expected ErrDataTooLong, got %v", err)
+	}
+
+	parts, err := qr.Split(payload, opts)
+	if err != nil {
+		t.Fatalf("Split() error = %v", err)
+	}
+	if len(parts) < 2 {
+		t.Fatalf("expected several parts, got %d", len(parts))
+	}
+
+	// Decode in reverse to check that joining restores the order.
+	var symbols []qr.Symbol
+	for i := len(parts) - 1; i >= 0; i-- {
+		part := parts[i]
+		if part.Append.Index != i || part.Append.Total != len(parts) {
+			t.Fatalf("part %d has header %+v", i, part.Append)
+		}
+
+		pngData, err := qr.PNG(part.Data, part.Options(opts))
+		if err != nil {
+			t.Fatalf("PNG() error = %v", err)
+		}
+		img, err := png.Decode(bytes.NewReader(pngData))
+		if err != nil {
+			t.Fatalf("png.Decode() error = %v", err)
+		}
+		decoded, err := qr.DecodeSymbols(img)
+		if err != nil {
+			t.Fatalf("DecodeSymbols() error = %v", err)
+		}
+		if decoded[0].Append != part.Append {
+			t.Fatalf("decoded header %+v, want %+v", decoded[0].Append, part.Append)
+		}
+		symbols = append(symbols, decoded[0])
+	}
+
+	joined, err := qr.JoinStructuredAppend(symbols)
+	if err != nil {
+		t.Fatalf("JoinStructuredAppend() error = %v", err)
+	}
+	if joined != payload {
+		t.Fatal("joined payload does not match the original")
+	}
+}
+
+func TestSplitFixedVersion(t *testing.T) {
+	opts := qr.DefaultOptions()
+	opts.Version = 5
+	parts, err := qr.Split(strings.Repeat("x", 300), opts)
+	if err != nil {
+		t.Fatalf("Split() error = %v", err)
+	}
+	for _, part := range parts {
+		code, err := qr.Generate(part.Data, part.Options(opts))
+		if err != nil {
+			t.Fatalf("Generate() error = %v", err)
+		}
+		if code.Version != 5 {
+			t.Fatalf("part encoded at version %d, want 5", code.Version)
+		}
+	}
+}
+
+func TestSplitTooLong(t *testing.T) {
+	opts := qr.DefaultOptions()
+	opts.Version = 1
+	if _, err := qr.Split(strings.Repeat("x", 1000), opts); !errors.Is(err, qr.ErrDataTooLong) {
+		t.Fatalf("expected ErrDataTooLong, got %v", err)
+	}
+}
+
+func TestJoinStructuredAppendErrors(t *testing.T) {
+	parity := qr.AppendParity("abcdef")
+	part := func(index int, payload string) qr.Symbol {
+		return qr.Symbol{Payload: payload, Append: qr.StructuredAppend{Index: index, Total: 3, Parity: parity}}
+	}
+
+	tests := []struct {
+		name    string
+		symbols []qr.Symbol
+	}{
+		{"missing part", []qr.Symbol{part(0, "ab"), part(2, "ef")}},
+		{"duplicate part", []qr.Symbol{part(0, "ab"), part(0, "ab"), part(2, "ef")}},
+		{"parity mismatch", []qr.Symbol{part(0, "ab"), part(1, "XX"), part(2, "ef")}},
+		{"not a series", []qr.Symbol{{Payload: "plain"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := qr.JoinStructuredAppend(tt.symbols); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+
+	joined, err := qr.JoinStructuredAppend([]qr.Symbol{part(2, "ef"), part(0, "ab"), part(1, "cd")})
+	if err != nil || joined != "abcdef" {
+		t.Fatalf("JoinStructuredAppend() = %q, %v", joined, err)
+	}
+}
@@ -0,0 +1,367 @@
+package qr
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"os"
+	"strings"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/image/vector"
+)
+
+// CaptionPosition places a caption above or below the symbol.
+type CaptionPosition int
+
+const (
+	// CaptionBottom places the caption below the symbol.
+	CaptionBottom CaptionPosition = iota
+	// CaptionTop places the caption above the symbol.
+	CaptionTop
+)
+
+func (p CaptionPosition) String() string {
+	if p == CaptionTop {
+		return "top"
+	}
+	return "bottom"
+}
+
+// Frame selects a border drawn around the symbol and its caption, outside
+// the quiet zone, in the foreground colour.
+type Frame int
+
+const (
+	// FrameNone draws no border.
+	FrameNone Frame = iota
+	// FrameSquare draws a border one module wide with square corners.
+	FrameSquare
+	// FrameRounded draws a border one module wide with rounded corners.
+	FrameRounded
+	// FrameBanner draws a rounded border whose caption band is filled, with
+	// the caption knocked out in the background colour.
+	FrameBanner
+)
+
+func (f Frame) String() string {
+	switch f {
+	case FrameSquare:
+		return "square"
+	case FrameRounded:
+		return "rounded"
+	case FrameBanner:
+		return "banner"
+	default:
+		return "none"
+	}
+}
+
+const (
+	// frameRadius is the outer corner radius of rounded frames, in modules.
+	frameRadius = 3
+	// captionLineHeight is the height of the caption band in font sizes.
+	captionLineHeight = 1.8
+)
+
+// decoration is the caption and frame laid out around a symbol, in the
+// units of the canvas being drawn.
+type decoration struct {
+	width, height    float64 // the whole canvas
+	symbolX, symbolY float64 // where the symbol, quiet zone included, sits
+
+	frame       []roundedRect
+	frameFill   color.Color
+	caption     []pathSegment
+	captionFill color.Color
+}
+
+// newDecoration lays out the caption and frame of opts around a symbol w
+// by h units, at module units to a module. With snap set, lengths are
+// rounded to whole units so a PNG symbol stays pixel-aligned. It returns
+// nil when opts asks for neither.
+func newDecoration(opts Options, w, h, module float64, snap bool) (*decoration, error) {
+	if opts.Caption == "" && opts.Frame == FrameNone {
+		return nil, nil
+	}
+	round := func(v float64) float64 {
+		if snap {
+			return math.Max(math.Round(v), 1)
+		}
+		return v
+	}
+
+	var border, radius float64
+	if opts.Frame != FrameNone {
+		border = round(module)
+	}
+	if opts.Frame == FrameRounded || opts.Frame == FrameBanner {
+		radius = frameRadius * module
+	}
+
+	var text textLine
+	var band float64
+	if opts.Caption != "" {
+		f, err := loadFont(opts.FontPath)
+		if err != nil {
+			return nil, err
+		}
+		// The caption scales with the symbol, shrinking to fit on one line
+		// inside a module of margin either side.
+		size := max(max(w, h)/module/12, 2) * module
+		if text, err = layoutText(f, opts.Caption, size); err != nil {
+			return nil, err
+		}
+		if avail := w - 2*module; text.width > avail {
+			size *= avail / text.width
+			if text, err = layoutText(f, opts.Caption, size); err != nil {
+				return nil, err
+			}
+		}
+		band = round(size * captionLineHeight)
+	}
+
+	d := &decoration{
+		width:       w + 2*border,
+		height:      h + 2*border + band,
+		symbolX:     border,
+		symbolY:     border,
+		frameFill:   opts.ForegroundColor,
+		captionFill: opts.ForegroundColor,
+	}
+	bandY := border + h
+	if opts.CaptionPosition == CaptionTop {
+		d.symbolY += band
+		bandY = border
+	}
+
+	if border > 0 {
+		d.frame = append(d.frame,
+			roundedRect{x: 0, y: 0, w: d.width, h: d.height, r: [4]float64{radius, radius, radius, radius}},
+			roundedRect{x: border, y: border, w: d.width - 2*border, h: d.height - 2*border,
+				r: [4]float64{max(radius-border, 0), max(radius-border, 0), max(radius-border, 0), max(radius-border, 0)}, hole: true},
+		)
+	}
+	if opts.Frame == FrameBanner && band > 0 {
+		if opts.CaptionPosition == CaptionTop {
+			d.frame = append(d.frame, roundedRect{x: 0, y: 0, w: d.width, h: bandY + band, r: [4]float64{radius, radius, 0, 0}})
+		} else {
+			d.frame = append(d.frame, roundedRect{x: 0, y: bandY, w: d.width, h: d.height - bandY, r: [4]float64{0, 0, radius, radius}})
+		}
+		// The knocked-out caption stays opaque over a transparent
+		// background.
+		bg := color.NRGBAModel.Convert(opts.BackgroundColor).(color.NRGBA)
+		bg.A = 0xff
+		d.captionFill = bg
+	}
+
+	if band > 0 {
+		baseline := bandY + (band-(text.ascent+text.descent))/2 + text.ascent
+		d.caption = text.translate((d.width-text.width)/2, baseline)
+	}
+	return d, nil
+}
+
+// codeUnits returns the size in modules of a bordered bitmap with its
+// decoration, if any.
+func codeUnits(bitmap [][]bool, deco *decoration) (float64, float64) {
+	if deco != nil {
+		return deco.width, deco.height
+	}
+	return float64(len(bitmap[0])), float64(len(bitmap))
+}
+
+// drawPNG returns a canvas holding symbol, with its background, frame and
+// caption drawn around it.
+func (d *decoration) drawPNG(symbol *image.RGBA, opts Options) *image.RGBA {
+	width, height := int(math.Round(d.width)), int(math.Round(d.height))
+	canvas := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(canvas, canvas.Bounds(), &image.Uniform{C: colorToRGBA(opts.BackgroundColor)}, image.Point{}, draw.Src)
+
+	at := image.Pt(int(math.Round(d.symbolX)), int(math.Round(d.symbolY)))
+	draw.Draw(canvas, symbol.Bounds().Add(at), symbol, image.Point{}, draw.Src)
+
+	if len(d.frame) > 0 {
+		z := vector.NewRasterizer(width, height)
+		for _, shape := range d.frame {
+			shape.rasterize(z, 1, 0, 0)
+		}
+		z.Draw(canvas, canvas.Bounds(), &image.Uniform{C: colorToRGBA(d.frameFill)}, image.Point{})
+	}
+	if len(d.caption) > 0 {
+		z := vector.NewRasterizer(width, height)
+		rasterizeOutline(z, d.caption)
+		z.Draw(canvas, canvas.Bounds(), &image.Uniform{C: colorToRGBA(d.captionFill)}, image.Point{})
+	}
+	return canvas
+}
+
+// writeSVG writes the frame and caption as anti-aliased paths.
+func (d *decoration) writeSVG(b *strings.Builder) {
+	if len(d.frame) > 0 {
+		var path strings.Builder
+		for _, shape := range d.frame {
+			shape.svgPath(&path)
+		}
+		fmt.Fprintf(b, `<path %s shape-rendering="geometricPrecision" d="%s"/>`, svgFill(d.frameFill), path.String())
+	}
+	if len(d.caption) > 0 {
+		fmt.Fprintf(b, `<path %s shape-rendering="geometricPrecision" d="%s"/>`, svgFill(d.captionFill), svgOutline(d.caption))
+	}
+}
+
+// loadFont parses the TrueType or OpenType font at path, or Go Regular
+// when path is empty.
+func loadFont(path string) (*sfnt.Font, error) {
+	data := goregular.TTF
+	if path != "" {
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			return nil, fmt.Errorf("failed to read font: %w", err)
+		}
+	}
+	f, err := sfnt.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse font %s: %w", path, err)
+	}
+	return f, nil
+}
+
+// pathSegment is one step of a glyph outline, in the form of an
+// sfnt.Segment with float coordinates.
+type pathSegment struct {
+	op   sfnt.SegmentOp
+	args [3][2]float64
+}
+
+// textLine is a line of text as glyph outlines, starting at x 0 with its
+// baseline at y 0 and y running down.
+type textLine struct {
+	segments        []pathSegment
+	width           float64
+	ascent, descent float64
+}
+
+// layoutText sets text on one line in f at size units to the em.
+// Glyphs are loaded at one pixel per font unit so the outlines keep their
+// precision when scaled.
+func layoutText(f *sfnt.Font, text string, size float64) (textLine, error) {
+	var buf sfnt.Buffer
+	upem := f.UnitsPerEm()
+	ppem := fixed.I(int(upem))
+	scale := size / float64(upem)
+	unit := func(v fixed.Int26_6) float64 { return float64(v) / 64 * scale }
+
+	metrics, err := f.Metrics(&buf, ppem, font.HintingNone)
+	if err != nil {
+		return textLine{}, err
+	}
+	line := textLine{ascent: unit(metrics.Ascent), descent: unit(metrics.Descent)}
+
+	var x float64
+	prev, hasPrev := sfnt.GlyphIndex(0), false
+	for _, r := range text {
+		if r < ' ' {
+			r = ' '
+		}
+		glyph, err := f.GlyphIndex(&buf, r)
+		if err != nil {
+			return textLine{}, err
+		}
+		if hasPrev {
+			if kern, err := f.Kern(&buf, prev, glyph, ppem, font.HintingNone); err == nil {
+				x += unit(kern)
+			}
+		}
+		segments, err := f.LoadGlyph(&buf, glyph, ppem, nil)
+		if err != nil {
+			return textLine{}, err
+		}
+		for _, s := range segments {
+			seg := pathSegment{op: s.Op}
+			for i, p := range s.Args {
+				seg.args[i] = [2]float64{x + unit(p.X), unit(p.Y)}
+			}
+			line.segments = append(line.segments, seg)
+		}
+		advance, err := f.GlyphAdvance(&buf, glyph, ppem, font.HintingNone)
+		if err != nil {
+			return textLine{}, err
+		}
+		x += unit(advance)
+		prev, hasPrev = glyph, true
+	}
+	line.width = x
+	return line, nil
+}
+
+// translate returns the outlines of the line moved by (dx, dy).
+func (l textLine) translate(dx, dy float64) []pathSegment {
+	out := make([]pathSegment, len(l.segments))
+	for i, s := range l.segments {
+		out[i] = s
+		for j := range s.args {
+			out[i].args[j] = [2]float64{s.args[j][0] + dx, s.args[j][1] + dy}
+		}
+	}
+	return out
+}
+
+// svgOutline formats glyph outlines as SVG path data, closing each
+// contour.
+func svgOutline(segments []pathSegment) string {
+	var b strings.Builder
+	pt := func(p [2]float64) string { return svgNum(p[0]) + " " + svgNum(p[1]) }
+	for i, s := range segments {
+		switch s.op {
+		case sfnt.SegmentOpMoveTo:
+			if i > 0 {
+				b.WriteString("Z")
+			}
+			b.WriteString("M" + pt(s.args[0]))
+		case sfnt.SegmentOpLineTo:
+			b.WriteString("L" + pt(s.args[0]))
+		case sfnt.SegmentOpQuadTo:
+			b.WriteString("Q" + pt(s.args[0]) + " " + pt(s.args[1]))
+		case sfnt.SegmentOpCubeTo:
+			b.WriteString("C" + pt(s.args[0]) + " " + pt(s.args[1]) + " " + pt(s.args[2]))
+		}
+	}
+	if len(segments) > 0 {
+		b.WriteString("Z")
+	}
+	return b.String()
+}
+
+// rasterizeOutline adds glyph outlines to z, closing each contour.
+func rasterizeOutline(z *vector.Rasterizer, segments []pathSegment) {
+	pt := func(p [2]float64) (float32, float32) { return float32(p[0]), float32(p[1]) }
+	for i, s := range segments {
+		switch s.op {
+		case sfnt.SegmentOpMoveTo:
+			if i > 0 {
+				z.ClosePath()
+			}
+			z.MoveTo(pt(s.args[0]))
+		case sfnt.SegmentOpLineTo:
+			z.LineTo(pt(s.args[0]))
+		case sfnt.SegmentOpQuadTo:
+			ax, ay := pt(s.args[0])
+			bx, by := pt(s.args[1])
+			z.QuadTo(ax, ay, bx, by)
+		case sfnt.SegmentOpCubeTo:
+			ax, ay := pt(s.args[0])
+			bx, by := pt(s.args[1])
+			cx, cy := pt(s.args[2])
+			z.CubeTo(ax, ay, bx, by, cx, cy)
+		}
+	}
+	if len(segments) > 0 {
+		z.ClosePath()
+	}
+}
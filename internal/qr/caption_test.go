@@ -0,0 +1,142 @@
+package qr_test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/eliaseffects/qr-cli/internal/qr"
+)
+
+func decodeImage(t *testing.T, data []byte) image.Image {
+	t.Helper()
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("png.Decode() error = %v", err)
+	}
+	return img
+}
+
+func gray(c color.Color) uint8 {
+	return color.GrayModel.Convert(c).(color.Gray).Y
+}
+
+func TestCaptionPNG(t *testing.T) {
+	payload := "https://example.com"
+	plainData, err := qr.PNG(payload, qr.DefaultOptions())
+	if err != nil {
+		t.Fatalf("PNG() error = %v", err)
+	}
+	plain := decodeImage(t, plainData)
+
+	for _, pos := range []qr.CaptionPosition{qr.CaptionBottom, qr.CaptionTop} {
+		opts := qr.DefaultOptions()
+		opts.Caption = "Scan to join Wi-Fi"
+		opts.CaptionPosition = pos
+		opts.Frame = qr.FrameSquare
+
+		data, err := qr.PNG(payload, opts)
+		if err != nil {
+			t.Fatalf("%s: PNG() error = %v", pos, err)
+		}
+		img := decodeImage(t, data)
+		if results := decodePNG(t, data); results[0] != payload {
+			t.Fatalf("%s: decoded %q", pos, results[0])
+		}
+		w, h, err := qr.PNGSize(payload, opts)
+		if err != nil || w != img.Bounds().Dx() || h != img.Bounds().Dy() {
+			t.Errorf("%s: PNGSize() = %d, %d, %v, want %v", pos, w, h, err, img.Bounds().Size())
+		}
+
+		// The symbol is drawn unchanged inside a frame one module (7
+		// pixels) wide, with the caption band above or below it.
+		const border = 7
+		band := h - 256 - 2*border
+		if w != 256+2*border || band <= 0 {
+			t.Fatalf("%s: canvas %dx%d", pos, w, h)
+		}
+		top := border
+		if pos == qr.CaptionTop {
+			top += band
+		}
+		for y := 0; y < 256; y++ {
+			for x := 0; x < 256; x++ {
+				if got, want := gray(img.At(x+border, y+top)), gray(plain.At(x, y)); got != want {
+					t.Fatalf("%s: symbol pixel (%d, %d) = %d, want %d", pos, x, y, got, want)
+				}
+			}
+		}
+
+		// The caption puts ink in the band.
+		bandY := border + 256
+		if pos == qr.CaptionTop {
+			bandY = border
+		}
+		ink := 0
+		for y := bandY; y < bandY+band; y++ {
+			for x := border; x < w-border; x++ {
+				if gray(img.At(x, y)) < 0x80 {
+					ink++
+				}
+			}
+		}
+		if ink == 0 {
+			t.Errorf("%s: caption band is empty", pos)
+		}
+	}
+}
+
+func TestCaptionSVG(t *testing.T) {
+	opts := qr.DefaultOptions()
+	opts.Caption = "Scan me"
+	opts.Frame = qr.FrameBanner
+
+	svg, err := qr.SVG("https://example.com", opts)
+	if err != nil {
+		t.Fatalf("SVG() error = %v", err)
+	}
+	for _, want := range []string{`viewBox="0 0 35 `, `<g transform="translate(1 1)">`, `fill="#ffffff" shape-rendering="geometricPrecision"`} {
+		if !strings.Contains(string(svg), want) {
+			t.Errorf("SVG missing %s", want)
+		}
+	}
+
+	opts.FontPath = "missing.ttf"
+	if _, err := qr.SVG("https://example.com", opts); err == nil {
+		t.Error("expected an error for a missing font")
+	}
+	opts = qr.DefaultOptions()
+	opts.Caption = "Scan me"
+	if _, err := qr.EPS("https://example.com", opts); err == nil {
+		t.Error("expected EPS to refuse a caption")
+	}
+}
+
+func TestCaptionPDF(t *testing.T) {
+	opts := qr.DefaultOptions()
+	opts.Caption = "Scan me"
+	opts.Frame = qr.FrameRounded
+
+	pdf, err := qr.PDF("https://example.com", opts)
+	if err != nil {
+		t.Fatalf("PDF() error = %v", err)
+	}
+	streams := pdfStreams(t, pdf)
+	content := streams[len(streams)-1]
+
+	// The decoration is drawn first, in its own module space, and the
+	// symbol one module in from the frame.
+	parts := strings.SplitN(content, "Q\n", 2)
+	if len(parts) != 2 || !strings.Contains(parts[0], " c\n") || !strings.Contains(parts[0], "h\nf\n") {
+		t.Fatalf("decoration = %q, want frame and caption outlines", parts[0])
+	}
+	module := 256.0 / 33
+	if want := "q " + strconv.FormatFloat(math.Round(module*10000)/10000, 'f', -1, 64); !strings.HasPrefix(parts[1], want) {
+		t.Errorf("symbol starts %q, want %q", parts[1][:30], want)
+	}
+}
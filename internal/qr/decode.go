@@ -14,17 +14,66 @@ import (
 
 // DecodeImage extracts QR payloads from an image.
 func DecodeImage(img image.Image) ([]string, error) {
+	symbols, err := DecodeSymbols(img)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]string, 0, len(symbols))
+	for _, sym := range symbols {
+		results = append(results, sym.Payload)
+	}
+	return results, nil
+}
+
+// DecodeSymbols extracts QR symbols from an image, keeping their
+// Structured Append headers.
+//
+// Upright single symbols, which covers everything this package renders,
+// are sampled and decoded natively. Anything else goes through goqr,
+// which handles rotation and multiple symbols but does not support every
+// segment type.
+func DecodeSymbols(img image.Image) ([]Symbol, error) {
+	var found []Symbol
+	var claimed []image.Rectangle
+	for _, grid := range sampleGrids(img) {
+		if overlapsAny(grid.bounds, claimed) {
+			continue
+		}
+		sym, err := decodeGrid(grid.modules)
+		if err != nil {
+			continue
+		}
+		found = append(found, Symbol{
+			Payload: string(sym.payload),
+			Append:  sym.append,
+			ECI:     sym.eci,
+			GS1:     sym.gs1,
+			Version: sym.version,
+			Level:   sym.level,
+			Mask:    sym.mask,
+
+			Modes:        sym.modes,
+			DataBits:     sym.used,
+			CapacityBits: sym.capacity,
+		})
+		claimed = append(claimed, grid.bounds)
+	}
+	if len(found) > 0 {
+		return found, nil
+	}
+
 	codes, err := goqr.Recognize(img)
 	if err != nil {
 		return nil, err
 	}
 
-	results := make([]string, 0, len(codes))
+	results := make([]Symbol, 0, len(codes))
 	for _, code := range codes {
 		if code == nil {
 			continue
 		}
-		results = append(results, string(code.Payload))
+		results = append(results, Symbol{Payload: string(code.Payload)})
 	}
 	if len(results) == 0 {
 		return nil, fmt.Errorf("no QR code data found")
@@ -33,8 +82,36 @@ func DecodeImage(img image.Image) ([]string, error) {
 	return results, nil
 }
 
+func overlapsAny(r image.Rectangle, others []image.Rectangle) bool {
+	for _, o := range others {
+		if r.Overlaps(o) {
+			return true
+		}
+	}
+	return false
+}
+
 // DecodeFile loads an image file and extracts QR payloads.
 func DecodeFile(path string) ([]string, error) {
+	img, err := loadImage(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return DecodeImage(img)
+}
+
+// DecodeFileSymbols loads an image file and extracts QR symbols.
+func DecodeFileSymbols(path string) ([]Symbol, error) {
+	img, err := loadImage(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return DecodeSymbols(img)
+}
+
+func loadImage(path string) (image.Image, error) {
 	file, err := os.Open(path)
 	if err != nil {
 		return nil, err
@@ -46,5 +123,5 @@ func DecodeFile(path string) ([]string, error) {
 		return nil, err
 	}
 
-	return DecodeImage(img)
+	return img, nil
 }
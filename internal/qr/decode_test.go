@@ -1,8 +1,13 @@
 package qr_test
 
 import (
+	"bytes"
+	"image/color"
+	"image/png"
+	"math"
 	"os"
 	"path/filepath"
+	"slices"
 	"testing"
 
 	"github.com/eliaseffects/qr-cli/internal/qr"
@@ -36,3 +41,110 @@ func TestDecodeFile(t *testing.T) {
 		t.Fatalf("expected %q, got %q", payload, results[0])
 	}
 }
+
+func TestDecodeImageWithLogo(t *testing.T) {
+	payload := "https://example.com/some/long/path"
+	opts := qr.DefaultOptions()
+	opts.Size = 512
+	opts.Level = qr.Highest
+	opts.LogoPath = filepath.Join("..", "..", "testdata", "logo.png")
+
+	pngData, err := qr.PNG(payload, opts)
+	if err != nil {
+		t.Fatalf("PNG() error = %v", err)
+	}
+
+	results := decodePNG(t, pngData)
+	if results[0] != payload {
+		t.Fatalf("expected %q, got %q", payload, results[0])
+	}
+}
+
+func TestDecodeSymbolParameters(t *testing.T) {
+	opts := qr.DefaultOptions()
+	opts.Size = 512
+	opts.Level = qr.High
+	opts.Version = 5
+	opts.Mask = 6
+
+	pngData, err := qr.PNG("parameters", opts)
+	if err != nil {
+		t.Fatalf("PNG() error = %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(pngData))
+	if err != nil {
+		t.Fatalf("png.Decode() error = %v", err)
+	}
+	symbols, err := qr.DecodeSymbols(img)
+	if err != nil {
+		t.Fatalf("DecodeSymbols() error = %v", err)
+	}
+	sym := symbols[0]
+	if sym.Version != 5 || sym.Level != qr.High || sym.Mask != 6 {
+		t.Fatalf("decoded version %d, level %s, mask %d", sym.Version, sym.Level, sym.Mask)
+	}
+}
+
+func TestDecodeSymbolSegments(t *testing.T) {
+	tests := []struct {
+		data  string
+		mode  qr.Mode
+		modes []qr.Mode
+	}{
+		{"12345678", qr.ModeByte, []qr.Mode{qr.ModeByte}},
+		{"12345678", qr.ModeAuto, []qr.Mode{qr.ModeNumeric}},
+		{"HELLO WORLD", qr.ModeAlphanumeric, []qr.Mode{qr.ModeAlphanumeric}},
+	}
+	for _, tt := range tests {
+		opts := qr.DefaultOptions()
+		opts.Size = 512
+		opts.Mode = tt.mode
+		code, err := qr.Generate(tt.data, opts)
+		if err != nil {
+			t.Fatalf("Generate(%q) error = %v", tt.data, err)
+		}
+		pngData, err := qr.PNG(tt.data, opts)
+		if err != nil {
+			t.Fatalf("PNG() error = %v", err)
+		}
+		img, err := png.Decode(bytes.NewReader(pngData))
+		if err != nil {
+			t.Fatalf("png.Decode() error = %v", err)
+		}
+		symbols, err := qr.DecodeSymbols(img)
+		if err != nil {
+			t.Fatalf("DecodeSymbols() error = %v", err)
+		}
+
+		sym := symbols[0]
+		used, capacity := code.DataBits()
+		if !slices.Equal(sym.Modes, tt.modes) {
+			t.Errorf("%q in mode %s: decoded modes %v, want %v", tt.data, tt.mode, sym.Modes, tt.modes)
+		}
+		if sym.DataBits != used || sym.CapacityBits != capacity {
+			t.Errorf("%q in mode %s: decoded %d of %d bits, encoded %d of %d",
+				tt.data, tt.mode, sym.DataBits, sym.CapacityBits, used, capacity)
+		}
+	}
+}
+
+func TestContrast(t *testing.T) {
+	tests := []struct {
+		name   string
+		fg, bg color.Color
+		want   float64
+	}{
+		{"black on white", color.Black, color.White, 1},
+		{"white on black", color.White, color.Black, -1},
+		{"grey on grey", color.Gray{Y: 0x77}, color.Gray{Y: 0x88}, 17.0 / 255},
+		{"transparent background", color.Black, color.Transparent, 1},
+		{"half-transparent black on white", color.NRGBA{A: 0x80}, color.White, 0.5},
+		{"transparent foreground", color.Transparent, color.White, 0},
+	}
+
+	for _, tt := range tests {
+		if got := qr.Contrast(tt.fg, tt.bg); math.Abs(got-tt.want) > 0.01 {
+			t.Errorf("%s: Contrast() = %.3f, want %.3f", tt.name, got, tt.want)
+		}
+	}
+}
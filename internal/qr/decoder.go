@@ -0,0 +1,360 @@
+package qr
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+
+	"golang.org/x/text/encoding/japanese"
+)
+
+var errTooManyErrors = errors.New("too many errors to correct")
+
+// symbolData is the content of a decoded symbol together with the
+// parameters read from it.
+type symbolData struct {
+	version  int
+	level    RecoveryLevel
+	mask     int
+	modes    []Mode
+	used     int // bits before the terminator
+	capacity int
+	append   StructuredAppend
+	eci      int
+	gs1      bool
+	payload  []byte
+}
+
+// decodeGrid reads a module grid (without quiet zone) back into its
+// payload, correcting errors where the level allows.
+func decodeGrid(grid [][]bool) (*symbolData, error) {
+	size := len(grid)
+	if size < symbolSize(minVersion) || size > symbolSize(maxVersion) || (size-17)%4 != 0 {
+		return nil, fmt.Errorf("invalid symbol size: %d", size)
+	}
+	version := (size - 17) / 4
+
+	level, mask, err := readFormat(grid)
+	if err != nil {
+		return nil, err
+	}
+
+	m := newMatrix(size)
+	m.drawFunctionPatterns(version)
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			if !m.function[y][x] {
+				m.modules[y][x] = grid[y][x]
+			}
+		}
+	}
+	m.applyMask(mask)
+
+	raw := m.readCodewords(rawDataModules(version) / 8)
+	data, err := removeErrorCorrection(raw, version, level)
+	if err != nil {
+		return nil, err
+	}
+
+	sym, err := parseBitstream(data, version)
+	if err != nil {
+		return nil, err
+	}
+	sym.level = level
+	sym.mask = mask
+	return sym, nil
+}
+
+// readFormat recovers the level and mask from whichever copy of the
+// format information is closest to a valid code word.
+func readFormat(grid [][]bool) (RecoveryLevel, int, error) {
+	primary, secondary := formatPositions(len(grid))
+
+	best, bestLevel, bestMask := 16, Medium, 0
+	for _, positions := range [][15][2]int{primary, secondary} {
+		read := 0
+		for i, p := range positions {
+			if grid[p[1]][p[0]] {
+				read |= 1 << uint(i)
+			}
+		}
+		for level := Low; level <= Highest; level++ {
+			for mask := 0; mask < 8; mask++ {
+				if d := bits.OnesCount(uint(read ^ formatBits(level, mask))); d < best {
+					best, bestLevel, bestMask = d, level, mask
+				}
+			}
+		}
+	}
+	if best > 3 {
+		return 0, 0, errors.New("unreadable format information")
+	}
+	return bestLevel, bestMask, nil
+}
+
+// removeErrorCorrection reverses addErrorCorrection: it de-interleaves
+// the blocks, corrects each one and returns the data codewords.
+func removeErrorCorrection(raw []byte, version int, level RecoveryLevel) ([]byte, error) {
+	numBlocks := eccBlockCount[level][version]
+	eccLen := eccCodewordsPerBlock[level][version]
+	numShortBlocks := numBlocks - len(raw)%numBlocks
+	shortBlockLen := len(raw) / numBlocks
+
+	blocks := make([][]byte, numBlocks)
+	for i := range blocks {
+		blocks[i] = make([]byte, shortBlockLen+1)
+	}
+	k := 0
+	for i := 0; i <= shortBlockLen; i++ {
+		for j := range blocks {
+			if i != shortBlockLen-eccLen || j >= numShortBlocks {
+				blocks[j][i] = raw[k]
+				k++
+			}
+		}
+	}
+
+	var data []byte
+	for j, block := range blocks {
+		if j < numShortBlocks {
+			// Drop the placeholder that keeps short blocks aligned.
+			pad := shortBlockLen - eccLen
+			block = append(block[:pad], block[pad+1:]...)
+		}
+		if err := rsCorrect(block, eccLen); err != nil {
+			return nil, err
+		}
+		data = append(data, block[:len(block)-eccLen]...)
+	}
+	return data, nil
+}
+
+type bitReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *bitReader) remaining() int {
+	return len(r.data)*8 - r.pos
+}
+
+func (r *bitReader) read(n int) (int, error) {
+	if n > r.remaining() {
+		return 0, errors.New("unexpected end of data")
+	}
+	value := 0
+	for i := 0; i < n; i++ {
+		value = value<<1 | int(r.data[r.pos>>3]>>uint(7-r.pos&7))&1
+		r.pos++
+	}
+	return value, nil
+}
+
+// parseBitstream splits the data codewords into segments and decodes
+// each one, stopping at the terminator or when the data runs out. Bytes
+// following an ECI designator are converted from its charset to UTF-8.
+func parseBitstream(data []byte, version int) (*symbolData, error) {
+	sym := &symbolData{version: version, capacity: len(data) * 8}
+	r := &bitReader{data: data}
+
+	var charset *Charset
+	var pending []byte
+	flush := func() error {
+		if charset == nil || len(pending) == 0 {
+			sym.payload = append(sym.payload, pending...)
+			pending = nil
+			return nil
+		}
+		text, err := charset.Decode(pending)
+		if err != nil {
+			return err
+		}
+		sym.payload = append(sym.payload, text...)
+		pending = nil
+		return nil
+	}
+
+	for r.remaining() >= 4 {
+		indicator, _ := r.read(4)
+		var mode Mode
+		switch indicator {
+		case 0x0:
+			sym.used = r.pos - 4
+			return sym, flush()
+		case 0x1:
+			mode = ModeNumeric
+		case 0x2:
+			mode = ModeAlphanumeric
+		case 0x4:
+			mode = ModeByte
+		case 0x8:
+			mode = ModeKanji
+		case 0x3:
+			header, err := r.read(16)
+			if err != nil {
+				return nil, err
+			}
+			sym.append = StructuredAppend{
+				Index:  header >> 12,
+				Total:  (header>>8)&0xf + 1,
+				Parity: byte(header),
+			}
+			continue
+		case 0x5:
+			sym.gs1 = true
+			continue
+		case 0x9:
+			// FNC1 in second position carries an application indicator
+			// that has no bearing on the payload.
+			if _, err := r.read(8); err != nil {
+				return nil, err
+			}
+			continue
+		case 0x7:
+			eci, err := readECI(r)
+			if err != nil {
+				return nil, err
+			}
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			if sym.eci == 0 {
+				sym.eci = eci
+			}
+			// An unknown designator leaves the bytes as they are.
+			charset = nil
+			if c, ok := CharsetForECI(eci); ok {
+				charset = &c
+			}
+			continue
+		default:
+			return nil, fmt.Errorf("unsupported mode indicator: %#x", indicator)
+		}
+
+		count, err := r.read(qrFraming(version).charCountBits(mode))
+		if err != nil {
+			return nil, err
+		}
+		part, err := readSegment(r, mode, count)
+		if err != nil {
+			return nil, err
+		}
+		sym.modes = append(sym.modes, mode)
+		if sym.gs1 && mode == ModeAlphanumeric {
+			part = unescapeFNC1(part)
+		}
+		if mode == ModeKanji {
+			// Kanji segments are Shift JIS whatever the ECI says.
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			text, err := japanese.ShiftJIS.NewDecoder().Bytes(part)
+			if err != nil {
+				return nil, err
+			}
+			sym.payload = append(sym.payload, text...)
+			continue
+		}
+		pending = append(pending, part...)
+	}
+
+	sym.used = r.pos
+	return sym, flush()
+}
+
+// unescapeFNC1 turns the '%' that stands for FNC1 in an alphanumeric
+// segment of a GS1 symbol back into a group separator, and "%%" into '%'.
+func unescapeFNC1(part []byte) []byte {
+	out := make([]byte, 0, len(part))
+	for i := 0; i < len(part); i++ {
+		switch {
+		case part[i] != '%':
+			out = append(out, part[i])
+		case i+1 < len(part) && part[i+1] == '%':
+			out = append(out, '%')
+			i++
+		default:
+			out = append(out, gs1Separator)
+		}
+	}
+	return out
+}
+
+// readSegment reads count characters of a segment as raw bytes; kanji
+// characters come back as Shift JIS byte pairs.
+func readSegment(r *bitReader, mode Mode, count int) ([]byte, error) {
+	var out []byte
+	switch mode {
+	case ModeNumeric:
+		for count > 0 {
+			digits := min(count, 3)
+			value, err := r.read(digits*3 + 1)
+			if err != nil {
+				return nil, err
+			}
+			out = fmt.Appendf(out, "%0*d", digits, value)
+			count -= digits
+		}
+	case ModeAlphanumeric:
+		for count > 0 {
+			if count == 1 {
+				value, err := r.read(6)
+				if err != nil || value >= 45 {
+					return nil, errors.New("invalid alphanumeric data")
+				}
+				out = append(out, alphanumericCharset[value])
+				break
+			}
+			value, err := r.read(11)
+			if err != nil || value >= 45*45 {
+				return nil, errors.New("invalid alphanumeric data")
+			}
+			out = append(out, alphanumericCharset[value/45], alphanumericCharset[value%45])
+			count -= 2
+		}
+	case ModeByte:
+		for ; count > 0; count-- {
+			value, err := r.read(8)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, byte(value))
+		}
+	case ModeKanji:
+		for ; count > 0; count-- {
+			value, err := r.read(13)
+			if err != nil {
+				return nil, err
+			}
+			code := (value/0xc0)<<8 | value%0xc0
+			if code < 0x1f00 {
+				code += 0x8140
+			} else {
+				code += 0xc140
+			}
+			out = append(out, byte(code>>8), byte(code))
+		}
+	}
+	return out, nil
+}
+
+// readECI reads an ECI designator, which is one to three bytes long
+// depending on its leading bits.
+func readECI(r *bitReader) (int, error) {
+	first, err := r.read(8)
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case first&0x80 == 0:
+		return first, nil
+	case first&0xc0 == 0x80:
+		rest, err := r.read(8)
+		return (first&0x3f)<<8 | rest, err
+	case first&0xe0 == 0xc0:
+		rest, err := r.read(16)
+		return (first&0x1f)<<16 | rest, err
+	default:
+		return 0, errors.New("invalid ECI designator")
+	}
+}
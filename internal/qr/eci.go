@@ -0,0 +1,152 @@
+package qr
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/korean"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// Charset is a character set that a symbol can declare with an Extended
+// Channel Interpretation (ECI) designator.
+type Charset struct {
+	ECI  int
+	Name string
+
+	aliases  []string
+	encoding encoding.Encoding
+}
+
+// charsets lists the ECI assignments this package can transcode.
+var charsets = []Charset{
+	{2, "CP437", []string{"ibm437", "437"}, charmap.CodePage437},
+	{3, "ISO-8859-1", []string{"latin1", "l1"}, charmap.ISO8859_1},
+	{4, "ISO-8859-2", []string{"latin2", "l2"}, charmap.ISO8859_2},
+	{5, "ISO-8859-3", []string{"latin3", "l3"}, charmap.ISO8859_3},
+	{6, "ISO-8859-4", []string{"latin4", "l4"}, charmap.ISO8859_4},
+	{7, "ISO-8859-5", []string{"cyrillic"}, charmap.ISO8859_5},
+	{8, "ISO-8859-6", []string{"arabic"}, charmap.ISO8859_6},
+	{9, "ISO-8859-7", []string{"greek"}, charmap.ISO8859_7},
+	{10, "ISO-8859-8", []string{"hebrew"}, charmap.ISO8859_8},
+	{11, "ISO-8859-9", []string{"latin5", "l5"}, charmap.ISO8859_9},
+	{12, "ISO-8859-10", []string{"latin6", "l6"}, charmap.ISO8859_10},
+	{15, "ISO-8859-13", []string{"latin7", "l7"}, charmap.ISO8859_13},
+	{16, "ISO-8859-14", []string{"latin8", "l8"}, charmap.ISO8859_14},
+	{17, "ISO-8859-15", []string{"latin9", "l9"}, charmap.ISO8859_15},
+	{18, "ISO-8859-16", []string{"latin10", "l10"}, charmap.ISO8859_16},
+	{20, "Shift_JIS", []string{"sjis", "cp932"}, japanese.ShiftJIS},
+	{21, "windows-1250", []string{"cp1250"}, charmap.Windows1250},
+	{22, "windows-1251", []string{"cp1251"}, charmap.Windows1251},
+	{23, "windows-1252", []string{"cp1252"}, charmap.Windows1252},
+	{24, "windows-1256", []string{"cp1256"}, charmap.Windows1256},
+	{25, "UTF-16BE", []string{"utf16be", "utf16"}, unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM)},
+	{26, "UTF-8", []string{"utf8"}, unicode.UTF8},
+	{28, "Big5", []string{"cp950"}, traditionalchinese.Big5},
+	{29, "GB2312", []string{"euccn"}, simplifiedchinese.GBK},
+	{30, "EUC-KR", []string{"euckr", "cp949"}, korean.EUCKR},
+	{31, "GBK", []string{"cp936"}, simplifiedchinese.GBK},
+	{32, "GB18030", nil, simplifiedchinese.GB18030},
+	{33, "UTF-16LE", []string{"utf16le"}, unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM)},
+}
+
+// CharsetForECI returns the charset assigned to an ECI designator.
+func CharsetForECI(eci int) (Charset, bool) {
+	for _, c := range charsets {
+		if c.ECI == eci {
+			return c, true
+		}
+	}
+	return Charset{}, false
+}
+
+// LookupCharset finds a charset by name or common alias, ignoring case,
+// dashes and underscores, or by its ECI designator written as a number.
+func LookupCharset(name string) (Charset, error) {
+	if eci, err := strconv.Atoi(strings.TrimSpace(name)); err == nil {
+		if c, ok := CharsetForECI(eci); ok {
+			return c, nil
+		}
+		return Charset{}, fmt.Errorf("unsupported ECI designator: %d", eci)
+	}
+
+	key := normalizeCharsetName(name)
+	for _, c := range charsets {
+		if normalizeCharsetName(c.Name) == key {
+			return c, nil
+		}
+		for _, alias := range c.aliases {
+			if alias == key {
+				return c, nil
+			}
+		}
+	}
+	return Charset{}, fmt.Errorf("unsupported charset: %s", name)
+}
+
+func normalizeCharsetName(name string) string {
+	return strings.NewReplacer("-", "", "_", "", " ", "").Replace(strings.ToLower(strings.TrimSpace(name)))
+}
+
+// Encode transcodes UTF-8 text into the charset.
+func (c Charset) Encode(text string) (string, error) {
+	encoded, err := c.encoding.NewEncoder().String(text)
+	if err == nil {
+		return encoded, nil
+	}
+
+	// Name the first character that does not fit, which is more useful
+	// than the encoder's generic error.
+	for _, r := range text {
+		if _, err := c.encoding.NewEncoder().String(string(r)); err != nil {
+			return "", fmt.Errorf("character %q cannot be encoded in %s", r, c.Name)
+		}
+	}
+	return "", fmt.Errorf("cannot encode data in %s: %w", c.Name, err)
+}
+
+// Decode transcodes bytes in the charset into UTF-8 text.
+func (c Charset) Decode(data []byte) ([]byte, error) {
+	decoded, err := c.encoding.NewDecoder().Bytes(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s data: %w", c.Name, err)
+	}
+	return decoded, nil
+}
+
+// applyCharset transcodes data into the charset declared by opts.ECI,
+// leaving it untouched when no ECI is set.
+func applyCharset(data string, opts Options) (string, error) {
+	if opts.ECI == 0 {
+		return data, nil
+	}
+	if opts.Mode == ModeKanji {
+		return "", errors.New("kanji mode cannot be combined with an ECI designator")
+	}
+
+	c, ok := CharsetForECI(opts.ECI)
+	if !ok {
+		return "", fmt.Errorf("unsupported ECI designator: %d", opts.ECI)
+	}
+	return c.Encode(data)
+}
+
+// appendECI writes an ECI designator in the one, two or three byte form
+// its size requires.
+func (b *bitBuffer) appendECI(eci int) {
+	switch {
+	case eci < 1<<7:
+		b.appendBits(uint32(eci), 8)
+	case eci < 1<<14:
+		b.appendBits(uint32(0x8000|eci), 16)
+	default:
+		b.appendBits(uint32(0xc00000|eci), 24)
+	}
+}
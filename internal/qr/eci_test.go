@@ -0,0 +1,170 @@
+package qr_test
+
+import (
+	"bytes"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/eliaseffects/qr-cli/internal/qr"
+)
+
+func TestLookupCharset(t *testing.T) {
+	tests := []struct {
+		name string
+		eci  int
+	}{
+		{"ISO-8859-1", 3},
+		{"latin1", 3},
+		{"iso_8859_15", 17},
+		{"Shift-JIS", 20},
+		{"utf8", 26},
+		{"26", 26},
+	}
+
+	for _, tt := range tests {
+		c, err := qr.LookupCharset(tt.name)
+		if err != nil {
+			t.Fatalf("LookupCharset(%q) error = %v", tt.name, err)
+		}
+		if c.ECI != tt.eci {
+			t.Errorf("LookupCharset(%q).ECI = %d, want %d", tt.name, c.ECI, tt.eci)
+		}
+	}
+
+	for _, name := range []string{"ebcdic", "999"} {
+		if _, err := qr.LookupCharset(name); err == nil {
+			t.Errorf("LookupCharset(%q) expected error", name)
+		}
+	}
+}
+
+func TestECIRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  string
+		eci   int
+		bytes int
+	}{
+		{"latin1", "café crème", 3, 10},
+		{"Shift_JIS", "こんにちは、世界", 20, 16},
+		{"UTF-8", "naïve", 26, 6},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := qr.DefaultOptions()
+			opts.ECI = tt.eci
+
+			code, err := qr.Generate(tt.data, opts)
+			if err != nil {
+				t.Fatalf("Generate() error = %v", err)
+			}
+			if code.ECI != tt.eci {
+				t.Errorf("Code.ECI = %d, want %d", code.ECI, tt.eci)
+			}
+			count := 0
+			for _, seg := range code.Segments {
+				count += seg.Count
+			}
+			if count != tt.bytes {
+				t.Errorf("segments hold %d characters, want %d transcoded bytes", count, tt.bytes)
+			}
+
+			pngData, err := qr.PNG(tt.data, opts)
+			if err != nil {
+				t.Fatalf("PNG() error = %v", err)
+			}
+			img, err := png.Decode(bytes.NewReader(pngData))
+			if err != nil {
+				t.Fatalf("png.Decode() error = %v", err)
+			}
+			symbols, err := qr.DecodeSymbols(img)
+			if err != nil {
+				t.Fatalf("DecodeSymbols() error = %v", err)
+			}
+			if symbols[0].Payload != tt.data || symbols[0].ECI != tt.eci {
+				t.Fatalf("decoded %q with ECI %d, want %q with ECI %d", symbols[0].Payload, symbols[0].ECI, tt.data, tt.eci)
+			}
+		})
+	}
+}
+
+func TestECIRMQR(t *testing.T) {
+	opts := qr.DefaultOptions()
+	opts.Symbology = qr.SymbolRMQR
+	opts.ECI = 3
+
+	code, err := qr.Generate("café", opts)
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if code.ECI != 3 || code.Segments[0].Count != 4 {
+		t.Fatalf("expected 4 latin1 bytes under ECI 3, got ECI %d and %+v", code.ECI, code.Segments)
+	}
+}
+
+func TestECIUnsupported(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   string
+		modify func(*qr.Options)
+		want   string
+	}{
+		{"unrepresentable", "price: 5€", func(o *qr.Options) { o.ECI = 3 }, `'€'`},
+		{"unknown designator", "abc", func(o *qr.Options) { o.ECI = 99 }, "unsupported ECI"},
+		{"kanji mode", "漢字", func(o *qr.Options) { o.ECI = 20; o.Mode = qr.ModeKanji }, "kanji"},
+		{"micro", "abc", func(o *qr.Options) { o.ECI = 3; o.Symbology = qr.SymbolMicro; o.Level = qr.Low }, "Micro QR"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := qr.DefaultOptions()
+			tt.modify(&opts)
+			_, err := qr.Generate(tt.data, opts)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestECISplitRoundTrip(t *testing.T) {
+	payload := strings.Repeat("Grüße aus Köln, ", 250)
+	opts := qr.DefaultOptions()
+	opts.Size = 1024
+	opts.ECI = 3
+
+	parts, err := qr.Split(payload, opts)
+	if err != nil {
+		t.Fatalf("Split() error = %v", err)
+	}
+	if len(parts) < 2 {
+		t.Fatalf("expected several parts, got %d", len(parts))
+	}
+
+	var symbols []qr.Symbol
+	for _, part := range parts {
+		pngData, err := qr.PNG(part.Data, part.Options(opts))
+		if err != nil {
+			t.Fatalf("PNG() error = %v", err)
+		}
+		img, err := png.Decode(bytes.NewReader(pngData))
+		if err != nil {
+			t.Fatalf("png.Decode() error = %v", err)
+		}
+		decoded, err := qr.DecodeSymbols(img)
+		if err != nil {
+			t.Fatalf("DecodeSymbols() error = %v", err)
+		}
+		symbols = append(symbols, decoded[0])
+	}
+
+	joined, err := qr.JoinStructuredAppend(symbols)
+	if err != nil {
+		t.Fatalf("JoinStructuredAppend() error = %v", err)
+	}
+	if joined != payload {
+		t.Fatal("joined payload differs from the original")
+	}
+}
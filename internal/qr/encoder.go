@@ -0,0 +1,322 @@
+package qr
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrDataTooLong reports that a payload exceeds the capacity of the
+// requested symbol.
+var ErrDataTooLong = errors.New("data too long")
+
+// RecoveryLevel is the error correction level of a symbol.
+type RecoveryLevel int
+
+const (
+	// Low recovers roughly 7% of codewords.
+	Low RecoveryLevel = iota
+	// Medium recovers roughly 15% of codewords.
+	Medium
+	// High recovers roughly 25% of codewords.
+	High
+	// Highest recovers roughly 30% of codewords.
+	Highest
+)
+
+func (l RecoveryLevel) String() string {
+	switch l {
+	case Low:
+		return "L"
+	case High:
+		return "Q"
+	case Highest:
+		return "H"
+	default:
+		return "M"
+	}
+}
+
+// MaskAuto lets the encoder pick the mask pattern with the lowest penalty.
+const MaskAuto = -1
+
+// Code is an encoded QR symbol. Version and Mask are numbered within the
+// symbology: Micro QR versions 1-4 are M1-M4 with masks 0-3, and rMQR
+// versions 1-32 run from R7x43 to R17x139 with the single fixed mask 0.
+type Code struct {
+	Symbology Symbology
+	Version   int
+	Level     RecoveryLevel
+	Mask      int
+	Segments  []Segment
+	Append    StructuredAppend
+	ECI       int
+	GS1       bool
+
+	modules      [][]bool
+	usedBits     int
+	capacityBits int
+}
+
+// Size returns the number of modules across the symbol, excluding the
+// quiet zone. Only rMQR symbols differ in height; see Height.
+func (c *Code) Size() int {
+	return len(c.modules[0])
+}
+
+// Height returns the number of module rows, excluding the quiet zone.
+func (c *Code) Height() int {
+	return len(c.modules)
+}
+
+// VersionName returns the version as the symbology labels it, such as
+// 7, M3 or R13x77.
+func (c *Code) VersionName() string {
+	return versionName(c.Symbology, c.Version)
+}
+
+// DataBits returns the number of data bits the payload occupies, headers
+// included, and the number the symbol can hold at its level.
+func (c *Code) DataBits() (used, capacity int) {
+	return c.usedBits, c.capacityBits
+}
+
+// Bitmap returns a copy of the symbol modules without a quiet zone, with
+// true marking a dark module.
+func (c *Code) Bitmap() [][]bool {
+	bitmap := make([][]bool, len(c.modules))
+	for y, row := range c.modules {
+		bitmap[y] = append([]bool(nil), row...)
+	}
+	return bitmap
+}
+
+// encode builds a symbol for data honouring the symbology, version, mask
+// and mode constraints in opts. Unset constraints pick the smallest
+// version and the lowest-penalty mask.
+func encode(data string, opts Options) (*Code, error) {
+	switch opts.Symbology {
+	case SymbolQR:
+	case SymbolMicro:
+		return encodeMicro(data, opts)
+	case SymbolRMQR:
+		return encodeRMQR(data, opts)
+	default:
+		return nil, fmt.Errorf("unknown symbology: %d", opts.Symbology)
+	}
+
+	if opts.Mask != MaskAuto && (opts.Mask < 0 || opts.Mask > 7) {
+		return nil, fmt.Errorf("invalid mask pattern %d (want 0-7)", opts.Mask)
+	}
+
+	if opts.LogoPath != "" {
+		// Raise the level, then the version, until the modules under the
+		// logo can be recovered.
+		level, version, err := logoFit(data, opts)
+		if err != nil {
+			return nil, err
+		}
+		opts.Level, opts.Version = level, version
+	}
+
+	version, segs, err := planSymbol(data, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	level := opts.Level
+	header := symbolHeader(opts)
+	capacity := dataCodewords(version, level) * 8
+	packed := packSegments(header, segs, qrFraming(version), capacity)
+	codewords := addErrorCorrection(packed, version, level)
+
+	m := newMatrix(symbolSize(version))
+	m.drawFunctionPatterns(version)
+	m.drawCodewords(codewords)
+
+	mask := opts.Mask
+	if mask == MaskAuto {
+		best := -1
+		for candidate := 0; candidate < 8; candidate++ {
+			m.applyMask(candidate)
+			m.drawFormatBits(level, candidate)
+			if score := m.penalty(); best < 0 || score < best {
+				best = score
+				mask = candidate
+			}
+			m.applyMask(candidate)
+		}
+	}
+	m.applyMask(mask)
+	m.drawFormatBits(level, mask)
+
+	return &Code{
+		Symbology: SymbolQR,
+		Version:   version,
+		Level:     level,
+		Mask:      mask,
+		Segments:  segs,
+		Append:    opts.Append,
+		ECI:       opts.ECI,
+		GS1:       opts.GS1,
+		modules:   m.modules,
+
+		usedBits:     len(header) + segmentsBitLength(segs, qrFraming(version)),
+		capacityBits: capacity,
+	}, nil
+}
+
+// planSymbol picks the version and segmentation for data without
+// building the symbol, so callers can cheaply test whether data fits. The
+// data is transcoded into the charset of opts.ECI first.
+func planSymbol(data string, opts Options) (int, []Segment, error) {
+	data, err := applyCharset(data, opts)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	switch opts.Symbology {
+	case SymbolMicro:
+		return planMicro(data, opts)
+	case SymbolRMQR:
+		return planRMQR(data, opts)
+	}
+
+	level := opts.Level
+	if level < Low || level > Highest {
+		return 0, nil, fmt.Errorf("invalid error correction level: %d", level)
+	}
+	if err := opts.Append.validate(); err != nil {
+		return 0, nil, err
+	}
+
+	minV, maxV := minVersion, maxVersion
+	if opts.Version != 0 {
+		if opts.Version < minVersion || opts.Version > maxVersion {
+			return 0, nil, fmt.Errorf("invalid version %d (want %d-%d)", opts.Version, minVersion, maxVersion)
+		}
+		minV, maxV = opts.Version, opts.Version
+	}
+
+	header := len(symbolHeader(opts))
+	var segs []Segment
+	for v := minV; v <= maxV; v++ {
+		// Character count field widths only change at versions 10 and 27,
+		// so the segmentation is reused within each group.
+		if v == minV || v == 10 || v == 27 {
+			var err error
+			segs, err = makeSegments(data, opts.Mode, qrFraming(v), opts.GS1)
+			if err != nil {
+				return 0, nil, err
+			}
+		}
+		used := segmentsBitLength(segs, qrFraming(v))
+		if used >= 0 && header+used <= dataCodewords(v, level)*8 {
+			return v, segs, nil
+		}
+	}
+
+	if opts.Version != 0 {
+		return 0, nil, fmt.Errorf("%w for version %d at level %s", ErrDataTooLong, opts.Version, level)
+	}
+	return 0, nil, fmt.Errorf("%w for a QR code at level %s", ErrDataTooLong, level)
+}
+
+// symbolHeader returns the bits that precede the data segments: the
+// Structured Append header, the ECI designator and the FNC1 in first
+// position indicator, in that order. rMQR uses 3-bit mode indicators.
+func symbolHeader(opts Options) bitBuffer {
+	var bits bitBuffer
+	if opts.Append.Total > 0 {
+		bits.appendBits(0x3, 4)
+		bits.appendBits(uint32(opts.Append.Index), 4)
+		bits.appendBits(uint32(opts.Append.Total-1), 4)
+		bits.appendBits(uint32(opts.Append.Parity), 8)
+	}
+	if opts.ECI != 0 {
+		if opts.Symbology == SymbolRMQR {
+			bits.appendBits(0x7, 3)
+		} else {
+			bits.appendBits(0x7, 4)
+		}
+		bits.appendECI(opts.ECI)
+	}
+	if opts.GS1 {
+		if opts.Symbology == SymbolRMQR {
+			bits.appendBits(0x5, 3)
+		} else {
+			bits.appendBits(0x5, 4)
+		}
+	}
+	return bits
+}
+
+// packSegments concatenates the segments, adds the terminator and pads
+// the result to capacity bits. A capacity that is not a whole number of
+// bytes ends in a 4-bit codeword (Micro QR M1 and M3), which is returned in
+// the high nibble of the last byte.
+func packSegments(header bitBuffer, segs []Segment, f framing, capacity int) []byte {
+	bits := append(bitBuffer(nil), header...)
+	for _, seg := range segs {
+		value, n := f.modeIndicator(seg.Mode)
+		bits.appendBits(value, n)
+		bits.appendBits(uint32(seg.Count), f.charCountBits(seg.Mode))
+		bits = append(bits, seg.bits...)
+	}
+
+	bits.appendBits(0, min(f.terminatorBits(), capacity-len(bits)))
+	bits.appendBits(0, min((8-len(bits)%8)%8, capacity-len(bits)))
+	for pad := uint32(0xec); capacity-len(bits) >= 8; pad ^= 0xec ^ 0x11 {
+		bits.appendBits(pad, 8)
+	}
+	bits.appendBits(0, capacity-len(bits))
+
+	result := make([]byte, (len(bits)+7)/8)
+	for i, b := range bits {
+		if b {
+			result[i>>3] |= 1 << uint(7-i&7)
+		}
+	}
+	return result
+}
+
+// addErrorCorrection splits data into blocks, appends Reed-Solomon
+// codewords to each and interleaves the blocks into the final sequence.
+func addErrorCorrection(data []byte, version int, level RecoveryLevel) []byte {
+	return interleaveBlocks(data, eccBlockCount[level][version], eccCodewordsPerBlock[level][version], rawDataModules(version)/8)
+}
+
+// interleaveBlocks spreads data over numBlocks blocks of eccLen error
+// correction codewords each, filling rawCodewords in total. Longer blocks
+// come last, one data codeword ahead of the shorter ones.
+func interleaveBlocks(data []byte, numBlocks, eccLen, rawCodewords int) []byte {
+	numShortBlocks := numBlocks - rawCodewords%numBlocks
+	shortBlockLen := rawCodewords / numBlocks
+
+	divisor := rsDivisor(eccLen)
+	blocks := make([][]byte, numBlocks)
+	k := 0
+	for i := range blocks {
+		n := shortBlockLen - eccLen
+		if i >= numShortBlocks {
+			n++
+		}
+		block := append([]byte(nil), data[k:k+n]...)
+		k += n
+		ecc := rsRemainder(block, divisor)
+		if i < numShortBlocks {
+			block = append(block, 0)
+		}
+		blocks[i] = append(block, ecc...)
+	}
+
+	result := make([]byte, 0, rawCodewords)
+	for i := range blocks[0] {
+		for j, block := range blocks {
+			// Short blocks carry a placeholder byte that is skipped.
+			if i != shortBlockLen-eccLen || j >= numShortBlocks {
+				result = append(result, block[i])
+			}
+		}
+	}
+	return result
+}
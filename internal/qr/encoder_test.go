@@ -0,0 +1,198 @@
+package qr_test
+
+import (
+	"bytes"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/eliaseffects/qr-cli/internal/qr"
+)
+
+func decodePNG(t *testing.T, data []byte) []string {
+	t.Helper()
+
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("png.Decode() error = %v", err)
+	}
+	results, err := qr.DecodeImage(img)
+	if err != nil {
+		t.Fatalf("DecodeImage() error = %v", err)
+	}
+	return results
+}
+
+func TestGenerateRoundTrip(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		level   qr.RecoveryLevel
+		version int
+		mask    int
+		mode    qr.Mode
+	}{
+		{"numeric", "0123456789012345", qr.Medium, 0, qr.MaskAuto, qr.ModeNumeric},
+		{"alphanumeric", "HELLO WORLD $%*+-./:", qr.High, 0, qr.MaskAuto, qr.ModeAlphanumeric},
+		{"byte", "https://example.com/path?q=1", qr.Low, 0, qr.MaskAuto, qr.ModeByte},
+		{"mixed auto", "ORDER 0000123456789 shipped", qr.Highest, 0, qr.MaskAuto, qr.ModeAuto},
+		{"utf-8", "こんにちは世界", qr.Medium, 0, qr.MaskAuto, qr.ModeAuto},
+		{"fixed version", "https://example.com", qr.Medium, 10, qr.MaskAuto, qr.ModeAuto},
+		{"version with info", strings.Repeat("x", 300), qr.Low, 0, qr.MaskAuto, qr.ModeAuto},
+		{"multi block", strings.Repeat("QR CODE ", 60), qr.High, 0, qr.MaskAuto, qr.ModeAuto},
+	}
+
+	for mask := 0; mask < 8; mask++ {
+		tests = append(tests, struct {
+			name    string
+			data    string
+			level   qr.RecoveryLevel
+			version int
+			mask    int
+			mode    qr.Mode
+		}{"mask", "https://example.com", qr.Medium, 0, mask, qr.ModeAuto})
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := qr.DefaultOptions()
+			opts.Size = 1024
+			opts.Level = tt.level
+			opts.Version = tt.version
+			opts.Mask = tt.mask
+			opts.Mode = tt.mode
+
+			code, err := qr.Generate(tt.data, opts)
+			if err != nil {
+				t.Fatalf("Generate() error = %v", err)
+			}
+			if tt.version != 0 && code.Version != tt.version {
+				t.Errorf("Version = %d, want %d", code.Version, tt.version)
+			}
+			if tt.mask != qr.MaskAuto && code.Mask != tt.mask {
+				t.Errorf("Mask = %d, want %d", code.Mask, tt.mask)
+			}
+			if code.Size() != code.Version*4+17 {
+				t.Errorf("Size() = %d for version %d", code.Size(), code.Version)
+			}
+
+			pngData, err := qr.PNG(tt.data, opts)
+			if err != nil {
+				t.Fatalf("PNG() error = %v", err)
+			}
+			results := decodePNG(t, pngData)
+			if results[0] != tt.data {
+				t.Fatalf("decoded %q, want %q", results[0], tt.data)
+			}
+		})
+	}
+}
+
+func TestGenerateSmallestVersion(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  string
+		level qr.RecoveryLevel
+		want  int
+	}{
+		// Version 1-L holds 41 digits, 25 alphanumerics or 17 bytes.
+		{"41 digits", strings.Repeat("7", 41), qr.Low, 1},
+		{"42 digits", strings.Repeat("7", 42), qr.Low, 2},
+		{"25 alphanumerics", strings.Repeat("A", 25), qr.Low, 1},
+		{"17 bytes", strings.Repeat("a", 17), qr.Low, 1},
+		{"18 bytes", strings.Repeat("a", 18), qr.Low, 2},
+		// As a single byte segment this needs version 2; splitting off
+		// the digits fits it in version 1.
+		{"mixed segments", "a" + strings.Repeat("1", 30), qr.Low, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := qr.DefaultOptions()
+			opts.Level = tt.level
+			code, err := qr.Generate(tt.data, opts)
+			if err != nil {
+				t.Fatalf("Generate() error = %v", err)
+			}
+			if code.Version != tt.want {
+				t.Errorf("Version = %d, want %d", code.Version, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateMixedSegments(t *testing.T) {
+	opts := qr.DefaultOptions()
+	code, err := qr.Generate("item-"+strings.Repeat("9", 20), opts)
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+
+	var modes []qr.Mode
+	for _, seg := range code.Segments {
+		modes = append(modes, seg.Mode)
+	}
+	if len(modes) != 2 || modes[0] != qr.ModeByte || modes[1] != qr.ModeNumeric {
+		t.Fatalf("segment modes = %v, want [byte numeric]", modes)
+	}
+}
+
+func TestGenerateConstraintErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  string
+		apply func(*qr.Options)
+	}{
+		{"numeric with letters", "12AB", func(o *qr.Options) { o.Mode = qr.ModeNumeric }},
+		{"alphanumeric lowercase", "hello", func(o *qr.Options) { o.Mode = qr.ModeAlphanumeric }},
+		{"kanji with ascii", "abc", func(o *qr.Options) { o.Mode = qr.ModeKanji }},
+		{"version too small", strings.Repeat("a", 100), func(o *qr.Options) { o.Version = 1 }},
+		{"version out of range", "a", func(o *qr.Options) { o.Version = 41 }},
+		{"mask out of range", "a", func(o *qr.Options) { o.Mask = 8 }},
+		{"too long", strings.Repeat("a", 3000), func(o *qr.Options) {}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := qr.DefaultOptions()
+			tt.apply(&opts)
+			if _, err := qr.Generate(tt.data, opts); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func TestGenerateKanji(t *testing.T) {
+	opts := qr.DefaultOptions()
+	opts.Mode = qr.ModeKanji
+	code, err := qr.Generate("日本語", opts)
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if len(code.Segments) != 1 || code.Segments[0].Mode != qr.ModeKanji || code.Segments[0].Count != 3 {
+		t.Fatalf("unexpected segments: %+v", code.Segments)
+	}
+}
+
+func TestDataBits(t *testing.T) {
+	opts := qr.DefaultOptions()
+	code, err := qr.Generate("HELLO WORLD", opts)
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	// Mode indicator, 9-bit count and 11 characters at 5.5 bits each.
+	used, capacity := code.DataBits()
+	if used != 4+9+61 || capacity != 16*8 {
+		t.Fatalf("DataBits() = %d, %d, want 74, 128", used, capacity)
+	}
+
+	opts.ECI = 26
+	code, err = qr.Generate("HELLO WORLD", opts)
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if used, _ := code.DataBits(); used != 4+8+74 {
+		t.Fatalf("DataBits() with ECI = %d, want 86", used)
+	}
+}
@@ -0,0 +1,124 @@
+package qr
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+)
+
+// EPS renders a QR code as Encapsulated PostScript for prepress tools.
+// The symbol, quiet zone included, is PhysicalSize millimetres wide, or
+// Size points when that is unset, and the bounding box fits it exactly.
+// Colours given as color.CMYK are written with setcmykcolor, others as
+// RGB; PostScript has no transparency, so alpha is dropped and a fully
+// transparent background is left unpainted.
+func EPS(data string, opts Options) ([]byte, error) {
+	if opts.shaped() {
+		return nil, errors.New("module and eye styles are not supported for EPS output")
+	}
+	if opts.ForegroundGradient != nil {
+		return nil, errors.New("gradients are not supported for EPS output")
+	}
+	if opts.LogoPath != "" {
+		return nil, errors.New("logo overlay is not supported for EPS output")
+	}
+	if opts.Caption != "" || opts.Frame != FrameNone {
+		return nil, errors.New("captions and frames are not supported for EPS output")
+	}
+
+	bitmap, err := Bitmap(data, opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(bitmap) == 0 {
+		return nil, fmt.Errorf("empty QR bitmap")
+	}
+
+	cols, rows := len(bitmap[0]), len(bitmap)
+	module := moduleMM(opts, bitmap) * pointsPerMM
+	width, height := float64(cols)*module, float64(rows)*module
+
+	var buf bytes.Buffer
+	buf.WriteString("%!PS-Adobe-3.0 EPSF-3.0\n")
+	buf.WriteString("%%Creator: qr-cli\n")
+	fmt.Fprintf(&buf, "%%%%BoundingBox: 0 0 %d %d\n", int(math.Ceil(width-1e-6)), int(math.Ceil(height-1e-6)))
+	fmt.Fprintf(&buf, "%%%%HiResBoundingBox: 0 0 %s %s\n", pdfNum(width), pdfNum(height))
+	buf.WriteString("%%LanguageLevel: 2\n")
+	buf.WriteString("%%Pages: 1\n")
+	buf.WriteString("%%EndComments\n")
+	buf.WriteString("%%BeginProlog\n/r { rectfill } bind def\n%%EndProlog\n")
+	buf.WriteString("%%Page: 1 1\n")
+
+	// Draw in module units with y running down, as in the bitmap.
+	fmt.Fprintf(&buf, "gsave\n0 %s translate\n%s %s scale\n", pdfNum(height), pdfNum(module), pdfNum(-module))
+	if colorToRGBA(opts.BackgroundColor).A != 0 {
+		fmt.Fprintf(&buf, "%s\n0 0 %d %d r\n", epsColor(opts.BackgroundColor), cols, rows)
+	}
+	fmt.Fprintf(&buf, "%s\n", epsColor(opts.ForegroundColor))
+	for _, rect := range mergeRects(bitmap) {
+		fmt.Fprintf(&buf, "%d %d %d %d r\n", rect.Min.X, rect.Min.Y, rect.Dx(), rect.Dy())
+	}
+	buf.WriteString("grestore\nshowpage\n")
+	buf.WriteString("%%Trailer\n%%EOF\n")
+	return buf.Bytes(), nil
+}
+
+// epsColor returns the PostScript operator that sets c as the fill colour.
+func epsColor(c color.Color) string {
+	if k, ok := c.(color.CMYK); ok {
+		return fmt.Sprintf("%s %s %s %s setcmykcolor",
+			epsChannel(k.C), epsChannel(k.M), epsChannel(k.Y), epsChannel(k.K))
+	}
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	return fmt.Sprintf("%s %s %s setrgbcolor", epsChannel(n.R), epsChannel(n.G), epsChannel(n.B))
+}
+
+func epsChannel(v uint8) string {
+	return pdfNum(float64(v) / 255)
+}
+
+// mergeRects covers the dark modules of bitmap with non-overlapping
+// rectangles, growing each one right along its row and then down while
+// the rows below are dark across the same span.
+func mergeRects(bitmap [][]bool) []image.Rectangle {
+	used := make([][]bool, len(bitmap))
+	for y := range used {
+		used[y] = make([]bool, len(bitmap[y]))
+	}
+	free := func(x, y int) bool {
+		return bitmap[y][x] && !used[y][x]
+	}
+
+	var rects []image.Rectangle
+	for y := range bitmap {
+		for x := range bitmap[y] {
+			if !free(x, y) {
+				continue
+			}
+			x1 := x + 1
+			for x1 < len(bitmap[y]) && free(x1, y) {
+				x1++
+			}
+			y1 := y + 1
+		grow:
+			for y1 < len(bitmap) {
+				for i := x; i < x1; i++ {
+					if !free(i, y1) {
+						break grow
+					}
+				}
+				y1++
+			}
+			for j := y; j < y1; j++ {
+				for i := x; i < x1; i++ {
+					used[j][i] = true
+				}
+			}
+			rects = append(rects, image.Rect(x, y, x1, y1))
+		}
+	}
+	return rects
+}
@@ -0,0 +1,90 @@
+package qr_test
+
+import (
+	"bytes"
+	"image/color"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/eliaseffects/qr-cli/internal/qr"
+)
+
+func TestEPS(t *testing.T) {
+	opts := qr.DefaultOptions()
+	opts.PhysicalSize = 25.4 // 72 points
+	opts.ForegroundColor = color.CMYK{K: 0xff}
+
+	eps, err := qr.EPS("https://example.com", opts)
+	if err != nil {
+		t.Fatalf("EPS() error = %v", err)
+	}
+	for _, want := range []string{"%!PS-Adobe-3.0 EPSF-3.0\n", "%%BoundingBox: 0 0 72 72\n", "0 0 0 1 setcmykcolor\n", "1 1 1 setrgbcolor\n"} {
+		if !bytes.Contains(eps, []byte(want)) {
+			t.Errorf("EPS missing %q", want)
+		}
+	}
+	if !bytes.HasSuffix(eps, []byte("%%EOF\n")) {
+		t.Error("EPS missing the EOF trailer")
+	}
+
+	bitmap, err := qr.Bitmap("https://example.com", opts)
+	if err != nil {
+		t.Fatalf("Bitmap() error = %v", err)
+	}
+
+	// The rectangles after the foreground colour must cover every dark
+	// module exactly once, and fewer of them than there are modules.
+	body := string(eps[bytes.Index(eps, []byte("setcmykcolor")):])
+	got := make([][]int, len(bitmap))
+	for i := range got {
+		got[i] = make([]int, len(bitmap[i]))
+	}
+	rects := regexp.MustCompile(`(?m)^(\d+) (\d+) (\d+) (\d+) r$`).FindAllStringSubmatch(body, -1)
+	dark := 0
+	for _, m := range rects {
+		x, _ := strconv.Atoi(m[1])
+		y, _ := strconv.Atoi(m[2])
+		w, _ := strconv.Atoi(m[3])
+		h, _ := strconv.Atoi(m[4])
+		for j := y; j < y+h; j++ {
+			for i := x; i < x+w; i++ {
+				got[j][i]++
+			}
+		}
+	}
+	for y := range bitmap {
+		for x := range bitmap[y] {
+			want := 0
+			if bitmap[y][x] {
+				want = 1
+				dark++
+			}
+			if got[y][x] != want {
+				t.Fatalf("module (%d, %d) covered %d times, want %d", x, y, got[y][x], want)
+			}
+		}
+	}
+	if len(rects)*2 > dark {
+		t.Errorf("%d rectangles for %d dark modules, want them merged", len(rects), dark)
+	}
+}
+
+func TestEPSUnsupported(t *testing.T) {
+	opts := qr.DefaultOptions()
+	opts.ModuleStyle = qr.StyleDot
+	if _, err := qr.EPS("https://example.com", opts); err == nil || !strings.Contains(err.Error(), "EPS") {
+		t.Errorf("EPS() with a module style error = %v, want an EPS error", err)
+	}
+
+	opts = qr.DefaultOptions()
+	opts.BackgroundColor = color.NRGBA{}
+	eps, err := qr.EPS("https://example.com", opts)
+	if err != nil {
+		t.Fatalf("EPS() error = %v", err)
+	}
+	if bytes.Contains(eps, []byte("1 1 1 setrgbcolor")) {
+		t.Error("transparent background was painted")
+	}
+}
@@ -0,0 +1,122 @@
+package qr
+
+import (
+	"fmt"
+	"math"
+)
+
+// Fit selects how a PNG meets a pixel size that is not a whole number of
+// pixels per module.
+type Fit int
+
+const (
+	// FitPad draws whole-pixel modules and centres them, padding the rest
+	// of the size with background.
+	FitPad Fit = iota
+	// FitExact spreads the modules over exactly the size; modules differ
+	// by at most a pixel but keep crisp edges.
+	FitExact
+	// FitRoundUp grows the image to the next whole-module multiple.
+	FitRoundUp
+	// FitRoundDown shrinks the image to the previous whole-module multiple.
+	FitRoundDown
+)
+
+func (f Fit) String() string {
+	switch f {
+	case FitExact:
+		return "exact"
+	case FitRoundUp:
+		return "round-up"
+	case FitRoundDown:
+		return "round-down"
+	default:
+		return "pad"
+	}
+}
+
+// PNGSize returns the width and height in pixels of the PNG that PNG
+// renders for data, which differ from Size under FitRoundUp and
+// FitRoundDown, for a print size, or with a caption or frame.
+func PNGSize(data string, opts Options) (int, int, error) {
+	bitmap, err := Bitmap(data, opts)
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(bitmap) == 0 {
+		return 0, 0, fmt.Errorf("empty QR bitmap")
+	}
+	layout := newPNGLayout(opts, len(bitmap[0]), len(bitmap))
+	deco, err := newDecoration(opts, float64(layout.width), float64(layout.height), layout.scale(), true)
+	if err != nil {
+		return 0, 0, err
+	}
+	if deco != nil {
+		return int(math.Round(deco.width)), int(math.Round(deco.height)), nil
+	}
+	return layout.width, layout.height, nil
+}
+
+// pngLayout places the modules of a symbol on a PNG canvas. The longer
+// side of the symbol, total modules, covers span pixels.
+type pngLayout struct {
+	width, height int
+	total         int
+	span          int
+	pad           int
+}
+
+func newPNGLayout(opts Options, cols, rows int) pngLayout {
+	total := max(cols, rows)
+	size := opts.Size
+	if size <= 0 {
+		size = DefaultOptions().Size
+	}
+	fit := opts.Fit
+	if opts.PhysicalSize > 0 && opts.DPI > 0 {
+		// A print size rounds to the nearest whole module unless a fit
+		// says otherwise, so every module prints at the same size.
+		target := opts.PhysicalSize / 25.4 * float64(opts.DPI)
+		size = int(math.Round(target))
+		if fit == FitPad {
+			size = max(int(math.Round(target/float64(total))), 1) * total
+		}
+	}
+	size = max(size, total)
+
+	var span int
+	switch fit {
+	case FitExact:
+		span = size
+	case FitRoundUp:
+		span = (size + total - 1) / total * total
+	default:
+		span = size / total * total
+	}
+	canvas := span
+	if fit == FitPad {
+		canvas = size
+	}
+
+	// The longer side fills the canvas; a rectangular symbol keeps the
+	// same margin on its shorter side.
+	l := pngLayout{total: total, span: span, pad: (canvas - span) / 2}
+	l.width = l.extent(cols) + canvas - span
+	l.height = l.extent(rows) + canvas - span
+	return l
+}
+
+// extent returns the pixels covered by n modules.
+func (l pngLayout) extent(n int) int {
+	return n * l.span / l.total
+}
+
+// edge returns the pixel position of the leading edge of module i.
+func (l pngLayout) edge(i int) int {
+	return l.pad + l.extent(i)
+}
+
+// scale returns the average number of pixels per module.
+func (l pngLayout) scale() float64 {
+	return float64(l.span) / float64(l.total)
+}
@@ -1,6 +1,7 @@
 package qr
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 )
@@ -29,6 +30,64 @@ func (w WifiConfig) String() string {
 	return fmt.Sprintf("WIFI:T:%s;S:%s;P:%s;%s%s", w.Security, ssid, pass, hidden, suffix)
 }
 
+// ParseWifiSecurity normalises a security type to WPA, WEP or nopass.
+// Empty means WPA; "none" and "open" mean nopass.
+func ParseWifiSecurity(s string) (string, error) {
+	switch strings.ToUpper(strings.TrimSpace(s)) {
+	case "", "WPA", "WPA2", "WPA3":
+		return "WPA", nil
+	case "WEP":
+		return "WEP", nil
+	case "NOPASS", "NONE", "OPEN":
+		return "nopass", nil
+	default:
+		return "", fmt.Errorf("invalid security type: %s (want WPA, WEP or nopass)", s)
+	}
+}
+
+// Validate checks that a phone could join the network: an SSID of at
+// most 32 bytes and, for WPA and WEP, a password of a length the
+// standard allows. An open network must not carry a password.
+func (w WifiConfig) Validate() error {
+	if w.SSID == "" {
+		return errors.New("SSID is required")
+	}
+	if len(w.SSID) > 32 {
+		return fmt.Errorf("SSID is %d bytes, longer than 32", len(w.SSID))
+	}
+	switch w.Security {
+	case "WPA":
+		// A passphrase of 8-63 characters, or a 64-digit hex key.
+		if n := len(w.Password); (n < 8 || n > 63) && !(n == 64 && isHex(w.Password)) {
+			return fmt.Errorf("WPA password must be 8-63 characters or 64 hex digits, got %d", n)
+		}
+	case "WEP":
+		// A 40 or 104 bit key as ASCII or hex.
+		switch n := len(w.Password); {
+		case n == 5 || n == 13:
+		case (n == 10 || n == 26) && isHex(w.Password):
+		default:
+			return errors.New("WEP password must be 5 or 13 characters, or 10 or 26 hex digits")
+		}
+	case "nopass":
+		if w.Password != "" {
+			return errors.New("an open network has no password")
+		}
+	default:
+		return fmt.Errorf("invalid security type: %s (want WPA, WEP or nopass)", w.Security)
+	}
+	return nil
+}
+
+func isHex(s string) bool {
+	for _, r := range s {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", r) {
+			return false
+		}
+	}
+	return true
+}
+
 type VCard struct {
 	Name    string
 	Phone   string
@@ -76,6 +135,41 @@ func (v VCard) String() string {
 	return b.String()
 }
 
+// Validate checks that a contact has a name and that its email address
+// and phone number are plausible.
+func (v VCard) Validate() error {
+	if strings.TrimSpace(v.Name) == "" {
+		return errors.New("name is required")
+	}
+	if v.Email != "" {
+		local, domain, ok := strings.Cut(v.Email, "@")
+		if !ok || local == "" || !strings.Contains(domain, ".") || strings.ContainsAny(v.Email, " \t\n;") {
+			return fmt.Errorf("invalid email address: %s", v.Email)
+		}
+	}
+	if v.Phone != "" {
+		digits := 0
+		for _, r := range v.Phone {
+			switch {
+			case r >= '0' && r <= '9':
+				digits++
+			case strings.ContainsRune("+-() .", r):
+			default:
+				return fmt.Errorf("invalid phone number: %s", v.Phone)
+			}
+		}
+		if digits < 3 {
+			return fmt.Errorf("invalid phone number: %s", v.Phone)
+		}
+	}
+	for _, field := range []string{v.Name, v.Phone, v.Email, v.Org, v.Title, v.URL, v.Address} {
+		if strings.ContainsAny(field, "\r\n") {
+			return errors.New("vCard fields must be on one line")
+		}
+	}
+	return nil
+}
+
 func escapeWifi(s string) string {
 	s = strings.ReplaceAll(s, "\\", "\\\\")
 	s = strings.ReplaceAll(s, ";", "\\;")
@@ -52,3 +52,57 @@ func TestVCard(t *testing.T) {
 		t.Error("missing TEL field")
 	}
 }
+
+func TestWifiValidate(t *testing.T) {
+	tests := []struct {
+		config qr.WifiConfig
+		ok     bool
+	}{
+		{qr.WifiConfig{SSID: "Guest", Password: "hunter2hunter2", Security: "WPA"}, true},
+		{qr.WifiConfig{SSID: "Guest", Password: strings.Repeat("ab", 32), Security: "WPA"}, true},
+		{qr.WifiConfig{SSID: "Guest", Security: "nopass"}, true},
+		{qr.WifiConfig{SSID: "Guest", Password: "abcde", Security: "WEP"}, true},
+		{qr.WifiConfig{SSID: "Guest", Password: "0123456789", Security: "WEP"}, true},
+		{qr.WifiConfig{Password: "hunter2hunter2", Security: "WPA"}, false},
+		{qr.WifiConfig{SSID: strings.Repeat("x", 33), Security: "nopass"}, false},
+		{qr.WifiConfig{SSID: "Guest", Password: "short", Security: "WPA"}, false},
+		{qr.WifiConfig{SSID: "Guest", Password: strings.Repeat("zz", 32), Security: "WPA"}, false},
+		{qr.WifiConfig{SSID: "Guest", Password: "abcdef", Security: "WEP"}, false},
+		{qr.WifiConfig{SSID: "Guest", Password: "secret", Security: "nopass"}, false},
+		{qr.WifiConfig{SSID: "Guest", Security: "WPA4"}, false},
+	}
+	for _, tt := range tests {
+		if err := tt.config.Validate(); (err == nil) != tt.ok {
+			t.Errorf("Validate(%+v) error = %v, want ok %v", tt.config, err, tt.ok)
+		}
+	}
+
+	for in, want := range map[string]string{"": "WPA", "wpa2": "WPA", "WEP": "WEP", "nopass": "nopass", "open": "nopass"} {
+		if got, err := qr.ParseWifiSecurity(in); err != nil || got != want {
+			t.Errorf("ParseWifiSecurity(%q) = %q, %v, want %q", in, got, err, want)
+		}
+	}
+	if _, err := qr.ParseWifiSecurity("WPA4"); err == nil {
+		t.Error("expected an error for an unknown security type")
+	}
+}
+
+func TestVCardValidate(t *testing.T) {
+	tests := []struct {
+		card qr.VCard
+		ok   bool
+	}{
+		{qr.VCard{Name: "Ada Lovelace", Email: "ada@example.com", Phone: "+44 (20) 7946-0000"}, true},
+		{qr.VCard{Name: "Ada Lovelace"}, true},
+		{qr.VCard{Email: "ada@example.com"}, false},
+		{qr.VCard{Name: "Ada", Email: "ada.example.com"}, false},
+		{qr.VCard{Name: "Ada", Email: "ada@localhost"}, false},
+		{qr.VCard{Name: "Ada", Phone: "call me"}, false},
+		{qr.VCard{Name: "Ada", Org: "Analytical\nEngines"}, false},
+	}
+	for _, tt := range tests {
+		if err := tt.card.Validate(); (err == nil) != tt.ok {
+			t.Errorf("Validate(%+v) error = %v, want ok %v", tt.card, err, tt.ok)
+		}
+	}
+}
@@ -3,30 +3,19 @@ package qr
 import (
 	"errors"
 	"strings"
-
-	qrcode "github.com/skip2/go-qrcode"
 )
 
-// Generate creates a QRCode instance from input data.
-func Generate(data string, opts Options) (*qrcode.QRCode, error) {
+// Generate encodes input data into a QR symbol.
+func Generate(data string, opts Options) (*Code, error) {
 	if strings.TrimSpace(data) == "" {
 		return nil, errors.New("data is empty")
 	}
 
-	code, err := qrcode.New(data, opts.Level)
-	if err != nil {
-		return nil, err
-	}
-
-	// We'll handle borders ourselves for consistent sizing.
-	code.DisableBorder = true
-	code.ForegroundColor = opts.ForegroundColor
-	code.BackgroundColor = opts.BackgroundColor
-
-	return code, nil
+	return encode(data, opts)
 }
 
 // Bitmap returns a QR bitmap with the configured border size applied.
+// Rows may be wider than the bitmap is tall for rMQR symbols.
 func Bitmap(data string, opts Options) ([][]bool, error) {
 	if opts.BorderSize < 0 {
 		opts.BorderSize = 0
@@ -51,11 +40,12 @@ func addBorder(bitmap [][]bool, border int) [][]bool {
 	}
 
 	width := len(bitmap[0])
-	total := width + border*2
+	totalWidth := width + border*2
+	totalHeight := len(bitmap) + border*2
 
-	bordered := make([][]bool, total)
-	for y := 0; y < total; y++ {
-		row := make([]bool, total)
+	bordered := make([][]bool, totalHeight)
+	for y := 0; y < totalHeight; y++ {
+		row := make([]bool, totalWidth)
 		if y >= border && y < border+len(bitmap) {
 			copy(row[border:border+width], bitmap[y-border])
 		}
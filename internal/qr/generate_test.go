@@ -1,9 +1,16 @@
 package qr_test
 
 import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/eliaseffects/qr-cli/internal/qr"
@@ -68,6 +75,58 @@ func TestPNGWithLogo(t *testing.T) {
 	}
 }
 
+func TestLogoConcurrentAndReloaded(t *testing.T) {
+	logoPath := filepath.Join(t.TempDir(), "logo.png")
+	writeLogo := func(c color.Color, size int) {
+		img := image.NewRGBA(image.Rect(0, 0, size, size))
+		draw.Draw(img, img.Bounds(), &image.Uniform{C: c}, image.Point{}, draw.Src)
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(logoPath, buf.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeLogo(color.RGBA{R: 0xff, A: 0xff}, 40)
+
+	opts := qr.DefaultOptions()
+	opts.LogoPath = logoPath
+	want, err := qr.PNG("https://example.com", opts)
+	if err != nil {
+		t.Fatalf("PNG() error = %v", err)
+	}
+
+	// Codes rendered at once share the cached logo and match.
+	results := make([][]byte, 8)
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], _ = qr.PNG("https://example.com", opts)
+		}()
+	}
+	wg.Wait()
+	for i, got := range results {
+		if !bytes.Equal(got, want) {
+			t.Fatalf("concurrent render %d differs", i)
+		}
+	}
+
+	// A changed file is read again. The new logo is a different size so
+	// the change shows even within the file system's timestamp resolution.
+	writeLogo(color.RGBA{B: 0xff, A: 0xff}, 64)
+	got, err := qr.PNG("https://example.com", opts)
+	if err != nil {
+		t.Fatalf("PNG() error = %v", err)
+	}
+	center := decodeImage(t, got).At(128, 128)
+	if r, _, b, _ := center.RGBA(); b>>8 != 0xff || r != 0 {
+		t.Errorf("centre pixel = %v, want the new blue logo", center)
+	}
+}
+
 func TestSVGWithLogo(t *testing.T) {
 	logoPath := filepath.Join("..", "..", "testdata", "logo.png")
 	if _, err := os.Stat(logoPath); err != nil {
@@ -84,3 +143,195 @@ func TestSVGWithLogo(t *testing.T) {
 		t.Fatal("SVG output missing embedded logo data")
 	}
 }
+
+func TestLogoRaisesLevel(t *testing.T) {
+	payload := "https://example.com/some/long/path"
+	opts := qr.DefaultOptions()
+	opts.Size = 512
+	opts.Level = qr.Low
+	opts.LogoPath = filepath.Join("..", "..", "testdata", "logo.png")
+	opts.LogoScale = 0.3
+
+	code, err := qr.Generate(payload, opts)
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if code.Level == qr.Low {
+		t.Fatal("expected the level to be raised for the logo")
+	}
+
+	pngData, err := qr.PNG(payload, opts)
+	if err != nil {
+		t.Fatalf("PNG() error = %v", err)
+	}
+	if results := decodePNG(t, pngData); results[0] != payload {
+		t.Fatalf("expected %q, got %q", payload, results[0])
+	}
+
+	opts.LogoScale = 0.4
+	opts.Version = 1
+	if _, err := qr.Generate("hi", opts); !errors.Is(err, qr.ErrLogoTooLarge) {
+		t.Fatalf("expected ErrLogoTooLarge for a pinned version 1, got %v", err)
+	}
+}
+
+func TestLogoMisdecodeProtection(t *testing.T) {
+	// At 512 px a 0.1 logo hides five codewords of a version 1 symbol.
+	// Level M has ten error correction codewords, but two of them only
+	// guard against misdecodes, so the four it can correct are not enough.
+	opts := qr.DefaultOptions()
+	opts.Size = 512
+	opts.Version = 1
+	opts.Level = qr.Medium
+	opts.LogoPath = filepath.Join("..", "..", "testdata", "logo.png")
+	opts.LogoScale = 0.1
+
+	code, err := qr.Generate("hi", opts)
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if code.Level != qr.High {
+		t.Fatalf("level %s, want Q for a logo hiding five codewords", code.Level)
+	}
+
+	pngData, err := qr.PNG("hi", opts)
+	if err != nil {
+		t.Fatalf("PNG() error = %v", err)
+	}
+	if results := decodePNG(t, pngData); results[0] != "hi" {
+		t.Fatalf("expected %q, got %q", "hi", results[0])
+	}
+}
+
+func TestTransparentBackground(t *testing.T) {
+	opts := qr.DefaultOptions()
+	opts.BackgroundColor = color.Transparent
+	opts.ForegroundColor = color.NRGBA{R: 0x1a, G: 0x4f, B: 0x8b, A: 0x80}
+
+	svg, err := qr.SVG("alpha", opts)
+	if err != nil {
+		t.Fatalf("SVG() error = %v", err)
+	}
+	if strings.Contains(string(svg), `<rect width="100%"`) {
+		t.Error("SVG kept a background rect for a transparent background")
+	}
+	if !strings.Contains(string(svg), `fill="#1a4f8b" fill-opacity="0.502"`) {
+		t.Error("SVG lost the foreground alpha")
+	}
+
+	pngData, err := qr.PNG("alpha", opts)
+	if err != nil {
+		t.Fatalf("PNG() error = %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(pngData))
+	if err != nil {
+		t.Fatalf("png.Decode() error = %v", err)
+	}
+	if _, _, _, a := img.At(0, 0).RGBA(); a != 0 {
+		t.Errorf("quiet zone alpha = %d, want 0", a)
+	}
+	// At 8 pixels a module, centred with 12 pixels to spare, the top-left
+	// finder starts at pixel 44.
+	module := color.NRGBAModel.Convert(img.At(48, 48)).(color.NRGBA)
+	if module.A != 0x80 || module.B < 0x80 {
+		t.Errorf("dark module = %v, want translucent blue", module)
+	}
+}
+
+func TestPhysicalSize(t *testing.T) {
+	opts := qr.DefaultOptions()
+	opts.PhysicalSize = 25
+	opts.DPI = 600
+
+	bitmap, err := qr.Bitmap("https://example.com", opts)
+	if err != nil {
+		t.Fatalf("Bitmap() error = %v", err)
+	}
+	pngData, err := qr.PNG("https://example.com", opts)
+	if err != nil {
+		t.Fatalf("PNG() error = %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(pngData))
+	if err != nil {
+		t.Fatalf("png.Decode() error = %v", err)
+	}
+	// 25mm at 600 dpi is 590.6 pixels, rounded to 18 whole pixels for each
+	// of the 33 modules.
+	if got, want := img.Bounds().Dx(), 18*len(bitmap); got != want {
+		t.Errorf("PNG width = %d, want %d", got, want)
+	}
+	// 600 dpi is 23622 pixels per metre, in metres (unit 1).
+	phys := []byte("pHYs\x00\x00\x5c\x46\x00\x00\x5c\x46\x01")
+	if !bytes.Contains(pngData, phys) {
+		t.Error("PNG missing a 600 dpi pHYs chunk")
+	}
+	if results := decodePNG(t, pngData); results[0] != "https://example.com" {
+		t.Errorf("decoded %q", results[0])
+	}
+
+	svg, err := qr.SVG("https://example.com", opts)
+	if err != nil {
+		t.Fatalf("SVG() error = %v", err)
+	}
+	if !strings.Contains(string(svg), `width="25mm" height="25mm"`) {
+		t.Error("SVG is not 25mm wide")
+	}
+
+	// A pixel size with a DPI keeps its pixels and prints at their size.
+	opts = qr.DefaultOptions()
+	opts.Size = 300
+	opts.DPI = 300
+	if svg, err = qr.SVG("https://example.com", opts); err != nil {
+		t.Fatalf("SVG() error = %v", err)
+	}
+	if !strings.Contains(string(svg), `width="25.4mm"`) {
+		t.Error("SVG at 300 pixels and 300 dpi is not 25.4mm wide")
+	}
+}
+
+func TestFit(t *testing.T) {
+	// Version 2 is 33 modules with its quiet zone, so 256 pixels is 7.76
+	// pixels a module.
+	tests := []struct {
+		fit  qr.Fit
+		want int
+	}{
+		{qr.FitPad, 256},
+		{qr.FitExact, 256},
+		{qr.FitRoundUp, 264},
+		{qr.FitRoundDown, 231},
+	}
+	for _, tt := range tests {
+		opts := qr.DefaultOptions()
+		opts.Fit = tt.fit
+
+		pngData, err := qr.PNG("https://example.com", opts)
+		if err != nil {
+			t.Fatalf("%s: PNG() error = %v", tt.fit, err)
+		}
+		img, err := png.Decode(bytes.NewReader(pngData))
+		if err != nil {
+			t.Fatalf("%s: png.Decode() error = %v", tt.fit, err)
+		}
+		if got := img.Bounds().Dx(); got != tt.want {
+			t.Errorf("%s: width = %d, want %d", tt.fit, got, tt.want)
+		}
+		if w, h, err := qr.PNGSize("https://example.com", opts); err != nil || w != tt.want || h != tt.want {
+			t.Errorf("%s: PNGSize() = %d, %d, %v, want %d", tt.fit, w, h, err, tt.want)
+		}
+		if results := decodePNG(t, pngData); results[0] != "https://example.com" {
+			t.Errorf("%s: decoded %q", tt.fit, results[0])
+		}
+
+		// Modules keep crisp edges even when they are not all the same
+		// width.
+		bounds := img.Bounds()
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				if c := color.GrayModel.Convert(img.At(x, y)).(color.Gray); c.Y != 0 && c.Y != 0xff {
+					t.Fatalf("%s: pixel (%d, %d) = %v, want black or white", tt.fit, x, y, c)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package qr
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strings"
+)
+
+// GradientType selects how a gradient blends across the symbol.
+type GradientType int
+
+const (
+	// GradientLinear blends along a line at Gradient.Angle.
+	GradientLinear GradientType = iota
+	// GradientRadial blends outwards from the centre of the symbol.
+	GradientRadial
+)
+
+func (t GradientType) String() string {
+	if t == GradientRadial {
+		return "radial"
+	}
+	return "linear"
+}
+
+// Gradient fills the dark modules with colours blended between evenly
+// spaced stops. It spans the symbol, not the quiet zone around it.
+type Gradient struct {
+	Type  GradientType
+	Stops []color.Color
+	// Angle is the direction of a linear gradient in degrees, clockwise
+	// from left-to-right, so 90 runs from top to bottom.
+	Angle float64
+}
+
+func (g *Gradient) validate() error {
+	if len(g.Stops) < 2 {
+		return fmt.Errorf("gradient needs at least two colors, got %d", len(g.Stops))
+	}
+	return nil
+}
+
+// axis returns the start and end of a linear gradient across a w by h box
+// centred on (cx, cy), reaching the corners so both end stops appear.
+func (g *Gradient) axis(cx, cy, w, h float64) (x1, y1, x2, y2 float64) {
+	rad := g.Angle * math.Pi / 180
+	dx, dy := math.Cos(rad), math.Sin(rad)
+	half := (math.Abs(dx)*w + math.Abs(dy)*h) / 2
+	return cx - dx*half, cy - dy*half, cx + dx*half, cy + dy*half
+}
+
+// radius returns the radius of a radial gradient across a w by h box,
+// which reaches its corners.
+func (g *Gradient) radius(w, h float64) float64 {
+	return math.Hypot(w, h) / 2
+}
+
+// at returns the colour at position t, clamped to 0-1 along the stops.
+func (g *Gradient) at(t float64) color.RGBA {
+	t = math.Max(0, math.Min(1, t))
+	pos := t * float64(len(g.Stops)-1)
+	i := min(int(pos), len(g.Stops)-2)
+	a, b := colorToRGBA(g.Stops[i]), colorToRGBA(g.Stops[i+1])
+	f := pos - float64(i)
+	mix := func(x, y uint8) uint8 {
+		return uint8(math.Round(float64(x) + (float64(y)-float64(x))*f))
+	}
+	return color.RGBA{R: mix(a.R, b.R), G: mix(a.G, b.G), B: mix(a.B, b.B), A: mix(a.A, b.A)}
+}
+
+// gradientImage is an unbounded image that evaluates a gradient laid over
+// the pixel rectangle box, for use as a draw source.
+type gradientImage struct {
+	g   *Gradient
+	box image.Rectangle
+}
+
+func (img gradientImage) ColorModel() color.Model { return color.RGBAModel }
+
+func (img gradientImage) Bounds() image.Rectangle {
+	return image.Rect(-1e9, -1e9, 1e9, 1e9)
+}
+
+func (img gradientImage) At(x, y int) color.Color {
+	w, h := float64(img.box.Dx()), float64(img.box.Dy())
+	cx, cy := float64(img.box.Min.X)+w/2, float64(img.box.Min.Y)+h/2
+	px, py := float64(x)+0.5, float64(y)+0.5
+
+	if img.g.Type == GradientRadial {
+		return img.g.at(math.Hypot(px-cx, py-cy) / img.g.radius(w, h))
+	}
+	x1, y1, x2, y2 := img.g.axis(cx, cy, w, h)
+	dx, dy := x2-x1, y2-y1
+	return img.g.at(((px-x1)*dx + (py-y1)*dy) / (dx*dx + dy*dy))
+}
+
+// svgGradientID is the id of the foreground gradient definition.
+const svgGradientID = "qr-fg"
+
+// svgDefinition returns a gradient element for an SVG whose viewBox
+// places the symbol at (x, y) with size w by h, in user space so it lines
+// up with the PNG rendering.
+func (g *Gradient) svgDefinition(x, y, w, h float64) string {
+	var b strings.Builder
+	cx, cy := x+w/2, y+h/2
+	if g.Type == GradientRadial {
+		fmt.Fprintf(&b, `<radialGradient id="%s" gradientUnits="userSpaceOnUse" cx="%s" cy="%s" r="%s">`,
+			svgGradientID, svgNum(cx), svgNum(cy), svgNum(g.radius(w, h)))
+	} else {
+		x1, y1, x2, y2 := g.axis(cx, cy, w, h)
+		fmt.Fprintf(&b, `<linearGradient id="%s" gradientUnits="userSpaceOnUse" x1="%s" y1="%s" x2="%s" y2="%s">`,
+			svgGradientID, svgNum(x1), svgNum(y1), svgNum(x2), svgNum(y2))
+	}
+	for i, stop := range g.Stops {
+		opacity := ""
+		if colorToRGBA(stop).A != 0xff {
+			opacity = ` stop-opacity="` + svgOpacity(stop) + `"`
+		}
+		fmt.Fprintf(&b, `<stop offset="%s" stop-color="%s"%s/>`, svgNum(float64(i)/float64(len(g.Stops)-1)), colorToHex(stop), opacity)
+	}
+	if g.Type == GradientRadial {
+		b.WriteString(`</radialGradient>`)
+	} else {
+		b.WriteString(`</linearGradient>`)
+	}
+	return b.String()
+}
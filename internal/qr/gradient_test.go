@@ -0,0 +1,70 @@
+package qr_test
+
+import (
+	"bytes"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/eliaseffects/qr-cli/internal/qr"
+)
+
+var (
+	red  = color.RGBA{R: 0xc0, A: 0xff}
+	blue = color.RGBA{B: 0xc0, A: 0xff}
+)
+
+func TestGradientPNG(t *testing.T) {
+	payload := "gradient"
+	for _, typ := range []qr.GradientType{qr.GradientLinear, qr.GradientRadial} {
+		opts := qr.DefaultOptions()
+		opts.Size = 290 // version 1 and its quiet zone at 10 pixels a module
+		opts.ForegroundGradient = &qr.Gradient{Type: typ, Stops: []color.Color{red, blue}}
+
+		pngData, err := qr.PNG(payload, opts)
+		if err != nil {
+			t.Fatalf("%s: PNG() error = %v", typ, err)
+		}
+		if results := decodePNG(t, pngData); results[0] != payload {
+			t.Fatalf("%s: decoded %q, want %q", typ, results[0], payload)
+		}
+
+		img, err := png.Decode(bytes.NewReader(pngData))
+		if err != nil {
+			t.Fatalf("png.Decode() error = %v", err)
+		}
+		// A left-to-right gradient starts red at the top-left finder and
+		// ends blue at the top-right one; a radial gradient is blue at both
+		// corners, furthest from the red centre.
+		topLeft := color.RGBAModel.Convert(img.At(42, 42)).(color.RGBA)
+		topRight := color.RGBAModel.Convert(img.At(247, 42)).(color.RGBA)
+		if radial := typ == qr.GradientRadial; radial != (topLeft.B > topLeft.R) {
+			t.Errorf("%s: top-left finder = %v", typ, topLeft)
+		}
+		if topRight.B <= topRight.R {
+			t.Errorf("%s: top-right finder = %v, want blue", typ, topRight)
+		}
+	}
+}
+
+func TestGradientSVG(t *testing.T) {
+	opts := qr.DefaultOptions()
+	opts.ForegroundGradient = &qr.Gradient{Type: qr.GradientLinear, Stops: []color.Color{red, blue}, Angle: 45}
+	opts.EyeFrameColor = color.Black
+
+	svg, err := qr.SVG("gradient", opts)
+	if err != nil {
+		t.Fatalf("SVG() error = %v", err)
+	}
+	for _, want := range []string{`<linearGradient id="qr-fg"`, `stop-color="#c00000"`, `stop-color="#0000c0"`, `fill="url(#qr-fg)"`, `fill="#000000"`} {
+		if !strings.Contains(string(svg), want) {
+			t.Errorf("SVG missing %s", want)
+		}
+	}
+
+	opts.ForegroundGradient.Stops = opts.ForegroundGradient.Stops[:1]
+	if _, err := qr.SVG("gradient", opts); err == nil {
+		t.Error("expected an error for a single-stop gradient")
+	}
+}
@@ -0,0 +1,441 @@
+package qr
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// gs1Separator is the ASCII group separator that ends a variable-length
+// element string field when another field follows it.
+const gs1Separator = 0x1d
+
+// gs1CharacterSet is GS1 AI encodable character set 82, which alphanumeric
+// (X) fields are limited to.
+const gs1CharacterSet = `!"%&'()*+,-./0123456789:;<=>?ABCDEFGHIJKLMNOPQRSTUVWXYZ_abcdefghijklmnopqrstuvwxyz`
+
+// gs1AI describes an Application Identifier. The format uses the notation
+// of the GS1 General Specifications: components joined by '+', each N
+// (digits) or X (character set 82) with a fixed length or "..max", and an
+// optional ",check" suffix naming a validation.
+type gs1AI struct {
+	title  string
+	format string
+}
+
+var gs1AIs = map[string]gs1AI{
+	"00":   {"SSCC", "N18,csum"},
+	"01":   {"GTIN", "N14,csum"},
+	"02":   {"CONTENT", "N14,csum"},
+	"10":   {"BATCH/LOT", "X..20"},
+	"11":   {"PROD DATE", "N6,yymmd0"},
+	"12":   {"DUE DATE", "N6,yymmd0"},
+	"13":   {"PACK DATE", "N6,yymmd0"},
+	"15":   {"BEST BEFORE", "N6,yymmd0"},
+	"16":   {"SELL BY", "N6,yymmd0"},
+	"17":   {"USE BY", "N6,yymmd0"},
+	"20":   {"VARIANT", "N2"},
+	"21":   {"SERIAL", "X..20"},
+	"22":   {"CPV", "X..20"},
+	"235":  {"TPX", "X..28"},
+	"240":  {"ADDITIONAL ID", "X..30"},
+	"241":  {"CUST. PART No.", "X..30"},
+	"242":  {"MTO VARIANT", "N..6"},
+	"243":  {"PCN", "X..20"},
+	"250":  {"SECONDARY SERIAL", "X..30"},
+	"251":  {"REF. TO SOURCE", "X..30"},
+	"253":  {"GDTI", "N13,csum+X..17"},
+	"254":  {"GLN EXTENSION COMPONENT", "X..20"},
+	"255":  {"GCN", "N13,csum+N..12"},
+	"30":   {"VAR. COUNT", "N..8"},
+	"37":   {"COUNT", "N..8"},
+	"400":  {"ORDER NUMBER", "X..30"},
+	"401":  {"GINC", "X..30"},
+	"402":  {"GSIN", "N17,csum"},
+	"403":  {"ROUTE", "X..30"},
+	"410":  {"SHIP TO LOC", "N13,csum"},
+	"411":  {"BILL TO", "N13,csum"},
+	"412":  {"PURCHASE FROM", "N13,csum"},
+	"413":  {"SHIP FOR LOC", "N13,csum"},
+	"414":  {"LOC No.", "N13,csum"},
+	"415":  {"PAY TO", "N13,csum"},
+	"416":  {"PROD/SERV LOC", "N13,csum"},
+	"417":  {"PARTY", "N13,csum"},
+	"420":  {"SHIP TO POST", "X..20"},
+	"421":  {"SHIP TO POST", "N3+X..9"},
+	"422":  {"ORIGIN", "N3"},
+	"423":  {"COUNTRY - INITIAL PROCESS.", "N3+N..12"},
+	"424":  {"COUNTRY - PROCESS.", "N3"},
+	"425":  {"COUNTRY - DISASSEMBLY", "N3+N..12"},
+	"426":  {"COUNTRY - FULL PROCESS", "N3"},
+	"7001": {"NSN", "N13"},
+	"7003": {"EXPIRY TIME", "N10,yymmddhhmm"},
+	"7006": {"FIRST FREEZE DATE", "N6,yymmdd"},
+	"8001": {"DIMENSIONS", "N14"},
+	"8002": {"CMT No.", "X..20"},
+	"8003": {"GRAI", "N1+N13,csum+X..16"},
+	"8004": {"GIAI", "X..30"},
+	"8005": {"PRICE PER UNIT", "N6"},
+	"8006": {"ITIP", "N14,csum+N2+N2"},
+	"8007": {"IBAN", "X..34"},
+	"8008": {"PROD TIME", "N8,yymmddhh+N..4"},
+	"8010": {"CPID", "X..30"},
+	"8011": {"CPID SERIAL", "N..12"},
+	"8012": {"VERSION", "X..20"},
+	"8013": {"GMN", "X..25"},
+	"8017": {"GSRN - PROVIDER", "N18,csum"},
+	"8018": {"GSRN - RECIPIENT", "N18,csum"},
+	"8019": {"SRIN", "N..10"},
+	"8020": {"REF No.", "X..25"},
+	"8200": {"PRODUCT URL", "X..70"},
+	"90":   {"INTERNAL", "X..30"},
+}
+
+func init() {
+	// Measures carry the decimal point position in the fourth AI digit.
+	measures := [][2]int{{310, 316}, {320, 337}, {340, 357}, {360, 369}}
+	for _, r := range measures {
+		for ai := r[0]; ai <= r[1]; ai++ {
+			for d := 0; d <= 9; d++ {
+				gs1AIs[fmt.Sprintf("%d%d", ai, d)] = gs1AI{"MEASURE", "N6"}
+			}
+		}
+	}
+	for d := 0; d <= 9; d++ {
+		gs1AIs[fmt.Sprintf("390%d", d)] = gs1AI{"AMOUNT", "N..15"}
+		gs1AIs[fmt.Sprintf("391%d", d)] = gs1AI{"AMOUNT", "N3+N..15"}
+		gs1AIs[fmt.Sprintf("392%d", d)] = gs1AI{"PRICE", "N..15"}
+		gs1AIs[fmt.Sprintf("393%d", d)] = gs1AI{"PRICE", "N3+N..15"}
+		gs1AIs[fmt.Sprintf("394%d", d)] = gs1AI{"PRCNT OFF", "N4"}
+	}
+	for ai := 91; ai <= 99; ai++ {
+		gs1AIs[strconv.Itoa(ai)] = gs1AI{"INTERNAL", "X..90"}
+	}
+}
+
+// gs1FixedLength lists the AI prefixes whose fields have a predefined
+// length and so never need a separator after them.
+var gs1FixedLength = []string{
+	"00", "01", "02", "03", "04", "11", "12", "13", "14", "15", "16", "17",
+	"18", "19", "20", "31", "32", "33", "34", "35", "36", "41",
+}
+
+// gs1PrimaryKeys are the AIs that may start a Digital Link URI path.
+var gs1PrimaryKeys = []string{
+	"00", "01", "253", "255", "401", "402", "414", "417",
+	"8003", "8004", "8006", "8010", "8013", "8017", "8018",
+}
+
+// GS1Element is a single Application Identifier and its value.
+type GS1Element struct {
+	AI    string
+	Value string
+}
+
+// GS1Data is validated GS1 data. URI is set when the data came from a
+// GS1 Digital Link URI, which is encoded as plain text rather than as an
+// element string behind FNC1.
+type GS1Data struct {
+	Elements []GS1Element
+	URI      string
+}
+
+// ParseGS1 parses and validates either a bracketed element string such as
+// "(01)09506000134352(17)201225(10)ABC123" or a GS1 Digital Link URI.
+func ParseGS1(input string) (*GS1Data, error) {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return nil, errors.New("GS1 data is empty")
+	}
+
+	var data *GS1Data
+	var err error
+	if strings.HasPrefix(input, "(") {
+		data, err = parseElementString(input)
+	} else {
+		data, err = parseDigitalLink(input)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]string)
+	for _, e := range data.Elements {
+		if prev, ok := seen[e.AI]; ok && prev != e.Value {
+			return nil, fmt.Errorf("(%s) appears twice with different values", e.AI)
+		}
+		seen[e.AI] = e.Value
+		if err := validateGS1Element(e); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// ParseGS1ElementString splits a raw element string, as read from an FNC1
+// symbol, back into its elements. Fields without a predefined length run
+// to the next group separator.
+func ParseGS1ElementString(s string) (*GS1Data, error) {
+	data := &GS1Data{}
+	for s != "" {
+		ai := ""
+		for n := 2; n <= 4 && n <= len(s); n++ {
+			if _, ok := gs1AIs[s[:n]]; ok {
+				ai = s[:n]
+				break
+			}
+		}
+		if ai == "" {
+			return nil, fmt.Errorf("unknown application identifier at %q", s)
+		}
+		s = s[len(ai):]
+
+		end := strings.IndexByte(s, gs1Separator)
+		if format := gs1AIs[ai].format; !strings.Contains(format, "..") {
+			end = min(totalLength(format), len(s))
+		} else if end < 0 {
+			end = len(s)
+		}
+		data.Elements = append(data.Elements, GS1Element{AI: ai, Value: s[:end]})
+		s = strings.TrimPrefix(s[end:], string(rune(gs1Separator)))
+	}
+	return data, nil
+}
+
+var gs1AIPattern = regexp.MustCompile(`\((\d{2,4})\)`)
+
+func parseElementString(input string) (*GS1Data, error) {
+	matches := gs1AIPattern.FindAllStringSubmatchIndex(input, -1)
+	if len(matches) == 0 || matches[0][0] != 0 {
+		return nil, errors.New("element string must start with a bracketed AI such as (01)")
+	}
+
+	data := &GS1Data{}
+	for i, m := range matches {
+		end := len(input)
+		if i+1 < len(matches) {
+			end = matches[i+1][0]
+		}
+		data.Elements = append(data.Elements, GS1Element{AI: input[m[2]:m[3]], Value: input[m[1]:end]})
+	}
+	return data, nil
+}
+
+func parseDigitalLink(input string) (*GS1Data, error) {
+	u, err := url.Parse(input)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, errors.New("GS1 data must be a bracketed element string or an http(s) Digital Link URI")
+	}
+
+	segments := strings.Split(strings.Trim(u.EscapedPath(), "/"), "/")
+	start := -1
+	for i, s := range segments {
+		if slices.Contains(gs1PrimaryKeys, s) && i+1 < len(segments) {
+			start = i
+			break
+		}
+	}
+	if start < 0 {
+		return nil, errors.New("Digital Link URI has no primary key such as /01/<GTIN>")
+	}
+	if (len(segments)-start)%2 != 0 {
+		return nil, fmt.Errorf("Digital Link path ends with AI %s but no value", segments[len(segments)-1])
+	}
+
+	data := &GS1Data{URI: input}
+	for i := start; i < len(segments); i += 2 {
+		value, err := url.PathUnescape(segments[i+1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid Digital Link value for (%s): %w", segments[i], err)
+		}
+		if _, ok := gs1AIs[segments[i]]; !ok {
+			return nil, fmt.Errorf("unknown application identifier (%s) in Digital Link path", segments[i])
+		}
+		if segments[i] == "01" && (len(value) == 8 || len(value) == 12 || len(value) == 13) {
+			// GTIN-8, -12 and -13 are zero-padded to fourteen digits.
+			value = strings.Repeat("0", 14-len(value)) + value
+		}
+		data.Elements = append(data.Elements, GS1Element{AI: segments[i], Value: value})
+	}
+
+	// Query parameters with AI keys are data attributes; anything else
+	// is passed through untouched.
+	query := u.Query()
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		if _, ok := gs1AIs[key]; ok {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		data.Elements = append(data.Elements, GS1Element{AI: key, Value: query.Get(key)})
+	}
+	return data, nil
+}
+
+// validateGS1Element checks a value against the format of its AI.
+func validateGS1Element(e GS1Element) error {
+	ai, ok := gs1AIs[e.AI]
+	if !ok {
+		return fmt.Errorf("unknown application identifier (%s)", e.AI)
+	}
+
+	value := e.Value
+	components := strings.Split(ai.format, "+")
+	for i, component := range components {
+		spec, check, _ := strings.Cut(component, ",")
+		numeric := spec[0] == 'N'
+		minLen, maxLen := 0, 0
+		if rest, ok := strings.CutPrefix(spec[1:], ".."); ok {
+			maxLen, _ = strconv.Atoi(rest)
+			minLen = 1
+		} else {
+			maxLen, _ = strconv.Atoi(spec[1:])
+			minLen = maxLen
+		}
+		// Optional trailing components may be absent.
+		if value == "" && i > 0 && strings.HasPrefix(spec[1:], "..") {
+			break
+		}
+
+		n := min(maxLen, len(value))
+		field := value[:n]
+		value = value[n:]
+		if len(field) < minLen {
+			if minLen == maxLen {
+				return fmt.Errorf("(%s) %s must be %d characters, got %q", e.AI, ai.title, totalLength(ai.format), e.Value)
+			}
+			return fmt.Errorf("(%s) %s is too short: %q", e.AI, ai.title, e.Value)
+		}
+		for _, r := range field {
+			if numeric && !isNumeric(r) {
+				return fmt.Errorf("(%s) %s must be numeric, got %q", e.AI, ai.title, e.Value)
+			}
+			if !numeric && (r >= 0x80 || !strings.ContainsRune(gs1CharacterSet, r)) {
+				return fmt.Errorf("(%s) %s contains %q, which GS1 does not allow", e.AI, ai.title, r)
+			}
+		}
+		if err := checkGS1Field(check, field); err != nil {
+			return fmt.Errorf("(%s) %s %w", e.AI, ai.title, err)
+		}
+	}
+	if value != "" {
+		return fmt.Errorf("(%s) %s is too long: %q", e.AI, ai.title, e.Value)
+	}
+	return nil
+}
+
+// totalLength returns the combined length of a fixed-length format.
+func totalLength(format string) int {
+	total := 0
+	for _, component := range strings.Split(format, "+") {
+		spec, _, _ := strings.Cut(component, ",")
+		n, _ := strconv.Atoi(spec[1:])
+		total += n
+	}
+	return total
+}
+
+func checkGS1Field(check, field string) error {
+	switch check {
+	case "csum":
+		if want := gs1CheckDigit(field[:len(field)-1]); field[len(field)-1] != want {
+			return fmt.Errorf("has an invalid check digit %c, want %c", field[len(field)-1], want)
+		}
+	case "yymmd0", "yymmdd":
+		if !validGS1Date(field[:6], check == "yymmd0") {
+			return fmt.Errorf("has an invalid date %s (want YYMMDD)", field)
+		}
+	case "yymmddhh", "yymmddhhmm":
+		if !validGS1Date(field[:6], false) {
+			return fmt.Errorf("has an invalid date %s (want YYMMDD)", field[:6])
+		}
+		hour, _ := strconv.Atoi(field[6:8])
+		minute := 0
+		if len(field) >= 10 {
+			minute, _ = strconv.Atoi(field[8:10])
+		}
+		if hour > 23 || minute > 59 {
+			return fmt.Errorf("has an invalid time %s", field[6:])
+		}
+	}
+	return nil
+}
+
+// gs1CheckDigit returns the mod-10 check digit for digits, weighting them
+// 3 and 1 alternately from the right.
+func gs1CheckDigit(digits string) byte {
+	sum := 0
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if (len(digits)-1-i)%2 == 0 {
+			d *= 3
+		}
+		sum += d
+	}
+	return byte('0' + (10-sum%10)%10)
+}
+
+// validGS1Date checks a YYMMDD date. Some AIs allow day 00 to mean the
+// end of the month.
+func validGS1Date(s string, zeroDay bool) bool {
+	year, _ := strconv.Atoi(s[0:2])
+	month, _ := strconv.Atoi(s[2:4])
+	day, _ := strconv.Atoi(s[4:6])
+	if month < 1 || month > 12 {
+		return false
+	}
+	if day == 0 {
+		return zeroDay
+	}
+	t := time.Date(2000+year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+	return t.Day() == day
+}
+
+// ElementString returns the data as it is encoded after FNC1: AIs and
+// values run together, with a group separator after each variable-length
+// field that is followed by another.
+func (d *GS1Data) ElementString() string {
+	var b strings.Builder
+	for i, e := range d.Elements {
+		b.WriteString(e.AI)
+		b.WriteString(e.Value)
+		if i < len(d.Elements)-1 && !gs1IsFixedLength(e.AI) {
+			b.WriteByte(gs1Separator)
+		}
+	}
+	return b.String()
+}
+
+// String returns the human readable form, e.g. "(01)09506000134352(10)ABC".
+func (d *GS1Data) String() string {
+	var b strings.Builder
+	for _, e := range d.Elements {
+		fmt.Fprintf(&b, "(%s)%s", e.AI, e.Value)
+	}
+	return b.String()
+}
+
+// Payload returns the text to encode: the Digital Link URI when there is
+// one, otherwise the element string for an FNC1 symbol (see Options.GS1).
+func (d *GS1Data) Payload() string {
+	if d.URI != "" {
+		return d.URI
+	}
+	return d.ElementString()
+}
+
+func gs1IsFixedLength(ai string) bool {
+	for _, prefix := range gs1FixedLength {
+		if strings.HasPrefix(ai, prefix) {
+			return true
+		}
+	}
+	return false
+}
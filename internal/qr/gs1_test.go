@@ -0,0 +1,131 @@
+package qr_test
+
+import (
+	"bytes"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/eliaseffects/qr-cli/internal/qr"
+)
+
+func TestParseGS1ElementString(t *testing.T) {
+	data, err := qr.ParseGS1("(01)09506000134352(17)201225(10)ABC123(21)X%1")
+	if err != nil {
+		t.Fatalf("ParseGS1() error = %v", err)
+	}
+	if data.URI != "" || len(data.Elements) != 4 {
+		t.Fatalf("unexpected parse: %+v", data)
+	}
+
+	// (01) and (17) have predefined lengths; (10) needs a separator
+	// because (21) follows it, and the last field never does.
+	want := "0109506000134352" + "17201225" + "10ABC123\x1d" + "21X%1"
+	if got := data.ElementString(); got != want {
+		t.Fatalf("ElementString() = %q, want %q", got, want)
+	}
+	if got := data.Payload(); got != want {
+		t.Fatalf("Payload() = %q, want the element string", got)
+	}
+	if got := data.String(); got != "(01)09506000134352(17)201225(10)ABC123(21)X%1" {
+		t.Fatalf("String() = %q", got)
+	}
+
+	back, err := qr.ParseGS1ElementString(want)
+	if err != nil {
+		t.Fatalf("ParseGS1ElementString() error = %v", err)
+	}
+	if back.String() != data.String() {
+		t.Fatalf("ParseGS1ElementString() = %q, want %q", back.String(), data.String())
+	}
+}
+
+func TestParseGS1DigitalLink(t *testing.T) {
+	uri := "https://id.gs1.org/01/9506000134352/10/ABC%2F1?17=201225&linkType=gs1:pip"
+	data, err := qr.ParseGS1(uri)
+	if err != nil {
+		t.Fatalf("ParseGS1() error = %v", err)
+	}
+	if data.Payload() != uri {
+		t.Fatalf("Payload() = %q, want the URI", data.Payload())
+	}
+	if got := data.String(); got != "(01)09506000134352(10)ABC/1(17)201225" {
+		t.Fatalf("String() = %q", got)
+	}
+}
+
+func TestParseGS1Invalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"check digit", "(01)09506000134353", "check digit"},
+		{"short GTIN", "(01)0950600013435", "14 characters"},
+		{"month", "(01)09506000134352(17)201325", "invalid date"},
+		{"day", "(01)09506000134352(11)210230", "invalid date"},
+		{"too long", "(10)" + strings.Repeat("A", 21), "too long"},
+		{"character set", "(10)AB CD", "does not allow"},
+		{"unknown AI", "(05)123", "unknown application identifier"},
+		{"no AI", "09506000134352", "Digital Link"},
+		{"no primary key", "https://example.com/10/ABC", "primary key"},
+		{"dangling AI", "https://example.com/01/09506000134352/10", "no value"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := qr.ParseGS1(tt.input)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+
+	// Day 00 stands for the end of the month on expiry-style dates.
+	if _, err := qr.ParseGS1("(01)09506000134352(17)201200"); err != nil {
+		t.Fatalf("expected day 00 to be accepted, got %v", err)
+	}
+}
+
+func TestGS1RoundTrip(t *testing.T) {
+	data, err := qr.ParseGS1("(01)09506000134352(10)AB-12(21)50%OFF(17)201225")
+	if err != nil {
+		t.Fatalf("ParseGS1() error = %v", err)
+	}
+
+	opts := qr.DefaultOptions()
+	opts.GS1 = true
+	code, err := qr.Generate(data.Payload(), opts)
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if !code.GS1 {
+		t.Fatal("expected Code.GS1 to be set")
+	}
+
+	pngData, err := qr.PNG(data.Payload(), opts)
+	if err != nil {
+		t.Fatalf("PNG() error = %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(pngData))
+	if err != nil {
+		t.Fatalf("png.Decode() error = %v", err)
+	}
+	symbols, err := qr.DecodeSymbols(img)
+	if err != nil {
+		t.Fatalf("DecodeSymbols() error = %v", err)
+	}
+	if !symbols[0].GS1 || symbols[0].Payload != data.Payload() {
+		t.Fatalf("decoded %q (GS1 %v), want %q", symbols[0].Payload, symbols[0].GS1, data.Payload())
+	}
+}
+
+func TestGS1Unsupported(t *testing.T) {
+	opts := qr.DefaultOptions()
+	opts.Symbology = qr.SymbolMicro
+	opts.Level = qr.Low
+	opts.GS1 = true
+	if _, err := qr.Generate("0109506000134352", opts); err == nil {
+		t.Fatal("expected error for GS1 Micro QR")
+	}
+}
@@ -3,12 +3,15 @@ package qr
 import (
 	"bytes"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"image"
 	"image/draw"
 	"image/png"
 	"math"
 	"os"
+	"sync"
+	"time"
 
 	xdraw "golang.org/x/image/draw"
 
@@ -24,6 +27,10 @@ const (
 	logoPaddingScale = 0.12
 )
 
+// ErrLogoTooLarge reports a logo that hides more of the symbol than even
+// level H can recover.
+var ErrLogoTooLarge = errors.New("logo too large")
+
 func clampLogoScale(scale float64) float64 {
 	if scale <= 0 {
 		return defaultLogoScale
@@ -37,19 +44,58 @@ func clampLogoScale(scale float64) float64 {
 	return scale
 }
 
-func loadLogoImage(path string) (image.Image, error) {
-	file, err := os.Open(path)
+// logoCache keeps decoded logos and their scaled copies, so a batch that
+// puts one logo on every code reads and resizes it once. Entries are
+// keyed by path and reloaded when the file changes.
+var logoCache = struct {
+	sync.Mutex
+	entries map[string]*cachedLogo
+}{entries: make(map[string]*cachedLogo)}
+
+type cachedLogo struct {
+	modTime time.Time
+	size    int64
+
+	once sync.Once
+	img  image.Image
+	err  error
+
+	mu     sync.Mutex
+	scaled map[int]*image.RGBA
+}
+
+// scaledLogo returns the logo at path scaled into a size-pixel square. It
+// is safe for concurrent use; the image is shared and must not be
+// modified.
+func scaledLogo(path string, size int) (*image.RGBA, error) {
+	info, err := os.Stat(path)
 	if err != nil {
 		return nil, err
 	}
-	defer file.Close()
 
-	img, _, err := image.Decode(file)
-	if err != nil {
-		return nil, err
+	logoCache.Lock()
+	entry := logoCache.entries[path]
+	if entry == nil || !entry.modTime.Equal(info.ModTime()) || entry.size != info.Size() {
+		entry = &cachedLogo{modTime: info.ModTime(), size: info.Size(), scaled: make(map[int]*image.RGBA)}
+		logoCache.entries[path] = entry
 	}
+	logoCache.Unlock()
 
-	return img, nil
+	entry.once.Do(func() {
+		entry.img, entry.err = loadImage(path)
+	})
+	if entry.err != nil {
+		return nil, entry.err
+	}
+
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+	scaled, ok := entry.scaled[size]
+	if !ok {
+		scaled = scaleToSquare(entry.img, size)
+		entry.scaled[size] = scaled
+	}
+	return scaled, nil
 }
 
 func scaledLogoPNG(path string, size int) ([]byte, error) {
@@ -57,13 +103,11 @@ func scaledLogoPNG(path string, size int) ([]byte, error) {
 		return nil, fmt.Errorf("invalid logo size")
 	}
 
-	img, err := loadLogoImage(path)
+	scaled, err := scaledLogo(path, size)
 	if err != nil {
 		return nil, err
 	}
 
-	scaled := scaleToSquare(img, size)
-
 	var buf bytes.Buffer
 	if err := png.Encode(&buf, scaled); err != nil {
 		return nil, err
@@ -123,12 +167,11 @@ func overlayLogoPNG(img *image.RGBA, opts Options, canvasSize int) error {
 	bgY := (canvasSize - bgSize) / 2
 	draw.Draw(img, image.Rect(bgX, bgY, bgX+bgSize, bgY+bgSize), &image.Uniform{C: opts.BackgroundColor}, image.Point{}, draw.Src)
 
-	logo, err := loadLogoImage(opts.LogoPath)
+	scaled, err := scaledLogo(opts.LogoPath, logoSize)
 	if err != nil {
 		return err
 	}
 
-	scaled := scaleToSquare(logo, logoSize)
 	logoX := (canvasSize - logoSize) / 2
 	logoY := (canvasSize - logoSize) / 2
 	draw.Draw(img, image.Rect(logoX, logoY, logoX+logoSize, logoY+logoSize), scaled, image.Point{}, draw.Over)
@@ -165,9 +208,143 @@ func svgLogoElement(opts Options, totalModules int) (string, error) {
 	encoded := base64.StdEncoding.EncodeToString(pngData)
 
 	return fmt.Sprintf(
-		`<rect x="%.2f" y="%.2f" width="%.2f" height="%.2f" fill="%s"/>`+
+		`<rect x="%.2f" y="%.2f" width="%.2f" height="%.2f" %s/>`+
 			`<image href="data:image/png;base64,%s" x="%.2f" y="%.2f" width="%.2f" height="%.2f"/>`,
-		bgX, bgY, bgUnits, bgUnits, colorToHex(opts.BackgroundColor),
+		bgX, bgY, bgUnits, bgUnits, svgFill(opts.BackgroundColor),
 		encoded, logoX, logoY, logoUnits, logoUnits,
 	), nil
 }
+
+// logoSpan returns the side, in modules, of the square that the logo and
+// its background padding cover on a symbol totalModules wide, quiet zone
+// included. It follows the PNG geometry of overlayLogoPNG, which covers
+// slightly more than the SVG overlay because the canvas can exceed the
+// symbol by the rounding padding.
+func logoSpan(opts Options, totalModules int) float64 {
+	layout := newPNGLayout(opts, totalModules, totalModules)
+	size := layout.width
+
+	logoSize := int(math.Round(float64(size) * clampLogoScale(opts.LogoScale)))
+	padding := int(math.Round(float64(logoSize) * logoPaddingScale))
+	return float64(min(logoSize+padding*2, size)) / layout.scale()
+}
+
+// logoDamage returns the fraction of data modules the logo hides in a
+// version, and whether every error correction block can still correct the
+// codewords those modules belong to. Misdecode protection codewords do not
+// count towards correction.
+func logoDamage(opts Options, version int, level RecoveryLevel) (float64, bool) {
+	size := symbolSize(version)
+	border := max(opts.BorderSize, 0)
+	span := logoSpan(opts, size+border*2)
+
+	// The square is centred on the symbol; a module counts as hidden when
+	// any part of it lies under the square, since scanners that sample off
+	// centre read partly covered modules unreliably.
+	lo := (float64(size) - span) / 2
+	hi := lo + span
+	hidden := func(x, y int) bool {
+		fx, fy := float64(x), float64(y)
+		return fx+1 > lo && fx < hi && fy+1 > lo && fy < hi
+	}
+
+	m := newMatrix(size)
+	m.drawFunctionPatterns(version)
+	rawCodewords := rawDataModules(version) / 8
+	damaged := make([]bool, rawCodewords)
+	total, covered, i := 0, 0, 0
+	m.forEachDataModule(func(x, y int) {
+		total++
+		if hidden(x, y) {
+			covered++
+			if i/8 < rawCodewords {
+				damaged[i/8] = true
+			}
+		}
+		i++
+	})
+
+	numBlocks := eccBlockCount[level][version]
+	eccLen := eccCodewordsPerBlock[level][version]
+	correctable := (eccLen - misdecodeProtection(version, level)) / 2
+	errs := make([]int, numBlocks)
+	for k, block := range codewordBlocks(numBlocks, eccLen, rawCodewords) {
+		if damaged[k] {
+			errs[block]++
+		}
+	}
+	for _, n := range errs {
+		if n > correctable {
+			return float64(covered) / float64(total), false
+		}
+	}
+	return float64(covered) / float64(total), true
+}
+
+// misdecodeProtection returns the error correction codewords that small
+// versions keep for detecting misdecodes rather than correcting errors,
+// from ISO/IEC 18004 Table 9.
+func misdecodeProtection(version int, level RecoveryLevel) int {
+	switch {
+	case version == 1 && level == Low:
+		return 3
+	case version == 1 && level == Medium, version == 2 && level == Low:
+		return 2
+	case version == 1, version == 3 && level == Low:
+		return 1
+	}
+	return 0
+}
+
+// codewordBlocks maps each position in the interleaved codeword sequence
+// to the block it came from, mirroring interleaveBlocks.
+func codewordBlocks(numBlocks, eccLen, rawCodewords int) []int {
+	numShortBlocks := numBlocks - rawCodewords%numBlocks
+	shortBlockLen := rawCodewords / numBlocks
+
+	blocks := make([]int, 0, rawCodewords)
+	for i := 0; i <= shortBlockLen; i++ {
+		for j := 0; j < numBlocks; j++ {
+			if i != shortBlockLen-eccLen || j >= numShortBlocks {
+				blocks = append(blocks, j)
+			}
+		}
+	}
+	return blocks
+}
+
+// logoFit returns the lowest level, starting at opts.Level, at which the
+// modules under the logo stay within the error correction budget. When
+// level H is not enough and the version is not pinned, larger versions are
+// tried, since smaller modules put fewer whole codewords under the logo.
+func logoFit(data string, opts Options) (RecoveryLevel, int, error) {
+	var fraction float64
+	version, tried := 0, opts.Level
+	for level := opts.Level; level <= Highest; level++ {
+		planned := opts
+		planned.Level = level
+		v, _, err := planSymbol(data, planned)
+		if err != nil {
+			if level == opts.Level || !errors.Is(err, ErrDataTooLong) {
+				return 0, 0, err
+			}
+			break
+		}
+		version, tried = v, level
+
+		var ok bool
+		if fraction, ok = logoDamage(opts, version, level); ok {
+			return level, opts.Version, nil
+		}
+	}
+
+	if opts.Version == 0 && tried == Highest {
+		for v := version + 1; v <= maxVersion; v++ {
+			if _, ok := logoDamage(opts, v, Highest); ok {
+				return Highest, v, nil
+			}
+		}
+	}
+	return 0, 0, fmt.Errorf("%w: it hides %.0f%% of the data modules, more than level %s can recover; use a smaller logo scale",
+		ErrLogoTooLarge, fraction*100, tried)
+}
@@ -0,0 +1,351 @@
+package qr
+
+// matrix holds the modules of a symbol under construction alongside a
+// mask of which modules belong to function patterns. Codewords are placed
+// in column pairs from firstColumn leftwards, stepping over timingColumn
+// (-1 when the symbol has no vertical timing pattern in its data area).
+type matrix struct {
+	width, height int
+	modules       [][]bool
+	function      [][]bool
+
+	firstColumn  int
+	timingColumn int
+}
+
+// newMatrix returns a square QR matrix of the given size.
+func newMatrix(size int) *matrix {
+	m := newRectMatrix(size, size)
+	m.timingColumn = 6
+	return m
+}
+
+func newRectMatrix(width, height int) *matrix {
+	m := &matrix{
+		width:        width,
+		height:       height,
+		modules:      make([][]bool, height),
+		function:     make([][]bool, height),
+		firstColumn:  width - 1,
+		timingColumn: -1,
+	}
+	for y := 0; y < height; y++ {
+		m.modules[y] = make([]bool, width)
+		m.function[y] = make([]bool, width)
+	}
+	return m
+}
+
+func (m *matrix) setFunction(x, y int, dark bool) {
+	m.modules[y][x] = dark
+	m.function[y][x] = true
+}
+
+// drawFunctionPatterns places the finder, timing and alignment patterns
+// and reserves the format and version areas for a QR version.
+func (m *matrix) drawFunctionPatterns(version int) {
+	for i := 0; i < m.width; i++ {
+		m.setFunction(6, i, i%2 == 0)
+		m.setFunction(i, 6, i%2 == 0)
+	}
+
+	m.drawFinder(3, 3)
+	m.drawFinder(m.width-4, 3)
+	m.drawFinder(3, m.height-4)
+
+	positions := alignmentPositions(version)
+	last := len(positions) - 1
+	for i, px := range positions {
+		for j, py := range positions {
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue
+			}
+			m.drawAlignment(px, py)
+		}
+	}
+
+	// Reserve the format areas with a dummy mask; the real bits are
+	// written once the mask is chosen.
+	m.drawFormatBits(Medium, 0)
+	m.drawVersion(version)
+}
+
+func (m *matrix) drawFinder(cx, cy int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			x, y := cx+dx, cy+dy
+			if x < 0 || x >= m.width || y < 0 || y >= m.height {
+				continue
+			}
+			dist := max(abs(dx), abs(dy))
+			m.setFunction(x, y, dist != 2 && dist != 4)
+		}
+	}
+}
+
+func (m *matrix) drawAlignment(cx, cy int) {
+	for dy := -2; dy <= 2; dy++ {
+		for dx := -2; dx <= 2; dx++ {
+			m.setFunction(cx+dx, cy+dy, max(abs(dx), abs(dy)) != 1)
+		}
+	}
+}
+
+// formatLevelBits is the two-bit level indicator used in format
+// information, which does not follow the L/M/Q/H ordering.
+var formatLevelBits = [4]int{1, 0, 3, 2}
+
+func (m *matrix) drawFormatBits(level RecoveryLevel, mask int) {
+	bits := formatBits(level, mask)
+	primary, secondary := formatPositions(m.width)
+	for i := 0; i < 15; i++ {
+		m.setFunction(primary[i][0], primary[i][1], bit(bits, i))
+		m.setFunction(secondary[i][0], secondary[i][1], bit(bits, i))
+	}
+	m.setFunction(8, m.height-8, true)
+}
+
+// formatBits returns the 15-bit BCH-protected format information.
+func formatBits(level RecoveryLevel, mask int) int {
+	return formatCode(formatLevelBits[level]<<3|mask) ^ 0x5412
+}
+
+// formatCode appends the (15,5) BCH check bits to five bits of format
+// information.
+func formatCode(data int) int {
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	return data<<10 | rem
+}
+
+// formatPositions returns the (x, y) coordinates of the 15 format bits,
+// least significant first, for the copy around the top-left finder and
+// the copy split between the other two finders.
+func formatPositions(size int) (primary, secondary [15][2]int) {
+	for i := 0; i <= 5; i++ {
+		primary[i] = [2]int{8, i}
+	}
+	primary[6] = [2]int{8, 7}
+	primary[7] = [2]int{8, 8}
+	primary[8] = [2]int{7, 8}
+	for i := 9; i < 15; i++ {
+		primary[i] = [2]int{14 - i, 8}
+	}
+
+	for i := 0; i < 8; i++ {
+		secondary[i] = [2]int{size - 1 - i, 8}
+	}
+	for i := 8; i < 15; i++ {
+		secondary[i] = [2]int{8, size - 15 + i}
+	}
+	return primary, secondary
+}
+
+func (m *matrix) drawVersion(version int) {
+	if version < 7 {
+		return
+	}
+
+	bits := versionCode(version)
+	for i := 0; i < 18; i++ {
+		dark := bit(bits, i)
+		a := m.width - 11 + i%3
+		b := i / 3
+		m.setFunction(a, b, dark)
+		m.setFunction(b, a, dark)
+	}
+}
+
+// versionCode appends the (18,6) BCH check bits to six bits of version
+// information.
+func versionCode(data int) int {
+	rem := data
+	for i := 0; i < 12; i++ {
+		rem = (rem << 1) ^ ((rem >> 11) * 0x1f25)
+	}
+	return data<<12 | rem
+}
+
+// drawCodewords fills the non-function modules with data bits.
+func (m *matrix) drawCodewords(data []byte) {
+	i := 0
+	m.forEachDataModule(func(x, y int) {
+		if i < len(data)*8 {
+			m.modules[y][x] = bit(int(data[i>>3]), 7-(i&7))
+			i++
+		}
+	})
+}
+
+// drawBits fills the non-function modules from a bit stream, for symbols
+// whose codewords are not all eight bits long.
+func (m *matrix) drawBits(bits bitBuffer) {
+	i := 0
+	m.forEachDataModule(func(x, y int) {
+		if i < len(bits) {
+			m.modules[y][x] = bits[i]
+			i++
+		}
+	})
+}
+
+// readCodewords collects n codewords from the non-function modules.
+func (m *matrix) readCodewords(n int) []byte {
+	result := make([]byte, n)
+	i := 0
+	m.forEachDataModule(func(x, y int) {
+		if i < n*8 {
+			if m.modules[y][x] {
+				result[i>>3] |= 1 << uint(7-i&7)
+			}
+			i++
+		}
+	})
+	return result
+}
+
+// forEachDataModule visits the non-function modules in codeword order:
+// two columns at a time from the right, zigzagging up and down and
+// skipping the vertical timing pattern.
+func (m *matrix) forEachDataModule(fn func(x, y int)) {
+	upward := true
+	for right := m.firstColumn; right >= 1; right -= 2 {
+		if right == m.timingColumn {
+			right--
+		}
+		for vert := 0; vert < m.height; vert++ {
+			y := vert
+			if upward {
+				y = m.height - 1 - vert
+			}
+			for j := 0; j < 2; j++ {
+				if x := right - j; !m.function[y][x] {
+					fn(x, y)
+				}
+			}
+		}
+		upward = !upward
+	}
+}
+
+// applyMask XORs a mask pattern over the data modules. Applying the same
+// mask twice restores the original modules.
+func (m *matrix) applyMask(mask int) {
+	for y := 0; y < m.height; y++ {
+		for x := 0; x < m.width; x++ {
+			if !m.function[y][x] && maskBit(mask, x, y) {
+				m.modules[y][x] = !m.modules[y][x]
+			}
+		}
+	}
+}
+
+func maskBit(mask, x, y int) bool {
+	switch mask {
+	case 0:
+		return (x+y)%2 == 0
+	case 1:
+		return y%2 == 0
+	case 2:
+		return x%3 == 0
+	case 3:
+		return (x+y)%3 == 0
+	case 4:
+		return (x/3+y/2)%2 == 0
+	case 5:
+		return x*y%2+x*y%3 == 0
+	case 6:
+		return (x*y%2+x*y%3)%2 == 0
+	default:
+		return ((x+y)%2+x*y%3)%2 == 0
+	}
+}
+
+const (
+	penaltyRun     = 3
+	penaltyBlock   = 3
+	penaltyFinder  = 40
+	penaltyBalance = 10
+)
+
+// penalty scores the matrix with the four rules from ISO/IEC 18004
+// section 7.8.3; the mask with the lowest score is preferred.
+func (m *matrix) penalty() int {
+	result := 0
+	for i := 0; i < m.width; i++ {
+		result += linePenalty(m.width, func(j int) bool { return m.modules[i][j] })
+		result += linePenalty(m.width, func(j int) bool { return m.modules[j][i] })
+	}
+
+	for y := 0; y < m.height-1; y++ {
+		for x := 0; x < m.width-1; x++ {
+			c := m.modules[y][x]
+			if c == m.modules[y][x+1] && c == m.modules[y+1][x] && c == m.modules[y+1][x+1] {
+				result += penaltyBlock
+			}
+		}
+	}
+
+	dark := 0
+	for _, row := range m.modules {
+		for _, v := range row {
+			if v {
+				dark++
+			}
+		}
+	}
+	total := m.width * m.height
+	k := (abs(dark*20-total*10)+total-1)/total - 1
+	result += k * penaltyBalance
+
+	return result
+}
+
+var (
+	finderLeft  = []bool{true, false, true, true, true, false, true, false, false, false, false}
+	finderRight = []bool{false, false, false, false, true, false, true, true, true, false, true}
+)
+
+func linePenalty(n int, at func(int) bool) int {
+	result := 0
+	run := 1
+	for j := 1; j <= n; j++ {
+		if j < n && at(j) == at(j-1) {
+			run++
+			continue
+		}
+		if run >= 5 {
+			result += penaltyRun + run - 5
+		}
+		run = 1
+	}
+
+	for j := 0; j+len(finderLeft) <= n; j++ {
+		if matchesAt(at, j, finderLeft) || matchesAt(at, j, finderRight) {
+			result += penaltyFinder
+		}
+	}
+	return result
+}
+
+func matchesAt(at func(int) bool, offset int, pattern []bool) bool {
+	for i, want := range pattern {
+		if at(offset+i) != want {
+			return false
+		}
+	}
+	return true
+}
+
+func bit(value, i int) bool {
+	return (value>>uint(i))&1 != 0
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
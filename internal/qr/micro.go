@@ -0,0 +1,241 @@
+package qr
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Micro QR codes (M1-M4) have one finder pattern in the top-left corner,
+// timing patterns along the top and left edges, a single error correction
+// block and a two-module quiet zone. M1 only detects errors; it is treated
+// as level L here.
+
+const maxMicroVersion = 4
+
+// microDataBits is the data capacity in bits indexed by [version][level]
+// for levels L, M and Q; zero marks a level the version does not offer.
+var microDataBits = [maxMicroVersion + 1][3]int{
+	{},
+	{20, 0, 0},
+	{40, 32, 0},
+	{84, 68, 0},
+	{128, 112, 80},
+}
+
+// microECCodewords is the number of error correction codewords indexed
+// like microDataBits.
+var microECCodewords = [maxMicroVersion + 1][3]int{
+	{},
+	{2, 0, 0},
+	{5, 6, 0},
+	{6, 8, 0},
+	{8, 10, 14},
+}
+
+// microMasks maps the four Micro QR mask numbers to the QR patterns they
+// share.
+var microMasks = [4]int{1, 4, 6, 7}
+
+func microSize(version int) int {
+	return version*2 + 9
+}
+
+func microCapacity(version int, level RecoveryLevel) int {
+	if level < Low || level > High {
+		return 0
+	}
+	return microDataBits[version][level]
+}
+
+// microFraming frames segments for a Micro QR version. The mode indicator
+// grows by a bit per version and M1 has none at all.
+type microFraming int
+
+func (f microFraming) modeIndicator(m Mode) (uint32, int) {
+	bits := int(f) - 1
+	switch m {
+	case ModeNumeric:
+		return 0, bits
+	case ModeAlphanumeric:
+		return 1, bits
+	case ModeKanji:
+		return 3, bits
+	default:
+		return 2, bits
+	}
+}
+
+func (f microFraming) charCountBits(m Mode) int {
+	switch m {
+	case ModeNumeric:
+		return [5]int{0, 3, 4, 5, 6}[f]
+	case ModeAlphanumeric:
+		return [5]int{0, 0, 3, 4, 5}[f]
+	case ModeKanji:
+		return [5]int{0, 0, 0, 3, 4}[f]
+	default:
+		return [5]int{0, 0, 0, 4, 5}[f]
+	}
+}
+
+func (f microFraming) terminatorBits() int {
+	return int(f)*2 + 1
+}
+
+// planMicro is planSymbol for Micro QR.
+func planMicro(data string, opts Options) (int, []Segment, error) {
+	level := opts.Level
+	if level < Low || level > Highest {
+		return 0, nil, fmt.Errorf("invalid error correction level: %d", level)
+	}
+	if level == Highest {
+		return 0, nil, errors.New("error correction level H is not available for Micro QR")
+	}
+	if opts.Append.Total > 0 {
+		return 0, nil, errors.New("structured append is not available for Micro QR")
+	}
+	if opts.ECI != 0 {
+		return 0, nil, errors.New("ECI designators are not available for Micro QR")
+	}
+	if opts.GS1 {
+		return 0, nil, errors.New("GS1 (FNC1) data is not available for Micro QR")
+	}
+
+	minV, maxV := 1, maxMicroVersion
+	if opts.Version != 0 {
+		if opts.Version < 1 || opts.Version > maxMicroVersion {
+			return 0, nil, fmt.Errorf("invalid Micro QR version %d (want 1-%d)", opts.Version, maxMicroVersion)
+		}
+		if microCapacity(opts.Version, level) == 0 {
+			return 0, nil, fmt.Errorf("error correction level %s is not available for Micro QR version M%d", level, opts.Version)
+		}
+		minV, maxV = opts.Version, opts.Version
+	}
+
+	for v := minV; v <= maxV; v++ {
+		capacity := microCapacity(v, level)
+		if capacity == 0 {
+			continue
+		}
+		segs, err := makeSegments(data, opts.Mode, microFraming(v), opts.GS1)
+		if err != nil {
+			return 0, nil, err
+		}
+		if used := segmentsBitLength(segs, microFraming(v)); used >= 0 && used <= capacity {
+			return v, segs, nil
+		}
+	}
+
+	if opts.Version != 0 {
+		return 0, nil, fmt.Errorf("%w for Micro QR version M%d at level %s", ErrDataTooLong, opts.Version, level)
+	}
+	return 0, nil, fmt.Errorf("%w for a Micro QR code at level %s", ErrDataTooLong, level)
+}
+
+func encodeMicro(data string, opts Options) (*Code, error) {
+	if opts.Mask != MaskAuto && (opts.Mask < 0 || opts.Mask > 3) {
+		return nil, fmt.Errorf("invalid Micro QR mask pattern %d (want 0-3)", opts.Mask)
+	}
+
+	version, segs, err := planSymbol(data, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	level := opts.Level
+	capacity := microCapacity(version, level)
+	codewords := packSegments(nil, segs, microFraming(version), capacity)
+	ecc := rsRemainder(codewords, rsDivisor(microECCodewords[version][level]))
+
+	// The last data codeword of M1 and M3 is only four bits long, so the
+	// symbol is filled from a bit stream rather than whole bytes.
+	var bits bitBuffer
+	for i := 0; i < capacity; i++ {
+		bits = append(bits, bit(int(codewords[i>>3]), 7-i&7))
+	}
+	for _, b := range ecc {
+		bits.appendBits(uint32(b), 8)
+	}
+
+	size := microSize(version)
+	m := newRectMatrix(size, size)
+	m.drawMicroFunctionPatterns()
+	m.drawBits(bits)
+
+	mask := opts.Mask
+	if mask == MaskAuto {
+		best := -1
+		for candidate := range microMasks {
+			m.applyMask(microMasks[candidate])
+			if score := m.microMaskScore(); score > best {
+				best = score
+				mask = candidate
+			}
+			m.applyMask(microMasks[candidate])
+		}
+	}
+	m.applyMask(microMasks[mask])
+	m.drawMicroFormatBits(microSymbolNumber(version, level), mask)
+
+	return &Code{
+		Symbology: SymbolMicro,
+		Version:   version,
+		Level:     level,
+		Mask:      mask,
+		Segments:  segs,
+		modules:   m.modules,
+
+		usedBits:     segmentsBitLength(segs, microFraming(version)),
+		capacityBits: capacity,
+	}, nil
+}
+
+// microSymbolNumber identifies the version and level pair in the format
+// information: 0 for M1, then M2-L, M2-M, M3-L, M3-M, M4-L, M4-M and M4-Q.
+func microSymbolNumber(version int, level RecoveryLevel) int {
+	if version == 1 {
+		return 0
+	}
+	return version*2 - 3 + int(level)
+}
+
+func (m *matrix) drawMicroFunctionPatterns() {
+	for i := 0; i < m.width; i++ {
+		m.setFunction(i, 0, i%2 == 0)
+		m.setFunction(0, i, i%2 == 0)
+	}
+	m.drawFinder(3, 3)
+
+	// Reserve the format area; the real bits follow mask selection.
+	m.drawMicroFormatBits(0, 0)
+}
+
+// drawMicroFormatBits writes the single copy of the format information
+// in the L around the finder pattern separator.
+func (m *matrix) drawMicroFormatBits(symbolNumber, mask int) {
+	bits := formatCode(symbolNumber<<2|mask) ^ 0x4445
+	for i := 0; i < 8; i++ {
+		m.setFunction(8, i+1, bit(bits, i))
+	}
+	for i := 8; i < 15; i++ {
+		m.setFunction(15-i, 8, bit(bits, i))
+	}
+}
+
+// microMaskScore rates a masked Micro QR symbol by the dark modules along
+// its right and bottom edges; unlike the QR penalty, higher is better.
+func (m *matrix) microMaskScore() int {
+	right, bottom := 0, 0
+	for i := 1; i < m.width; i++ {
+		if m.modules[i][m.width-1] {
+			right++
+		}
+		if m.modules[m.height-1][i] {
+			bottom++
+		}
+	}
+	if right > bottom {
+		right, bottom = bottom, right
+	}
+	return right*16 + bottom
+}
@@ -2,29 +2,64 @@ package qr
 
 import (
 	"image/color"
-
-	qrcode "github.com/skip2/go-qrcode"
 )
 
 // Options configures QR code generation.
 type Options struct {
-	Size            int
-	Level           qrcode.RecoveryLevel
-	ForegroundColor color.Color
-	BackgroundColor color.Color
-	BorderSize      int
-	LogoPath        string
-	LogoScale       float64
+	Size               int
+	Symbology          Symbology
+	Level              RecoveryLevel
+	Version            int  // 0 picks the smallest version that fits
+	Mask               int  // MaskAuto picks the lowest-penalty pattern
+	Mode               Mode // ModeAuto mixes segments for the smallest symbol
+	Append             StructuredAppend
+	ECI                int  // 0 writes no ECI designator; see CharsetForECI
+	GS1                bool // FNC1 in first position; data is a GS1 element string
+	ForegroundColor    color.Color
+	BackgroundColor    color.Color
+	ForegroundGradient *Gradient // replaces ForegroundColor when set
+	BorderSize         int
+	ModuleStyle        ModuleStyle
+	EyeFrame           EyeShape
+	EyePupil           EyeShape
+	EyeFrameColor      color.Color     // nil uses ForegroundColor
+	EyePupilColor      color.Color     // nil uses the frame colour
+	SVGGeometry        SVGGeometry     // how SVG draws square modules
+	Caption            string          // drawn outside the quiet zone, extending the canvas
+	CaptionPosition    CaptionPosition // CaptionBottom by default
+	FontPath           string          // TrueType or OpenType caption font; empty uses Go Regular
+	Frame              Frame
+	LogoPath           string
+	LogoScale          float64
+	PhysicalSize       float64 // symbol width in mm; 0 uses Size pixels at DPI, or Size points
+	DPI                int     // pixels per inch for physical sizes and the PNG pHYs chunk; 0 for none
+	Fit                Fit     // how PNG output meets a size that is not whole modules
+	PageWidth          float64 // PDF page size in mm; 0 fits the page to the symbol
+	PageHeight         float64
 }
 
 // DefaultOptions returns sensible defaults for QR code generation.
 func DefaultOptions() Options {
 	return Options{
 		Size:            256,
-		Level:           qrcode.Medium,
+		Level:           Medium,
+		Mask:            MaskAuto,
 		ForegroundColor: color.Black,
 		BackgroundColor: color.White,
 		BorderSize:      4,
 		LogoScale:       0.2,
 	}
 }
+
+// physicalMM returns the width of the symbol, quiet zone included, in
+// millimetres: PhysicalSize, or Size pixels at DPI, or 0 when neither is
+// set.
+func (o Options) physicalMM() float64 {
+	if o.PhysicalSize > 0 {
+		return o.PhysicalSize
+	}
+	if o.DPI > 0 && o.Size > 0 {
+		return float64(o.Size) / float64(o.DPI) * 25.4
+	}
+	return 0
+}
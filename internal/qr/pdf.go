@@ -0,0 +1,493 @@
+package qr
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/font/sfnt"
+)
+
+const (
+	// pointsPerMM converts millimetres to PDF points (1/72 inch).
+	pointsPerMM = 72 / 25.4
+	// logoDPI is the resolution a logo is embedded at in vector output.
+	logoDPI = 300
+)
+
+// PDF renders a QR code as a single-page vector PDF. The symbol, quiet
+// zone included, is PhysicalSize millimetres wide, or Size points when
+// that is unset; with any caption and frame around it, it sits centred on
+// a PageWidth by PageHeight page, or on a page of its own size.
+func PDF(data string, opts Options) ([]byte, error) {
+	bitmap, err := Bitmap(data, opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(bitmap) == 0 {
+		return nil, fmt.Errorf("empty QR bitmap")
+	}
+
+	module := moduleMM(opts, bitmap) * pointsPerMM
+	deco, err := newDecoration(opts, float64(len(bitmap[0])), float64(len(bitmap)), 1, false)
+	if err != nil {
+		return nil, err
+	}
+	unitsW, unitsH := codeUnits(bitmap, deco)
+	width, height := unitsW*module, unitsH*module
+	pageW, pageH := width, height
+	if opts.PageWidth > 0 && opts.PageHeight > 0 {
+		pageW, pageH = opts.PageWidth*pointsPerMM, opts.PageHeight*pointsPerMM
+		if width > pageW+1e-6 || height > pageH+1e-6 {
+			return nil, fmt.Errorf("symbol is %.1f x %.1f mm, larger than the %.1f x %.1f mm page",
+				width/pointsPerMM, height/pointsPerMM, opts.PageWidth, opts.PageHeight)
+		}
+	}
+
+	doc := newPDFDocument()
+	page := doc.newPage(pageW, pageH)
+	if err := page.drawCode(bitmap, deco, opts, (pageW-width)/2, (pageH-height)/2, module); err != nil {
+		return nil, err
+	}
+	doc.addPage(page)
+	return doc.bytes(), nil
+}
+
+// moduleMM returns the width of one module in millimetres: the physical
+// size spread over the longer side of the bitmap, or Size points when no
+// physical size is set.
+func moduleMM(opts Options, bitmap [][]bool) float64 {
+	total := float64(max(len(bitmap[0]), len(bitmap)))
+	if mm := opts.physicalMM(); mm > 0 {
+		return mm / total
+	}
+	size := opts.Size
+	if size <= 0 {
+		size = DefaultOptions().Size
+	}
+	return float64(size) / pointsPerMM / total
+}
+
+// pdfDocument assembles the objects of a PDF file. Object 1 is the
+// catalog and object 2 the page tree; pages are added in order.
+type pdfDocument struct {
+	objects [][]byte
+	pages   []int
+}
+
+func newPDFDocument() *pdfDocument {
+	d := &pdfDocument{}
+	d.reserve() // catalog
+	d.reserve() // page tree
+	return d
+}
+
+func (d *pdfDocument) reserve() int {
+	d.objects = append(d.objects, nil)
+	return len(d.objects)
+}
+
+func (d *pdfDocument) set(n int, obj string) {
+	d.objects[n-1] = []byte(obj)
+}
+
+func (d *pdfDocument) add(obj string) int {
+	n := d.reserve()
+	d.set(n, obj)
+	return n
+}
+
+// addStream stores data Flate-compressed under the given dictionary
+// entries, which must not include /Length or /Filter.
+func (d *pdfDocument) addStream(dict string, data []byte) int {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	zw.Write(data)
+	zw.Close()
+
+	n := d.reserve()
+	d.objects[n-1] = fmt.Appendf(nil, "<< %s /Filter /FlateDecode /Length %d >>\nstream\n%s\nendstream", dict, buf.Len(), buf.Bytes())
+	return n
+}
+
+func (d *pdfDocument) addPage(p *pdfPage) {
+	content := d.addStream("", p.content.Bytes())
+	n := d.add(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] /Resources %s /Contents %d 0 R >>",
+		pdfNum(p.width), pdfNum(p.height), p.resources(), content))
+	d.pages = append(d.pages, n)
+}
+
+func (d *pdfDocument) bytes() []byte {
+	d.set(1, "<< /Type /Catalog /Pages 2 0 R >>")
+	kids := make([]string, len(d.pages))
+	for i, n := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", n)
+	}
+	d.set(2, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make([]int, len(d.objects))
+	for i, obj := range d.objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(d.objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(d.objects)+1, xref)
+	return buf.Bytes()
+}
+
+// pdfPage collects the drawing operators and named resources of a page.
+type pdfPage struct {
+	doc           *pdfDocument
+	width, height float64
+	content       bytes.Buffer
+
+	patterns  map[string]int
+	xobjects  map[string]int
+	extStates map[string]string
+	alpha     uint8 // fill alpha of the current graphics state
+}
+
+func (d *pdfDocument) newPage(width, height float64) *pdfPage {
+	return &pdfPage{
+		doc:       d,
+		width:     width,
+		height:    height,
+		patterns:  map[string]int{},
+		xobjects:  map[string]int{},
+		extStates: map[string]string{},
+		alpha:     0xff,
+	}
+}
+
+func (p *pdfPage) resources() string {
+	var b strings.Builder
+	b.WriteString("<<")
+	writeRefs := func(kind string, refs map[string]int) {
+		if len(refs) == 0 {
+			return
+		}
+		fmt.Fprintf(&b, " /%s <<", kind)
+		for _, name := range sortedKeys(refs) {
+			fmt.Fprintf(&b, " /%s %d 0 R", name, refs[name])
+		}
+		b.WriteString(" >>")
+	}
+	writeRefs("Pattern", p.patterns)
+	writeRefs("XObject", p.xobjects)
+	if len(p.extStates) > 0 {
+		b.WriteString(" /ExtGState <<")
+		for _, name := range sortedKeys(p.extStates) {
+			fmt.Fprintf(&b, " /%s %s", name, p.extStates[name])
+		}
+		b.WriteString(" >>")
+	}
+	b.WriteString(" >>")
+	return b.String()
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// drawCode draws a bordered bitmap with its caption and frame, if any,
+// with its top-left corner x, y points from the top-left of the page.
+func (p *pdfPage) drawCode(bitmap [][]bool, deco *decoration, opts Options, x, y, module float64) error {
+	if deco != nil {
+		p.drawDecoration(deco, opts, x, y, module)
+		x, y = x+deco.symbolX*module, y+deco.symbolY*module
+	}
+	return p.drawSymbol(bitmap, opts, x, y, module)
+}
+
+// drawDecoration draws the background, frame and caption of a decoration
+// laid out in module units.
+func (p *pdfPage) drawDecoration(d *decoration, opts Options, x, y, module float64) {
+	fmt.Fprintf(&p.content, "q %s 0 0 %s %s %s cm\n", pdfNum(module), pdfNum(-module), pdfNum(x), pdfNum(p.height-y))
+	defer func(alpha uint8) { p.alpha = alpha }(p.alpha) // restored by Q
+	if colorToRGBA(opts.BackgroundColor).A > 0 {
+		p.setFill(opts.BackgroundColor)
+		fmt.Fprintf(&p.content, "0 0 %s %s re f\n", pdfNum(d.width), pdfNum(d.height))
+	}
+	if len(d.frame) > 0 {
+		p.setFill(d.frameFill)
+		for _, shape := range d.frame {
+			shape.pdfPath(&p.content)
+		}
+		p.content.WriteString("f\n")
+	}
+	if len(d.caption) > 0 {
+		p.setFill(d.captionFill)
+		pdfOutline(&p.content, d.caption)
+		p.content.WriteString("f\n")
+	}
+	p.content.WriteString("Q\n")
+}
+
+// drawSymbol draws a bordered bitmap with its top-left corner x, y points
+// from the top-left of the page, module points to a module. Everything
+// inside is drawn in module units with the y axis pointing down, as in
+// the SVG renderer.
+func (p *pdfPage) drawSymbol(bitmap [][]bool, opts Options, x, y, module float64) error {
+	cols, rows := len(bitmap[0]), len(bitmap)
+	matrix := fmt.Sprintf("%s 0 0 %s %s %s", pdfNum(module), pdfNum(-module), pdfNum(x), pdfNum(p.height-y))
+
+	fmt.Fprintf(&p.content, "q %s cm\n", matrix)
+	defer func(alpha uint8) { p.alpha = alpha }(p.alpha) // restored by Q
+	if colorToRGBA(opts.BackgroundColor).A > 0 {
+		p.setFill(opts.BackgroundColor)
+		fmt.Fprintf(&p.content, "0 0 %d %d re f\n", cols, rows)
+	}
+
+	var gradient string
+	if g := opts.ForegroundGradient; g != nil {
+		if err := g.validate(); err != nil {
+			return err
+		}
+		border := float64(max(opts.BorderSize, 0))
+		gradient = p.addGradient(g, matrix, border, border, float64(cols)-border*2, float64(rows)-border*2)
+	}
+	fill := func(c color.Color, useGradient bool) {
+		if useGradient && gradient != "" {
+			p.setAlpha(0xff)
+			fmt.Fprintf(&p.content, "/Pattern cs /%s scn\n", gradient)
+			return
+		}
+		p.setFill(c)
+	}
+
+	if !opts.shaped() {
+		// Runs of dark modules along each row become one rectangle.
+		fill(opts.ForegroundColor, true)
+		for yy, row := range bitmap {
+			for xx := 0; xx < len(row); xx++ {
+				if !row[xx] {
+					continue
+				}
+				start := xx
+				for xx < len(row) && row[xx] {
+					xx++
+				}
+				fmt.Fprintf(&p.content, "%d %d %d 1 re\n", start, yy, xx-start)
+			}
+		}
+		p.content.WriteString("f\n")
+	} else {
+		for _, layer := range shapeLayers(bitmap, opts) {
+			if len(layer.shapes) == 0 {
+				continue
+			}
+			fill(layer.fill, layer.gradient)
+			for _, shape := range layer.shapes {
+				shape.pdfPath(&p.content)
+			}
+			p.content.WriteString("f\n")
+		}
+	}
+
+	if opts.LogoPath != "" {
+		if err := p.drawLogo(opts, max(cols, rows), module); err != nil {
+			return err
+		}
+	}
+	p.content.WriteString("Q\n")
+	return nil
+}
+
+// setFill selects c as the fill colour.
+func (p *pdfPage) setFill(c color.Color) {
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	p.setAlpha(n.A)
+	fmt.Fprintf(&p.content, "%s %s %s rg\n", pdfNum(float64(n.R)/255), pdfNum(float64(n.G)/255), pdfNum(float64(n.B)/255))
+}
+
+// setAlpha switches to a graphics state with the given fill alpha when it
+// differs from the current one.
+func (p *pdfPage) setAlpha(a uint8) {
+	if a == p.alpha {
+		return
+	}
+	name := fmt.Sprintf("GA%d", a)
+	p.extStates[name] = fmt.Sprintf("<< /ca %s >>", pdfNum(float64(a)/255))
+	fmt.Fprintf(&p.content, "/%s gs\n", name)
+	p.alpha = a
+}
+
+// addGradient registers a shading pattern for g laid over the box, in
+// module units, and returns its resource name. matrix maps module units
+// to the page, since patterns ignore the current transformation.
+func (p *pdfPage) addGradient(g *Gradient, matrix string, x, y, w, h float64) string {
+	rgb := func(c color.Color) string {
+		n := color.NRGBAModel.Convert(c).(color.NRGBA)
+		return fmt.Sprintf("[%s %s %s]", pdfNum(float64(n.R)/255), pdfNum(float64(n.G)/255), pdfNum(float64(n.B)/255))
+	}
+	var functions, bounds, encode []string
+	for i := 0; i+1 < len(g.Stops); i++ {
+		functions = append(functions, fmt.Sprintf("<< /FunctionType 2 /Domain [0 1] /C0 %s /C1 %s /N 1 >>", rgb(g.Stops[i]), rgb(g.Stops[i+1])))
+		encode = append(encode, "0 1")
+		if i > 0 {
+			bounds = append(bounds, pdfNum(float64(i)/float64(len(g.Stops)-1)))
+		}
+	}
+	function := fmt.Sprintf("<< /FunctionType 3 /Domain [0 1] /Functions [%s] /Bounds [%s] /Encode [%s] >>",
+		strings.Join(functions, " "), strings.Join(bounds, " "), strings.Join(encode, " "))
+
+	cx, cy := x+w/2, y+h/2
+	shading := ""
+	if g.Type == GradientRadial {
+		shading = fmt.Sprintf("<< /ShadingType 3 /ColorSpace /DeviceRGB /Coords [%s %s 0 %s %s %s] /Function %s /Extend [true true] >>",
+			pdfNum(cx), pdfNum(cy), pdfNum(cx), pdfNum(cy), pdfNum(g.radius(w, h)), function)
+	} else {
+		x1, y1, x2, y2 := g.axis(cx, cy, w, h)
+		shading = fmt.Sprintf("<< /ShadingType 2 /ColorSpace /DeviceRGB /Coords [%s %s %s %s] /Function %s /Extend [true true] >>",
+			pdfNum(x1), pdfNum(y1), pdfNum(x2), pdfNum(y2), function)
+	}
+
+	name := fmt.Sprintf("P%d", len(p.patterns))
+	p.patterns[name] = p.doc.add(fmt.Sprintf("<< /Type /Pattern /PatternType 2 /Matrix [%s] /Shading %s >>", matrix, shading))
+	return name
+}
+
+// drawLogo paints the logo and its background pad centred on a symbol
+// totalModules wide, matching the SVG overlay. The image is embedded at
+// logoDPI for its printed size.
+func (p *pdfPage) drawLogo(opts Options, totalModules int, module float64) error {
+	scale := clampLogoScale(opts.LogoScale)
+	logoUnits := float64(totalModules) * scale
+	bgUnits := logoUnits + logoUnits*logoPaddingScale*2
+	bgPos := (float64(totalModules) - bgUnits) / 2
+	logoPos := (float64(totalModules) - logoUnits) / 2
+
+	pixels := max(1, int(math.Round(logoUnits*module/72*logoDPI)))
+	logo, err := scaledLogo(opts.LogoPath, pixels)
+	if err != nil {
+		return err
+	}
+	name := fmt.Sprintf("Im%d", len(p.xobjects))
+	p.xobjects[name] = p.doc.addImage(logo)
+
+	if colorToRGBA(opts.BackgroundColor).A > 0 {
+		p.setFill(opts.BackgroundColor)
+		fmt.Fprintf(&p.content, "%s %s %s %s re f\n", pdfNum(bgPos), pdfNum(bgPos), pdfNum(bgUnits), pdfNum(bgUnits))
+	}
+	// Images fill the unit square upwards, so flip it back into the
+	// downward module space.
+	fmt.Fprintf(&p.content, "q %s 0 0 %s %s %s cm /%s Do Q\n",
+		pdfNum(logoUnits), pdfNum(-logoUnits), pdfNum(logoPos), pdfNum(logoPos+logoUnits), name)
+	return nil
+}
+
+// addImage embeds img as an RGB image XObject, with a soft mask for its
+// alpha channel when it has any transparency.
+func (d *pdfDocument) addImage(img *image.RGBA) int {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	rgb := make([]byte, 0, w*h*3)
+	alpha := make([]byte, 0, w*h)
+	opaque := true
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			n := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			rgb = append(rgb, n.R, n.G, n.B)
+			alpha = append(alpha, n.A)
+			opaque = opaque && n.A == 0xff
+		}
+	}
+
+	dict := fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceRGB /BitsPerComponent 8", w, h)
+	if !opaque {
+		mask := d.addStream(fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceGray /BitsPerComponent 8", w, h), alpha)
+		dict += fmt.Sprintf(" /SMask %d 0 R", mask)
+	}
+	return d.addStream(dict, rgb)
+}
+
+// pdfPath appends the outline of the shape as PDF path operators, with
+// corners approximated by cubic Béziers.
+func (s roundedRect) pdfPath(b *bytes.Buffer) {
+	pt := func(x, y float64) string {
+		return pdfNum(x) + " " + pdfNum(y)
+	}
+	corner := func(r, cx, cy, fromX, fromY, toX, toY float64) {
+		if r == 0 {
+			b.WriteString(pt(cx, cy) + " l\n")
+			return
+		}
+		b.WriteString(pt(cx+fromX*r, cy+fromY*r) + " l\n")
+		b.WriteString(pt(cx+fromX*r*(1-kappa), cy+fromY*r*(1-kappa)) + " " +
+			pt(cx+toX*r*(1-kappa), cy+toY*r*(1-kappa)) + " " +
+			pt(cx+toX*r, cy+toY*r) + " c\n")
+	}
+
+	x0, y0, x1, y1 := s.x, s.y, s.x+s.w, s.y+s.h
+	if s.r == [4]float64{} && !s.hole {
+		b.WriteString(pt(x0, y0) + " " + pt(s.w, s.h) + " re\n")
+		return
+	}
+	b.WriteString(pt(x0+s.r[0], y0) + " m\n")
+	if s.hole {
+		corner(s.r[0], x0, y0, 1, 0, 0, 1)
+		corner(s.r[3], x0, y1, 0, -1, 1, 0)
+		corner(s.r[2], x1, y1, -1, 0, 0, -1)
+		corner(s.r[1], x1, y0, 0, 1, -1, 0)
+	} else {
+		corner(s.r[1], x1, y0, -1, 0, 0, 1)
+		corner(s.r[2], x1, y1, 0, -1, -1, 0)
+		corner(s.r[3], x0, y1, 1, 0, 0, -1)
+		corner(s.r[0], x0, y0, 0, 1, 1, 0)
+	}
+	b.WriteString("h\n")
+}
+
+// pdfOutline appends glyph outlines as PDF path operators, raising
+// quadratic segments to cubics and closing each contour.
+func pdfOutline(b *bytes.Buffer, segments []pathSegment) {
+	pt := func(p [2]float64) string { return pdfNum(p[0]) + " " + pdfNum(p[1]) }
+	var cur [2]float64
+	for i, s := range segments {
+		switch s.op {
+		case sfnt.SegmentOpMoveTo:
+			if i > 0 {
+				b.WriteString("h\n")
+			}
+			b.WriteString(pt(s.args[0]) + " m\n")
+			cur = s.args[0]
+		case sfnt.SegmentOpLineTo:
+			b.WriteString(pt(s.args[0]) + " l\n")
+			cur = s.args[0]
+		case sfnt.SegmentOpQuadTo:
+			ctrl, end := s.args[0], s.args[1]
+			c1 := [2]float64{cur[0] + (ctrl[0]-cur[0])*2/3, cur[1] + (ctrl[1]-cur[1])*2/3}
+			c2 := [2]float64{end[0] + (ctrl[0]-end[0])*2/3, end[1] + (ctrl[1]-end[1])*2/3}
+			b.WriteString(pt(c1) + " " + pt(c2) + " " + pt(end) + " c\n")
+			cur = end
+		case sfnt.SegmentOpCubeTo:
+			b.WriteString(pt(s.args[0]) + " " + pt(s.args[1]) + " " + pt(s.args[2]) + " c\n")
+			cur = s.args[2]
+		}
+	}
+	if len(segments) > 0 {
+		b.WriteString("h\n")
+	}
+}
+
+// pdfNum formats a number with at most four decimals.
+func pdfNum(f float64) string {
+	return strconv.FormatFloat(math.Round(f*10000)/10000, 'f', -1, 64)
+}
@@ -0,0 +1,139 @@
+package qr_test
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image/color"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/eliaseffects/qr-cli/internal/qr"
+)
+
+// pdfStreams checks the cross-reference table of a PDF and returns its
+// decompressed streams in file order.
+func pdfStreams(t *testing.T, pdf []byte) []string {
+	t.Helper()
+
+	if !bytes.HasPrefix(pdf, []byte("%PDF-1.4\n")) || !bytes.HasSuffix(pdf, []byte("%%EOF\n")) {
+		t.Fatal("missing PDF header or trailer")
+	}
+	start := regexp.MustCompile(`startxref\n(\d+)\n`).FindSubmatch(pdf)
+	if start == nil {
+		t.Fatal("missing startxref")
+	}
+	xref, _ := strconv.Atoi(string(start[1]))
+	if !bytes.HasPrefix(pdf[xref:], []byte("xref\n")) {
+		t.Fatalf("startxref %d does not point at the xref table", xref)
+	}
+	for i, m := range regexp.MustCompile(`(\d{10}) 00000 n `).FindAllSubmatch(pdf[xref:], -1) {
+		off, _ := strconv.Atoi(string(m[1]))
+		if want := fmt.Sprintf("%d 0 obj\n", i+1); !bytes.HasPrefix(pdf[off:], []byte(want)) {
+			t.Fatalf("xref entry %d points at %q", i+1, pdf[off:off+10])
+		}
+	}
+
+	var streams []string
+	for _, m := range regexp.MustCompile(`(?s)/Length (\d+) >>\nstream\n`).FindAllSubmatchIndex(pdf, -1) {
+		n, _ := strconv.Atoi(string(pdf[m[2]:m[3]]))
+		zr, err := zlib.NewReader(bytes.NewReader(pdf[m[1] : m[1]+n]))
+		if err != nil {
+			t.Fatalf("stream: %v", err)
+		}
+		data, err := io.ReadAll(zr)
+		if err != nil {
+			t.Fatalf("stream: %v", err)
+		}
+		streams = append(streams, string(data))
+	}
+	return streams
+}
+
+func TestPDF(t *testing.T) {
+	opts := qr.DefaultOptions()
+	opts.PhysicalSize = 40
+	opts.PageWidth, opts.PageHeight = 210, 297
+
+	pdf, err := qr.PDF("https://example.com", opts)
+	if err != nil {
+		t.Fatalf("PDF() error = %v", err)
+	}
+	if !bytes.Contains(pdf, []byte("/MediaBox [0 0 595.2756 841.8898]")) {
+		t.Error("page is not A4")
+	}
+	streams := pdfStreams(t, pdf)
+	content := streams[len(streams)-1]
+
+	bitmap, err := qr.Bitmap("https://example.com", opts)
+	if err != nil {
+		t.Fatalf("Bitmap() error = %v", err)
+	}
+
+	// The modules, quiet zone included, span 40mm centred on the page.
+	module := 40 / float64(len(bitmap)) * 72 / 25.4
+	offset := (210*72/25.4 - 40*72/25.4) / 2
+	if want := fmt.Sprintf("q %.4f 0 0 %.4f %.4f ", module, -module, offset); !strings.HasPrefix(content, want) {
+		t.Errorf("content starts %q, want %q", content[:40], want)
+	}
+
+	// The row runs must reproduce the bitmap exactly.
+	got := make([][]bool, len(bitmap))
+	for i := range got {
+		got[i] = make([]bool, len(bitmap[i]))
+	}
+	for _, m := range regexp.MustCompile(`(?m)^(\d+) (\d+) (\d+) 1 re$`).FindAllStringSubmatch(content, -1) {
+		x, _ := strconv.Atoi(m[1])
+		y, _ := strconv.Atoi(m[2])
+		w, _ := strconv.Atoi(m[3])
+		for i := x; i < x+w; i++ {
+			got[y][i] = true
+		}
+	}
+	for y := range bitmap {
+		for x := range bitmap[y] {
+			if got[y][x] != bitmap[y][x] {
+				t.Fatalf("module (%d, %d) = %v, want %v", x, y, got[y][x], bitmap[y][x])
+			}
+		}
+	}
+}
+
+func TestPDFStyled(t *testing.T) {
+	opts := qr.DefaultOptions()
+	opts.ModuleStyle = qr.StyleDot
+	opts.EyeFrame = qr.EyeCircle
+	opts.ForegroundGradient = &qr.Gradient{Type: qr.GradientRadial, Stops: []color.Color{red, blue}}
+	opts.BackgroundColor = color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0x80}
+	opts.LogoPath = filepath.Join("..", "..", "testdata", "logo.png")
+
+	pdf, err := qr.PDF("https://example.com/styled", opts)
+	if err != nil {
+		t.Fatalf("PDF() error = %v", err)
+	}
+	streams := pdfStreams(t, pdf)
+	content := streams[len(streams)-1]
+	for _, want := range []string{"/Pattern cs /P0 scn", " c\n", "/Im0 Do", "/GA128 gs"} {
+		if !strings.Contains(content, want) {
+			t.Errorf("content missing %q", want)
+		}
+	}
+	for _, want := range []string{"/ShadingType 3", "/Subtype /Image", "/ca 0.502"} {
+		if !bytes.Contains(pdf, []byte(want)) {
+			t.Errorf("PDF missing %q", want)
+		}
+	}
+}
+
+func TestPDFPageTooSmall(t *testing.T) {
+	opts := qr.DefaultOptions()
+	opts.PhysicalSize = 120
+	opts.PageWidth, opts.PageHeight = 105, 148
+	if _, err := qr.PDF("https://example.com", opts); err == nil {
+		t.Fatal("expected an error for a symbol wider than the page")
+	}
+}
@@ -0,0 +1,170 @@
+package qr
+
+// gfMultiply multiplies two elements of GF(2^8) modulo the QR polynomial
+// x^8 + x^4 + x^3 + x^2 + 1.
+func gfMultiply(x, y byte) byte {
+	var z int
+	for i := 7; i >= 0; i-- {
+		z = (z << 1) ^ ((z >> 7) * 0x11d)
+		z ^= int((y>>uint(i))&1) * int(x)
+	}
+	return byte(z)
+}
+
+// rsDivisor returns the generator polynomial of the given degree, highest
+// coefficient first with the leading 1 omitted.
+func rsDivisor(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range result {
+			result[j] = gfMultiply(result[j], root)
+			if j+1 < len(result) {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = gfMultiply(root, 0x02)
+	}
+	return result
+}
+
+// rsRemainder computes the error correction codewords for data.
+func rsRemainder(data, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i, coef := range divisor {
+			result[i] ^= gfMultiply(coef, factor)
+		}
+	}
+	return result
+}
+
+var (
+	gfExp [255]byte
+	gfLog [256]int
+)
+
+func init() {
+	x := byte(1)
+	for i := range gfExp {
+		gfExp[i] = x
+		gfLog[x] = i
+		x = gfMultiply(x, 0x02)
+	}
+}
+
+func gfPow(exponent int) byte {
+	return gfExp[((exponent%255)+255)%255]
+}
+
+func gfDivide(a, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+	return gfPow(gfLog[a] - gfLog[b])
+}
+
+// polyEval evaluates a polynomial stored lowest coefficient first.
+func polyEval(poly []byte, x byte) byte {
+	var result byte
+	for i := len(poly) - 1; i >= 0; i-- {
+		result = gfMultiply(result, x) ^ poly[i]
+	}
+	return result
+}
+
+// rsCorrect repairs a block of data followed by eccLen error correction
+// codewords in place, using Berlekamp-Massey to find the error locator
+// and Forney's formula for the error values.
+func rsCorrect(block []byte, eccLen int) error {
+	n := len(block)
+
+	syndromes := make([]byte, eccLen)
+	clean := true
+	for i := range syndromes {
+		x := gfExp[i]
+		var s byte
+		for _, c := range block {
+			s = gfMultiply(s, x) ^ c
+		}
+		syndromes[i] = s
+		if s != 0 {
+			clean = false
+		}
+	}
+	if clean {
+		return nil
+	}
+
+	locator := []byte{1}
+	prev := []byte{1}
+	errCount, shift, prevDiscrepancy := 0, 1, byte(1)
+	for k := 0; k < eccLen; k++ {
+		d := syndromes[k]
+		for i := 1; i <= errCount && i < len(locator); i++ {
+			d ^= gfMultiply(locator[i], syndromes[k-i])
+		}
+		if d == 0 {
+			shift++
+			continue
+		}
+
+		saved := append([]byte(nil), locator...)
+		if need := len(prev) + shift; len(locator) < need {
+			locator = append(locator, make([]byte, need-len(locator))...)
+		}
+		coef := gfDivide(d, prevDiscrepancy)
+		for i, c := range prev {
+			locator[i+shift] ^= gfMultiply(coef, c)
+		}
+
+		if 2*errCount <= k {
+			errCount = k + 1 - errCount
+			prev = saved
+			prevDiscrepancy = d
+			shift = 1
+		} else {
+			shift++
+		}
+	}
+	if 2*errCount > eccLen {
+		return errTooManyErrors
+	}
+
+	var positions []int
+	for j := 0; j < n; j++ {
+		if polyEval(locator, gfPow(-j)) == 0 {
+			positions = append(positions, j)
+		}
+	}
+	if len(positions) != errCount {
+		return errTooManyErrors
+	}
+
+	omega := make([]byte, eccLen)
+	for i := range omega {
+		for j := 0; j <= i && j < len(locator); j++ {
+			omega[i] ^= gfMultiply(locator[j], syndromes[i-j])
+		}
+	}
+
+	for _, j := range positions {
+		xInv := gfPow(-j)
+		var derivative byte
+		for i := 1; i < len(locator); i += 2 {
+			derivative ^= gfMultiply(locator[i], gfPow(-j*(i-1)))
+		}
+		if derivative == 0 {
+			return errTooManyErrors
+		}
+		magnitude := gfMultiply(gfPow(j), gfDivide(polyEval(omega, xInv), derivative))
+		block[n-1-j] ^= magnitude
+	}
+
+	return nil
+}
@@ -2,74 +2,164 @@ package qr
 
 import (
 	"bytes"
+	"encoding/binary"
 	"fmt"
+	"hash/crc32"
 	"image"
 	"image/color"
 	"image/draw"
 	"image/png"
+	"math"
 	"strings"
+
+	"golang.org/x/image/vector"
 )
 
 // PNG renders a QR code into PNG bytes.
 func PNG(data string, opts Options) ([]byte, error) {
-	bitmap, err := Bitmap(data, opts)
+	img, err := renderImage(data, opts)
 	if err != nil {
 		return nil, err
 	}
 
-	if opts.Size <= 0 {
-		opts.Size = DefaultOptions().Size
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	if opts.DPI > 0 {
+		return withPHYs(buf.Bytes(), opts.DPI), nil
 	}
 
-	totalModules := len(bitmap)
-	if totalModules == 0 {
-		return nil, fmt.Errorf("empty QR bitmap")
+	return buf.Bytes(), nil
+}
+
+// renderImage draws a QR code, with any caption and frame, as PNG renders
+// it.
+func renderImage(data string, opts Options) (*image.RGBA, error) {
+	bitmap, err := Bitmap(data, opts)
+	if err != nil {
+		return nil, err
 	}
 
-	size := opts.Size
-	if size < totalModules {
-		size = totalModules
+	if opts.ForegroundGradient != nil {
+		if err := opts.ForegroundGradient.validate(); err != nil {
+			return nil, err
+		}
 	}
 
-	scale := size / totalModules
-	if scale < 1 {
-		scale = 1
+	if len(bitmap) == 0 {
+		return nil, fmt.Errorf("empty QR bitmap")
 	}
-	actual := totalModules * scale
-	pad := (size - actual) / 2
+	cols, rows := len(bitmap[0]), len(bitmap)
+	layout := newPNGLayout(opts, cols, rows)
 
-	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	img := image.NewRGBA(image.Rect(0, 0, layout.width, layout.height))
 	bg := colorToRGBA(opts.BackgroundColor)
-	fg := colorToRGBA(opts.ForegroundColor)
+	fg := image.Image(&image.Uniform{C: colorToRGBA(opts.ForegroundColor)})
+	if opts.ForegroundGradient != nil {
+		// The gradient spans the symbol without its quiet zone.
+		border := max(opts.BorderSize, 0)
+		fg = gradientImage{
+			g:   opts.ForegroundGradient,
+			box: image.Rect(layout.edge(border), layout.edge(border), layout.edge(cols-border), layout.edge(rows-border)),
+		}
+	}
 
 	draw.Draw(img, img.Bounds(), &image.Uniform{C: bg}, image.Point{}, draw.Src)
 
-	for y := 0; y < totalModules; y++ {
-		for x := 0; x < totalModules; x++ {
-			if !bitmap[y][x] {
-				continue
+	if !opts.shaped() {
+		for y := 0; y < rows; y++ {
+			for x := 0; x < cols; x++ {
+				if !bitmap[y][x] {
+					continue
+				}
+				rect := image.Rect(layout.edge(x), layout.edge(y), layout.edge(x+1), layout.edge(y+1))
+				draw.Draw(img, rect, fg, rect.Min, draw.Over)
 			}
-			startX := pad + x*scale
-			startY := pad + y*scale
-			rect := image.Rect(startX, startY, startX+scale, startY+scale)
-			draw.Draw(img, rect, &image.Uniform{C: fg}, image.Point{}, draw.Src)
+		}
+	} else {
+		// Shaped modules and eyes are rasterised with anti-aliasing, one
+		// colour at a time.
+		for _, layer := range shapeLayers(bitmap, opts) {
+			z := vector.NewRasterizer(layout.width, layout.height)
+			pad := float64(layout.pad)
+			for _, shape := range layer.shapes {
+				shape.rasterize(z, layout.scale(), pad, pad)
+			}
+			src := fg
+			if !layer.gradient {
+				src = &image.Uniform{C: colorToRGBA(layer.fill)}
+			}
+			z.Draw(img, img.Bounds(), src, image.Point{})
 		}
 	}
 
 	if opts.LogoPath != "" {
-		if err := overlayLogoPNG(img, opts, size); err != nil {
+		if err := overlayLogoPNG(img, opts, max(layout.width, layout.height)); err != nil {
 			return nil, err
 		}
 	}
 
-	var buf bytes.Buffer
-	if err := png.Encode(&buf, img); err != nil {
+	deco, err := newDecoration(opts, float64(layout.width), float64(layout.height), layout.scale(), true)
+	if err != nil {
 		return nil, err
 	}
+	if deco != nil {
+		img = deco.drawPNG(img, opts)
+	}
+	return img, nil
+}
 
-	return buf.Bytes(), nil
+// withPHYs inserts a pHYs chunk recording dpi after the IHDR chunk of an
+// encoded PNG, which image/png does not write itself.
+func withPHYs(pngData []byte, dpi int) []byte {
+	ppm := uint32(math.Round(float64(dpi) / 0.0254))
+	chunk := make([]byte, 0, 21)
+	chunk = binary.BigEndian.AppendUint32(chunk, 9)
+	chunk = append(chunk, "pHYs"...)
+	chunk = binary.BigEndian.AppendUint32(chunk, ppm)
+	chunk = binary.BigEndian.AppendUint32(chunk, ppm)
+	chunk = append(chunk, 1) // unit: metre
+	chunk = binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+
+	// The 8-byte signature is followed by IHDR: length, type, 13 bytes of
+	// data and a CRC.
+	end := 8 + 4 + 4 + 13 + 4
+	out := make([]byte, 0, len(pngData)+len(chunk))
+	out = append(out, pngData[:end]...)
+	out = append(out, chunk...)
+	return append(out, pngData[end:]...)
 }
 
+// SVGGeometry selects how SVG output draws square modules. Module and eye
+// styles are always drawn as one path per colour.
+type SVGGeometry int
+
+const (
+	// SVGPath merges the dark modules into rectangles traced as a single
+	// path, the most compact form.
+	SVGPath SVGGeometry = iota
+	// SVGUse defines one module and places a <use> reference for each dark
+	// module, for editors that keep modules as separate objects.
+	SVGUse
+	// SVGRects writes a <rect> for each dark module.
+	SVGRects
+)
+
+func (g SVGGeometry) String() string {
+	switch g {
+	case SVGUse:
+		return "use"
+	case SVGRects:
+		return "rects"
+	default:
+		return "path"
+	}
+}
+
+// svgModuleID is the id of the module definition SVGUse references.
+const svgModuleID = "qr-m"
+
 // SVG renders a QR code into SVG bytes.
 func SVG(data string, opts Options) ([]byte, error) {
 	bitmap, err := Bitmap(data, opts)
@@ -81,32 +171,115 @@ func SVG(data string, opts Options) ([]byte, error) {
 		opts.Size = DefaultOptions().Size
 	}
 
-	totalModules := len(bitmap)
-	if totalModules == 0 {
+	if len(bitmap) == 0 {
 		return nil, fmt.Errorf("empty QR bitmap")
 	}
+	cols, rows := len(bitmap[0]), len(bitmap)
+	totalModules := max(cols, rows)
 
-	fg := colorToHex(opts.ForegroundColor)
-	bg := colorToHex(opts.BackgroundColor)
+	fg := svgFill(opts.ForegroundColor)
+	if opts.ForegroundGradient != nil {
+		if err := opts.ForegroundGradient.validate(); err != nil {
+			return nil, err
+		}
+		fg = `fill="url(#` + svgGradientID + `)"`
+	}
+
+	// A caption or frame extends the canvas around the symbol.
+	deco, err := newDecoration(opts, float64(cols), float64(rows), 1, false)
+	if err != nil {
+		return nil, err
+	}
+	viewW, viewH := float64(cols), float64(rows)
+	if deco != nil {
+		viewW, viewH = deco.width, deco.height
+	}
+
+	// Physical sizes are written in millimetres so the SVG prints at size;
+	// otherwise the longer side of the symbol is Size user units.
+	perModule, unit := float64(opts.Size)/float64(totalModules), ""
+	if mm := opts.physicalMM(); mm > 0 {
+		perModule, unit = mm/float64(totalModules), "mm"
+	}
+	width, height := svgNum(viewW*perModule)+unit, svgNum(viewH*perModule)+unit
+
+	var layers []shapeLayer
+	if opts.shaped() {
+		if opts.SVGGeometry != SVGPath {
+			return nil, fmt.Errorf("SVG %s geometry needs square modules and eyes", opts.SVGGeometry)
+		}
+		layers = shapeLayers(bitmap, opts)
+	}
+
+	// Curved shapes need anti-aliasing; square modules render crisply.
+	rendering := "crispEdges"
+	if curved(layers) {
+		rendering = "geometricPrecision"
+	}
 
 	var b strings.Builder
 	b.WriteString(fmt.Sprintf(
-		`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
-		opts.Size, opts.Size, totalModules, totalModules,
+		`<svg xmlns="http://www.w3.org/2000/svg" width="%s" height="%s" viewBox="0 0 %s %s" shape-rendering="%s">`,
+		width, height, svgNum(viewW), svgNum(viewH), rendering,
 	))
-	b.WriteString(fmt.Sprintf(`<rect width="100%%" height="100%%" fill="%s"/>`, bg))
-	b.WriteString(fmt.Sprintf(`<g fill="%s">`, fg))
+	// A fully transparent background is left out so the code can sit
+	// over artwork.
+	if colorToRGBA(opts.BackgroundColor).A > 0 {
+		b.WriteString(fmt.Sprintf(`<rect width="100%%" height="100%%" %s/>`, svgFill(opts.BackgroundColor)))
+	}
+	if deco != nil {
+		deco.writeSVG(&b)
+		b.WriteString(fmt.Sprintf(`<g transform="translate(%s %s)">`, svgNum(deco.symbolX), svgNum(deco.symbolY)))
+	}
+	if opts.ForegroundGradient != nil {
+		border := float64(max(opts.BorderSize, 0))
+		b.WriteString("<defs>" + opts.ForegroundGradient.svgDefinition(border, border, float64(cols)-border*2, float64(rows)-border*2) + "</defs>")
+	}
 
-	for y := 0; y < totalModules; y++ {
-		for x := 0; x < totalModules; x++ {
-			if bitmap[y][x] {
-				b.WriteString(fmt.Sprintf(`<rect x="%d" y="%d" width="1" height="1"/>`, x, y))
+	if layers == nil {
+		switch opts.SVGGeometry {
+		case SVGRects:
+			b.WriteString(fmt.Sprintf(`<g %s>`, fg))
+			for y := 0; y < rows; y++ {
+				for x := 0; x < cols; x++ {
+					if bitmap[y][x] {
+						b.WriteString(fmt.Sprintf(`<rect x="%d" y="%d" width="1" height="1"/>`, x, y))
+					}
+				}
+			}
+			b.WriteString(`</g>`)
+		case SVGUse:
+			b.WriteString(fmt.Sprintf(`<defs><rect id="%s" width="1" height="1"/></defs><g %s>`, svgModuleID, fg))
+			for y := 0; y < rows; y++ {
+				for x := 0; x < cols; x++ {
+					if bitmap[y][x] {
+						b.WriteString(fmt.Sprintf(`<use href="#%s" x="%d" y="%d"/>`, svgModuleID, x, y))
+					}
+				}
+			}
+			b.WriteString(`</g>`)
+		default:
+			// Merged rectangles traced as one path.
+			var d strings.Builder
+			for _, rect := range mergeRects(bitmap) {
+				fmt.Fprintf(&d, "M%d %dh%dv%dh-%dz", rect.Min.X, rect.Min.Y, rect.Dx(), rect.Dy(), rect.Dx())
+			}
+			b.WriteString(fmt.Sprintf(`<path %s d="%s"/>`, fg, d.String()))
+		}
+	} else {
+		for _, layer := range layers {
+			var d strings.Builder
+			for _, shape := range layer.shapes {
+				shape.svgPath(&d)
 			}
+			fill := fg
+			if !layer.gradient {
+				fill = svgFill(layer.fill)
+			}
+			b.WriteString(fmt.Sprintf(`<path %s d="%s"/>`, fill, d.String()))
 		}
 	}
 
-	b.WriteString(`</g>`)
-
 	if opts.LogoPath != "" {
 		element, err := svgLogoElement(opts, totalModules)
 		if err != nil {
@@ -115,6 +288,9 @@ func SVG(data string, opts Options) ([]byte, error) {
 		b.WriteString(element)
 	}
 
+	if deco != nil {
+		b.WriteString(`</g>`)
+	}
 	b.WriteString(`</svg>`)
 	return []byte(b.String()), nil
 }
@@ -123,7 +299,23 @@ func colorToRGBA(c color.Color) color.RGBA {
 	return color.RGBAModel.Convert(c).(color.RGBA)
 }
 
+// colorToHex formats the colour without its alpha, which SVG carries in a
+// separate opacity attribute.
 func colorToHex(c color.Color) string {
-	rgba := colorToRGBA(c)
-	return fmt.Sprintf("#%02x%02x%02x", rgba.R, rgba.G, rgba.B)
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	return fmt.Sprintf("#%02x%02x%02x", n.R, n.G, n.B)
+}
+
+// svgOpacity returns the alpha of c from 0 to 1.
+func svgOpacity(c color.Color) string {
+	return svgNum(float64(color.NRGBAModel.Convert(c).(color.NRGBA).A) / 255)
+}
+
+// svgFill returns the fill attribute for c, with a fill-opacity when the
+// colour is translucent.
+func svgFill(c color.Color) string {
+	if colorToRGBA(c).A == 0xff {
+		return fmt.Sprintf(`fill="%s"`, colorToHex(c))
+	}
+	return fmt.Sprintf(`fill="%s" fill-opacity="%s"`, colorToHex(c), svgOpacity(c))
 }
@@ -0,0 +1,296 @@
+package qr
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// rMQR symbols (ISO/IEC 23941) are 7 to 17 modules tall and 27 to 139
+// wide. They carry a finder pattern on the left, a smaller finder
+// sub-pattern in the bottom-right corner, timing patterns around every
+// edge and down each alignment column, and a single fixed data mask.
+// Only levels M and H exist.
+
+// rmqrVersion describes one rMQR version. The per-level arrays hold level
+// M at index 0 and level H at index 1; countBits is ordered numeric,
+// alphanumeric, byte and kanji.
+type rmqrVersion struct {
+	height, width int
+	eccPerBlock   [2]int
+	blocks        [2]int
+	countBits     [4]int
+}
+
+// rmqrVersions lists versions R7x43 to R17x139 in version indicator order.
+var rmqrVersions = [...]rmqrVersion{
+	{7, 43, [2]int{7, 10}, [2]int{1, 1}, [4]int{4, 3, 3, 2}},
+	{7, 59, [2]int{9, 14}, [2]int{1, 1}, [4]int{5, 5, 4, 3}},
+	{7, 77, [2]int{12, 22}, [2]int{1, 1}, [4]int{6, 5, 5, 4}},
+	{7, 99, [2]int{16, 30}, [2]int{1, 1}, [4]int{7, 6, 5, 5}},
+	{7, 139, [2]int{24, 22}, [2]int{1, 2}, [4]int{7, 6, 6, 5}},
+	{9, 43, [2]int{9, 14}, [2]int{1, 1}, [4]int{5, 5, 4, 3}},
+	{9, 59, [2]int{12, 22}, [2]int{1, 1}, [4]int{6, 5, 5, 4}},
+	{9, 77, [2]int{18, 16}, [2]int{1, 2}, [4]int{7, 6, 5, 5}},
+	{9, 99, [2]int{24, 22}, [2]int{1, 2}, [4]int{7, 6, 6, 5}},
+	{9, 139, [2]int{18, 22}, [2]int{2, 3}, [4]int{8, 7, 6, 6}},
+	{11, 27, [2]int{8, 10}, [2]int{1, 1}, [4]int{4, 4, 3, 2}},
+	{11, 43, [2]int{12, 20}, [2]int{1, 1}, [4]int{6, 5, 5, 4}},
+	{11, 59, [2]int{16, 16}, [2]int{1, 2}, [4]int{7, 6, 5, 5}},
+	{11, 77, [2]int{24, 22}, [2]int{1, 2}, [4]int{7, 6, 6, 5}},
+	{11, 99, [2]int{16, 30}, [2]int{2, 2}, [4]int{8, 7, 6, 6}},
+	{11, 139, [2]int{24, 30}, [2]int{2, 3}, [4]int{8, 7, 7, 6}},
+	{13, 27, [2]int{9, 14}, [2]int{1, 1}, [4]int{5, 5, 4, 3}},
+	{13, 43, [2]int{14, 28}, [2]int{1, 1}, [4]int{6, 6, 5, 5}},
+	{13, 59, [2]int{22, 20}, [2]int{1, 2}, [4]int{7, 6, 6, 5}},
+	{13, 77, [2]int{16, 28}, [2]int{2, 2}, [4]int{7, 7, 6, 6}},
+	{13, 99, [2]int{20, 26}, [2]int{2, 3}, [4]int{8, 7, 7, 6}},
+	{13, 139, [2]int{20, 28}, [2]int{3, 4}, [4]int{8, 8, 7, 7}},
+	{15, 43, [2]int{18, 18}, [2]int{1, 2}, [4]int{7, 6, 6, 5}},
+	{15, 59, [2]int{26, 24}, [2]int{1, 2}, [4]int{7, 7, 6, 5}},
+	{15, 77, [2]int{18, 24}, [2]int{2, 3}, [4]int{8, 7, 7, 6}},
+	{15, 99, [2]int{24, 24}, [2]int{2, 4}, [4]int{8, 7, 7, 6}},
+	{15, 139, [2]int{24, 26}, [2]int{3, 5}, [4]int{9, 8, 7, 7}},
+	{17, 43, [2]int{22, 20}, [2]int{1, 2}, [4]int{7, 6, 6, 5}},
+	{17, 59, [2]int{16, 30}, [2]int{2, 2}, [4]int{8, 7, 6, 6}},
+	{17, 77, [2]int{22, 28}, [2]int{2, 3}, [4]int{8, 7, 7, 6}},
+	{17, 99, [2]int{20, 26}, [2]int{3, 4}, [4]int{8, 8, 7, 6}},
+	{17, 139, [2]int{20, 26}, [2]int{4, 6}, [4]int{9, 8, 8, 7}},
+}
+
+// rmqrBySize holds version numbers ordered by symbol area, the order in
+// which automatic version selection tries them.
+var rmqrBySize = func() []int {
+	order := make([]int, len(rmqrVersions))
+	for i := range order {
+		order[i] = i + 1
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := rmqrVersions[order[i]-1], rmqrVersions[order[j]-1]
+		return a.width*a.height < b.width*b.height
+	})
+	return order
+}()
+
+// rmqrAlignmentColumns returns the centre columns of the alignment
+// patterns for a symbol width.
+func rmqrAlignmentColumns(width int) []int {
+	switch width {
+	case 43:
+		return []int{21}
+	case 59:
+		return []int{19, 39}
+	case 77:
+		return []int{25, 51}
+	case 99:
+		return []int{23, 49, 75}
+	case 139:
+		return []int{27, 55, 83, 111}
+	default:
+		return nil
+	}
+}
+
+// rmqrLevel maps a recovery level to the rMQR level index.
+func rmqrLevel(level RecoveryLevel) (int, error) {
+	switch level {
+	case Medium:
+		return 0, nil
+	case Highest:
+		return 1, nil
+	default:
+		return 0, fmt.Errorf("error correction level %s is not available for rMQR (want M or H)", level)
+	}
+}
+
+// rmqrRawModules counts the modules left for codewords in a version.
+func rmqrRawModules(version int) int {
+	v := rmqrVersions[version-1]
+	m := newRectMatrix(v.width, v.height)
+	m.drawRMQRFunctionPatterns(version, 0)
+
+	count := 0
+	for _, row := range m.function {
+		for _, f := range row {
+			if !f {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+func rmqrDataCodewords(version, level int) int {
+	v := rmqrVersions[version-1]
+	return rmqrRawModules(version)/8 - v.eccPerBlock[level]*v.blocks[level]
+}
+
+// rmqrFraming frames segments for an rMQR version.
+type rmqrFraming int
+
+func (rmqrFraming) modeIndicator(m Mode) (uint32, int) {
+	switch m {
+	case ModeNumeric:
+		return 1, 3
+	case ModeAlphanumeric:
+		return 2, 3
+	case ModeKanji:
+		return 4, 3
+	default:
+		return 3, 3
+	}
+}
+
+func (f rmqrFraming) charCountBits(m Mode) int {
+	counts := rmqrVersions[f-1].countBits
+	switch m {
+	case ModeNumeric:
+		return counts[0]
+	case ModeAlphanumeric:
+		return counts[1]
+	case ModeKanji:
+		return counts[3]
+	default:
+		return counts[2]
+	}
+}
+
+func (rmqrFraming) terminatorBits() int {
+	return 3
+}
+
+// planRMQR is planSymbol for rMQR. Without a fixed version it picks the
+// smallest symbol by area.
+func planRMQR(data string, opts Options) (int, []Segment, error) {
+	level, err := rmqrLevel(opts.Level)
+	if err != nil {
+		return 0, nil, err
+	}
+	if opts.Append.Total > 0 {
+		return 0, nil, errors.New("structured append is not available for rMQR")
+	}
+
+	header := len(symbolHeader(opts))
+	candidates := rmqrBySize
+	if opts.Version != 0 {
+		if opts.Version < 1 || opts.Version > len(rmqrVersions) {
+			return 0, nil, fmt.Errorf("invalid rMQR version %d (want 1-%d)", opts.Version, len(rmqrVersions))
+		}
+		candidates = []int{opts.Version}
+	}
+
+	for _, v := range candidates {
+		segs, err := makeSegments(data, opts.Mode, rmqrFraming(v), opts.GS1)
+		if err != nil {
+			return 0, nil, err
+		}
+		if used := segmentsBitLength(segs, rmqrFraming(v)); used >= 0 && header+used <= rmqrDataCodewords(v, level)*8 {
+			return v, segs, nil
+		}
+	}
+
+	if opts.Version != 0 {
+		return 0, nil, fmt.Errorf("%w for rMQR version %s at level %s", ErrDataTooLong, versionName(SymbolRMQR, opts.Version), opts.Level)
+	}
+	return 0, nil, fmt.Errorf("%w for an rMQR code at level %s", ErrDataTooLong, opts.Level)
+}
+
+func encodeRMQR(data string, opts Options) (*Code, error) {
+	if opts.Mask != MaskAuto && opts.Mask != 0 {
+		return nil, fmt.Errorf("invalid rMQR mask pattern %d (rMQR has a single fixed mask)", opts.Mask)
+	}
+
+	version, segs, err := planSymbol(data, opts)
+	if err != nil {
+		return nil, err
+	}
+	level, _ := rmqrLevel(opts.Level)
+
+	v := rmqrVersions[version-1]
+	header := symbolHeader(opts)
+	capacity := rmqrDataCodewords(version, level) * 8
+	packed := packSegments(header, segs, rmqrFraming(version), capacity)
+	codewords := interleaveBlocks(packed, v.blocks[level], v.eccPerBlock[level], rmqrRawModules(version)/8)
+
+	m := newRectMatrix(v.width, v.height)
+	m.firstColumn = v.width - 2
+	m.drawRMQRFunctionPatterns(version, level)
+	m.drawCodewords(codewords)
+	m.applyMask(4)
+
+	return &Code{
+		Symbology: SymbolRMQR,
+		Version:   version,
+		Level:     opts.Level,
+		Segments:  segs,
+		ECI:       opts.ECI,
+		GS1:       opts.GS1,
+		modules:   m.modules,
+
+		usedBits:     len(header) + segmentsBitLength(segs, rmqrFraming(version)),
+		capacityBits: capacity,
+	}, nil
+}
+
+func (m *matrix) drawRMQRFunctionPatterns(version, level int) {
+	w, h := m.width, m.height
+	columns := rmqrAlignmentColumns(w)
+
+	for x := 0; x < w; x++ {
+		m.setFunction(x, 0, x%2 == 0)
+		m.setFunction(x, h-1, x%2 == 0)
+	}
+	for _, x := range append([]int{0, w - 1}, columns...) {
+		for y := 0; y < h; y++ {
+			m.setFunction(x, y, y%2 == 0)
+		}
+	}
+
+	for _, cx := range columns {
+		for dy := -1; dy <= 1; dy++ {
+			for dx := -1; dx <= 1; dx++ {
+				dark := dx != 0 || dy != 0
+				m.setFunction(cx+dx, 1+dy, dark)
+				m.setFunction(cx+dx, h-2+dy, dark)
+			}
+		}
+	}
+
+	m.drawFinder(3, 3)
+	// The finder sub-pattern has the shape of a QR alignment pattern.
+	m.drawAlignment(w-3, h-3)
+
+	// Corner finder patterns mark the two remaining corners.
+	m.setFunction(w-1, 0, true)
+	m.setFunction(w-2, 0, true)
+	m.setFunction(w-1, 1, true)
+	m.setFunction(w-2, 1, false)
+	for x := 0; x < 3; x++ {
+		m.setFunction(x, h-1, true)
+	}
+	if h >= 11 {
+		m.setFunction(0, h-2, true)
+		m.setFunction(1, h-2, false)
+	}
+
+	m.drawRMQRVersion(version, level)
+}
+
+// drawRMQRVersion writes the level and version indicator next to the
+// finder pattern and again next to the finder sub-pattern, each copy
+// under its own mask.
+func (m *matrix) drawRMQRVersion(version, level int) {
+	bits := versionCode(level<<5 | (version - 1))
+	finderSide := bits ^ 0x1fab2
+	subSide := bits ^ 0x20a7b
+
+	for i := 0; i < 18; i++ {
+		m.setFunction(8+i/5, 1+i%5, bit(finderSide, i))
+
+		x, y := m.width-8+i/5, m.height-6+i%5
+		if i >= 15 {
+			x, y = m.width-5+i-15, m.height-6
+		}
+		m.setFunction(x, y, bit(subSide, i))
+	}
+}
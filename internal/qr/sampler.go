@@ -0,0 +1,283 @@
+package qr
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// finderPoint is the centre of a located finder pattern in pixels, with
+// the estimated module size and how many scan lines confirmed it.
+type finderPoint struct {
+	x, y   float64
+	module float64
+	hits   int
+}
+
+// sampledGrid is the module grid read from one upright symbol along with
+// its extent in pixels.
+type sampledGrid struct {
+	modules [][]bool
+	bounds  image.Rectangle
+}
+
+// sampleGrids reads the module grids of upright symbols, such as the ones
+// this package renders. Data regions can mimic a finder now and then, so
+// every plausible triple of finders yields a candidate and the caller
+// weeds out the ones that fail to decode. Rotated or skewed symbols yield
+// nothing, leaving them to a general detector.
+func sampleGrids(img image.Image) []sampledGrid {
+	dark := binarize(img)
+	if len(dark) == 0 {
+		return nil
+	}
+
+	finders := locateFinders(dark)
+	var grids []sampledGrid
+	for i := 0; i < len(finders); i++ {
+		for j := i + 1; j < len(finders); j++ {
+			for k := j + 1; k < len(finders); k++ {
+				tl, tr, bl, ok := orderFinders([]finderPoint{finders[i], finders[j], finders[k]})
+				if !ok {
+					continue
+				}
+				if grid, ok := sampleGrid(dark, tl, tr, bl); ok {
+					grids = append(grids, grid)
+				}
+			}
+		}
+	}
+	return grids
+}
+
+func sampleGrid(dark [][]bool, tl, tr, bl finderPoint) (sampledGrid, bool) {
+	module := (tl.module + tr.module + bl.module) / 3
+	version := int(math.Round(((tr.x-tl.x)/module + 7 - 17) / 4))
+	if version < minVersion || version > maxVersion {
+		return sampledGrid{}, false
+	}
+	size := symbolSize(version)
+
+	pitchX := (tr.x - tl.x) / float64(size-7)
+	pitchY := (bl.y - tl.y) / float64(size-7)
+	if math.Abs(pitchX-pitchY) > module/4 {
+		return sampledGrid{}, false
+	}
+
+	modules := make([][]bool, size)
+	for row := 0; row < size; row++ {
+		modules[row] = make([]bool, size)
+		for col := 0; col < size; col++ {
+			px := int(tl.x + float64(col-3)*pitchX)
+			py := int(tl.y + float64(row-3)*pitchY)
+			if py < 0 || py >= len(dark) || px < 0 || px >= len(dark[py]) {
+				return sampledGrid{}, false
+			}
+			modules[row][col] = dark[py][px]
+		}
+	}
+
+	bounds := image.Rect(
+		int(tl.x-3.5*pitchX), int(tl.y-3.5*pitchY),
+		int(tr.x+3.5*pitchX), int(bl.y+3.5*pitchY),
+	)
+	return sampledGrid{modules: modules, bounds: bounds}, true
+}
+
+// binarize thresholds the image at the midpoint between its darkest and
+// lightest luminance, compositing transparent pixels over white.
+func binarize(img image.Image) [][]bool {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w == 0 || h == 0 {
+		return nil
+	}
+
+	lum := make([][]float64, h)
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for y := 0; y < h; y++ {
+		lum[y] = make([]float64, w)
+		for x := 0; x < w; x++ {
+			v := luminance(img.At(bounds.Min.X+x, bounds.Min.Y+y))
+			lum[y][x] = v
+			lo = math.Min(lo, v)
+			hi = math.Max(hi, v)
+		}
+	}
+
+	threshold := (lo + hi) / 2
+	dark := make([][]bool, h)
+	for y := range lum {
+		dark[y] = make([]bool, w)
+		for x, v := range lum[y] {
+			dark[y][x] = v < threshold
+		}
+	}
+	return dark
+}
+
+// luminance returns the brightness of c from 0 to 255, composited over
+// white.
+func luminance(c color.Color) float64 {
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	alpha := float64(n.A) / 255
+	return (0.299*float64(n.R)+0.587*float64(n.G)+0.114*float64(n.B))*alpha + 255*(1-alpha)
+}
+
+// MinContrast is the lowest symbol contrast that still earns a passing
+// grade (D) under ISO/IEC 15415.
+const MinContrast = 0.2
+
+// Contrast returns the symbol contrast between dark and light modules of
+// the given colours, from 0 to 1. It is negative when the "dark" colour is
+// the lighter of the two. A translucent fg is composited over bg, and a
+// translucent bg over white.
+func Contrast(fg, bg color.Color) float64 {
+	n := color.NRGBAModel.Convert(fg).(color.NRGBA)
+	alpha := float64(n.A) / 255
+	dark := luminance(color.NRGBA{R: n.R, G: n.G, B: n.B, A: 0xff})*alpha + luminance(bg)*(1-alpha)
+	return (luminance(bg) - dark) / 255
+}
+
+// locateFinders scans every row for the 1:1:3:1:1 finder signature,
+// confirms each hit vertically and clusters hits into pattern centres.
+func locateFinders(dark [][]bool) []finderPoint {
+	var clusters []finderPoint
+	for y, row := range dark {
+		x := 0
+		for x < len(row) {
+			if !row[x] {
+				x++
+				continue
+			}
+			if cx, unit, ok := crossCheck(len(row), func(i int) bool { return row[i] }, x); ok {
+				col := int(cx)
+				cy, vunit, ok := crossCheck(len(dark), func(i int) bool { return dark[i][col] }, y)
+				if ok && math.Abs(unit-vunit) <= math.Max(unit, vunit)*0.3 {
+					clusters = addFinderHit(clusters, cx, cy, (unit+vunit)/2)
+				}
+			}
+			for x < len(row) && row[x] {
+				x++
+			}
+		}
+	}
+
+	var found []finderPoint
+	for _, c := range clusters {
+		if c.hits >= 2 {
+			found = append(found, c)
+		}
+	}
+	return found
+}
+
+// crossCheck looks for a finder signature whose centre stone contains
+// position pos along a line, returning the stone centre and module size.
+func crossCheck(n int, at func(int) bool, pos int) (float64, float64, bool) {
+	if !at(pos) {
+		return 0, 0, false
+	}
+
+	start, end := pos, pos
+	for start > 0 && at(start-1) {
+		start--
+	}
+	for end < n-1 && at(end+1) {
+		end++
+	}
+
+	var runs [5]int
+	runs[2] = end - start + 1
+	left, right := start, end
+	for i, want := range []bool{false, true} {
+		count := 0
+		for left > 0 && at(left-1) == want {
+			left--
+			count++
+		}
+		runs[1-i] = count
+		count = 0
+		for right < n-1 && at(right+1) == want {
+			right++
+			count++
+		}
+		runs[3+i] = count
+	}
+
+	for _, r := range runs {
+		if r == 0 {
+			return 0, 0, false
+		}
+	}
+
+	// The outer rings set the module size; each run must then be within
+	// half a module (plus a pixel of anti-aliasing) of its expected width.
+	unit := float64(runs[0]+runs[1]+runs[3]+runs[4]) / 4
+	tolerance := unit/2 + 0.5
+	for i, r := range runs {
+		want := unit
+		if i == 2 {
+			want = unit * 3
+		}
+		if math.Abs(float64(r)-want) >= tolerance {
+			return 0, 0, false
+		}
+	}
+
+	return float64(start+end+1) / 2, unit, true
+}
+
+func addFinderHit(clusters []finderPoint, x, y, module float64) []finderPoint {
+	for i := range clusters {
+		c := &clusters[i]
+		if math.Abs(c.x-x) <= c.module*2 && math.Abs(c.y-y) <= c.module*2 {
+			n := float64(c.hits)
+			c.x = (c.x*n + x) / (n + 1)
+			c.y = (c.y*n + y) / (n + 1)
+			c.module = (c.module*n + module) / (n + 1)
+			c.hits++
+			return clusters
+		}
+	}
+	return append(clusters, finderPoint{x: x, y: y, module: module, hits: 1})
+}
+
+// orderFinders identifies the top-left, top-right and bottom-left
+// patterns of an upright symbol.
+func orderFinders(points []finderPoint) (tl, tr, bl finderPoint, ok bool) {
+	best := 0
+	for i, p := range points {
+		if p.x+p.y < points[best].x+points[best].y {
+			best = i
+		}
+	}
+	tl = points[best]
+
+	var rest []finderPoint
+	for i, p := range points {
+		if i != best {
+			rest = append(rest, p)
+		}
+	}
+	tr, bl = rest[0], rest[1]
+	if tr.x-tr.y < bl.x-bl.y {
+		tr, bl = bl, tr
+	}
+
+	lo := math.Min(tl.module, math.Min(tr.module, bl.module))
+	hi := math.Max(tl.module, math.Max(tr.module, bl.module))
+	if hi > lo*1.25 {
+		return tl, tr, bl, false
+	}
+
+	slack := tl.module * 2
+	if math.Abs(tr.y-tl.y) > slack || math.Abs(bl.x-tl.x) > slack || tr.x <= tl.x || bl.y <= tl.y {
+		return tl, tr, bl, false
+	}
+	width, height := tr.x-tl.x, bl.y-tl.y
+	if math.Abs(width-height) > slack {
+		return tl, tr, bl, false
+	}
+	return tl, tr, bl, true
+}
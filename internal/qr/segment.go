@@ -0,0 +1,367 @@
+package qr
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/japanese"
+)
+
+// Mode selects how payload characters are packed into the symbol.
+type Mode int
+
+const (
+	// ModeAuto splits the payload into the cheapest mix of segments.
+	ModeAuto Mode = iota
+	ModeNumeric
+	ModeAlphanumeric
+	ModeByte
+	ModeKanji
+)
+
+const alphanumericCharset = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ $%*+-./:"
+
+func (m Mode) String() string {
+	switch m {
+	case ModeNumeric:
+		return "numeric"
+	case ModeAlphanumeric:
+		return "alphanumeric"
+	case ModeByte:
+		return "byte"
+	case ModeKanji:
+		return "kanji"
+	default:
+		return "auto"
+	}
+}
+
+// framing describes how segments are laid out in one symbol version: the
+// mode indicator, the character count field and the terminator. A count
+// width of zero means the mode is not available in that version.
+type framing interface {
+	modeIndicator(m Mode) (value uint32, bits int)
+	charCountBits(m Mode) int
+	terminatorBits() int
+}
+
+// qrFraming frames segments for a QR version.
+type qrFraming int
+
+func (qrFraming) modeIndicator(m Mode) (uint32, int) {
+	switch m {
+	case ModeNumeric:
+		return 0x1, 4
+	case ModeAlphanumeric:
+		return 0x2, 4
+	case ModeKanji:
+		return 0x8, 4
+	default:
+		return 0x4, 4
+	}
+}
+
+func (f qrFraming) charCountBits(m Mode) int {
+	group := 0
+	switch {
+	case f >= 27:
+		group = 2
+	case f >= 10:
+		group = 1
+	}
+
+	switch m {
+	case ModeNumeric:
+		return [3]int{10, 12, 14}[group]
+	case ModeAlphanumeric:
+		return [3]int{9, 11, 13}[group]
+	case ModeKanji:
+		return [3]int{8, 10, 12}[group]
+	default:
+		return [3]int{8, 16, 16}[group]
+	}
+}
+
+func (qrFraming) terminatorBits() int {
+	return 4
+}
+
+// Segment is a run of payload characters packed in a single mode.
+type Segment struct {
+	Mode  Mode
+	Count int
+	bits  bitBuffer
+}
+
+// bitLength returns the number of bits the segment occupies under f, or -1
+// if the mode is unavailable or its character count overflows the field.
+func (s Segment) bitLength(f framing) int {
+	ccBits := f.charCountBits(s.Mode)
+	if ccBits == 0 || s.Count >= 1<<uint(ccBits) {
+		return -1
+	}
+	_, indicatorBits := f.modeIndicator(s.Mode)
+	return indicatorBits + ccBits + len(s.bits)
+}
+
+type bitBuffer []bool
+
+func (b *bitBuffer) appendBits(value uint32, n int) {
+	for i := n - 1; i >= 0; i-- {
+		*b = append(*b, (value>>uint(i))&1 != 0)
+	}
+}
+
+func numericSegment(s string) Segment {
+	var bits bitBuffer
+	for i := 0; i < len(s); i += 3 {
+		end := i + 3
+		if end > len(s) {
+			end = len(s)
+		}
+		var value uint32
+		for _, c := range s[i:end] {
+			value = value*10 + uint32(c-'0')
+		}
+		bits.appendBits(value, (end-i)*3+1)
+	}
+	return Segment{Mode: ModeNumeric, Count: len(s), bits: bits}
+}
+
+// alphanumericSegment packs s in alphanumeric mode. A GS1 group separator
+// is written as '%', which GS1 symbols reserve for FNC1.
+func alphanumericSegment(s string) Segment {
+	s = strings.ReplaceAll(s, string(rune(gs1Separator)), "%")
+	var bits bitBuffer
+	for i := 0; i+1 < len(s); i += 2 {
+		value := strings.IndexByte(alphanumericCharset, s[i])*45 + strings.IndexByte(alphanumericCharset, s[i+1])
+		bits.appendBits(uint32(value), 11)
+	}
+	if len(s)%2 == 1 {
+		bits.appendBits(uint32(strings.IndexByte(alphanumericCharset, s[len(s)-1])), 6)
+	}
+	return Segment{Mode: ModeAlphanumeric, Count: len(s), bits: bits}
+}
+
+func byteSegment(data []byte) Segment {
+	var bits bitBuffer
+	for _, b := range data {
+		bits.appendBits(uint32(b), 8)
+	}
+	return Segment{Mode: ModeByte, Count: len(data), bits: bits}
+}
+
+func kanjiSegment(s string) (Segment, error) {
+	var bits bitBuffer
+	count := 0
+	for _, r := range s {
+		value, ok := kanjiValue(r)
+		if !ok {
+			return Segment{}, fmt.Errorf("character %q cannot be encoded in kanji mode", r)
+		}
+		bits.appendBits(uint32(value), 13)
+		count++
+	}
+	return Segment{Mode: ModeKanji, Count: count, bits: bits}, nil
+}
+
+func isNumeric(r rune) bool {
+	return r >= '0' && r <= '9'
+}
+
+func isAlphanumeric(r rune) bool {
+	return r < utf8.RuneSelf && strings.IndexByte(alphanumericCharset, byte(r)) >= 0
+}
+
+// isAlphanumericIn reports whether r fits an alphanumeric segment. In a GS1
+// symbol '%' stands for FNC1, so a literal '%' goes to byte mode and the
+// group separator takes its place.
+func isAlphanumericIn(r rune, gs1 bool) bool {
+	if gs1 {
+		if r == '%' {
+			return false
+		}
+		if r == gs1Separator {
+			return true
+		}
+	}
+	return isAlphanumeric(r)
+}
+
+// kanjiValue maps a rune to its 13-bit kanji mode value via Shift JIS.
+func kanjiValue(r rune) (int, bool) {
+	encoded, err := japanese.ShiftJIS.NewEncoder().String(string(r))
+	if err != nil || len(encoded) != 2 {
+		return 0, false
+	}
+
+	code := int(encoded[0])<<8 | int(encoded[1])
+	switch {
+	case code >= 0x8140 && code <= 0x9ffc:
+		code -= 0x8140
+	case code >= 0xe040 && code <= 0xebbf:
+		code -= 0xc140
+	default:
+		return 0, false
+	}
+	return (code>>8)*0xc0 + code&0xff, true
+}
+
+// makeSegments packs data for a symbol version framed by f. A forced mode
+// yields a single segment; ModeAuto picks the cheapest mix of numeric,
+// alphanumeric and byte segments. gs1 marks data for an FNC1 symbol.
+func makeSegments(data string, mode Mode, f framing, gs1 bool) ([]Segment, error) {
+	switch mode {
+	case ModeAuto:
+		return optimalSegments(data, f, gs1), nil
+	case ModeNumeric:
+		for _, r := range data {
+			if !isNumeric(r) {
+				return nil, fmt.Errorf("character %q cannot be encoded in numeric mode", r)
+			}
+		}
+		return []Segment{numericSegment(data)}, nil
+	case ModeAlphanumeric:
+		for _, r := range data {
+			if !isAlphanumericIn(r, gs1) {
+				return nil, fmt.Errorf("character %q cannot be encoded in alphanumeric mode", r)
+			}
+		}
+		return []Segment{alphanumericSegment(data)}, nil
+	case ModeByte:
+		return []Segment{byteSegment([]byte(data))}, nil
+	case ModeKanji:
+		seg, err := kanjiSegment(data)
+		if err != nil {
+			return nil, err
+		}
+		return []Segment{seg}, nil
+	default:
+		return nil, fmt.Errorf("unknown encoding mode: %d", mode)
+	}
+}
+
+// optimalSegments finds the mode for every character that minimises the
+// total bit length, then groups consecutive characters into segments.
+// Kanji mode is left out: scanners return kanji segments as Shift JIS,
+// which would mix encodings inside an otherwise UTF-8 payload. Modes the
+// framing lacks are skipped; if no available mode can hold a character the
+// payload falls back to a single byte segment, which then fails to fit.
+func optimalSegments(data string, f framing, gs1 bool) []Segment {
+	if data == "" {
+		return nil
+	}
+
+	// Walk the payload by byte offset rather than converting to runes so
+	// that invalid UTF-8 (e.g. transcoded text) passes through untouched.
+	var offsets []int
+	var runes []rune
+	for i := 0; i < len(data); {
+		r, width := utf8.DecodeRuneInString(data[i:])
+		offsets = append(offsets, i)
+		runes = append(runes, r)
+		i += width
+	}
+	offsets = append(offsets, len(data))
+
+	modes := [3]Mode{ModeByte, ModeAlphanumeric, ModeNumeric}
+
+	// Costs are tracked in sixths of a bit so that the fractional
+	// per-character sizes of numeric and alphanumeric stay exact.
+	var headCosts [3]int
+	var allowed [3]bool
+	for i, m := range modes {
+		_, indicatorBits := f.modeIndicator(m)
+		headCosts[i] = (indicatorBits + f.charCountBits(m)) * 6
+		allowed[i] = f.charCountBits(m) > 0
+	}
+
+	charModes := make([][3]Mode, len(runes))
+	prevCosts := headCosts
+	for i, r := range runes {
+		var curCosts [3]int
+		var cur [3]Mode
+
+		if allowed[0] {
+			curCosts[0] = prevCosts[0] + (offsets[i+1]-offsets[i])*8*6
+			cur[0] = ModeByte
+		}
+		if allowed[1] && isAlphanumericIn(r, gs1) {
+			curCosts[1] = prevCosts[1] + 33
+			cur[1] = ModeAlphanumeric
+		}
+		if allowed[2] && isNumeric(r) {
+			curCosts[2] = prevCosts[2] + 20
+			cur[2] = ModeNumeric
+		}
+		if cur == [3]Mode{} {
+			return []Segment{byteSegment([]byte(data))}
+		}
+
+		for j := range modes {
+			for k := range modes {
+				newCost := (curCosts[k]+5)/6*6 + headCosts[j]
+				if allowed[j] && cur[k] != ModeAuto && (cur[j] == ModeAuto || newCost < curCosts[j]) {
+					curCosts[j] = newCost
+					cur[j] = modes[k]
+				}
+			}
+		}
+
+		charModes[i] = cur
+		prevCosts = curCosts
+	}
+
+	best := -1
+	for j := range modes {
+		if allowed[j] && (best < 0 || prevCosts[j] < prevCosts[best]) {
+			best = j
+		}
+	}
+
+	chosen := make([]Mode, len(runes))
+	current := modes[best]
+	for i := len(runes) - 1; i >= 0; i-- {
+		for j, m := range modes {
+			if m == current {
+				current = charModes[i][j]
+				chosen[i] = current
+				break
+			}
+		}
+	}
+
+	var segs []Segment
+	start := 0
+	for i := 1; i <= len(runes); i++ {
+		if i < len(runes) && chosen[i] == chosen[start] {
+			continue
+		}
+		part := data[offsets[start]:offsets[i]]
+		switch chosen[start] {
+		case ModeNumeric:
+			segs = append(segs, numericSegment(part))
+		case ModeAlphanumeric:
+			segs = append(segs, alphanumericSegment(part))
+		default:
+			segs = append(segs, byteSegment([]byte(part)))
+		}
+		start = i
+	}
+	return segs
+}
+
+// segmentsBitLength sums the bit lengths of segs under f, returning -1 if
+// any segment cannot be framed.
+func segmentsBitLength(segs []Segment, f framing) int {
+	total := 0
+	for _, seg := range segs {
+		n := seg.bitLength(f)
+		if n < 0 {
+			return -1
+		}
+		total += n
+	}
+	return total
+}
@@ -0,0 +1,185 @@
+package qr
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"math"
+)
+
+// SheetLayout is a grid of labels on a page, such as Avery label stock.
+// All lengths are in millimetres.
+type SheetLayout struct {
+	PageWidth, PageHeight float64
+	// MarginTop and MarginLeft place the top-left corner of the first
+	// label.
+	MarginTop, MarginLeft float64
+	Cols, Rows            int
+	LabelWidth            float64
+	LabelHeight           float64
+	// PitchX and PitchY are the distances between the corners of
+	// neighbouring labels, which exceed the label size by any gap.
+	PitchX, PitchY float64
+	// Padding is kept clear inside each label edge.
+	Padding float64
+}
+
+// PerPage returns the number of labels on a page.
+func (l SheetLayout) PerPage() int {
+	return l.Cols * l.Rows
+}
+
+// Validate checks that the labels fit on the page without overlapping.
+func (l SheetLayout) Validate() error {
+	switch {
+	case l.PageWidth <= 0 || l.PageHeight <= 0:
+		return errors.New("sheet page size must be positive")
+	case l.Cols <= 0 || l.Rows <= 0:
+		return errors.New("sheet needs at least one column and row")
+	case l.LabelWidth <= 0 || l.LabelHeight <= 0:
+		return errors.New("sheet label size must be positive")
+	case l.LabelWidth-2*l.Padding <= 0 || l.LabelHeight-2*l.Padding <= 0:
+		return errors.New("sheet label padding leaves no room for a code")
+	case l.PitchX < l.LabelWidth && l.Cols > 1, l.PitchY < l.LabelHeight && l.Rows > 1:
+		return errors.New("sheet pitch is smaller than the label, so labels overlap")
+	}
+	right := l.MarginLeft + float64(l.Cols-1)*l.PitchX + l.LabelWidth
+	bottom := l.MarginTop + float64(l.Rows-1)*l.PitchY + l.LabelHeight
+	if l.MarginLeft < 0 || l.MarginTop < 0 || right > l.PageWidth+0.01 || bottom > l.PageHeight+0.01 {
+		return fmt.Errorf("sheet grid spans %.1f x %.1f mm, outside the %.1f x %.1f mm page", right, bottom, l.PageWidth, l.PageHeight)
+	}
+	return nil
+}
+
+// label returns the top-left corner and size of the usable area of label
+// i on its page, in millimetres.
+func (l SheetLayout) label(i int) (x, y, w, h float64) {
+	i %= l.PerPage()
+	col, row := i%l.Cols, i/l.Cols
+	return l.MarginLeft + float64(col)*l.PitchX + l.Padding, l.MarginTop + float64(row)*l.PitchY + l.Padding,
+		l.LabelWidth - 2*l.Padding, l.LabelHeight - 2*l.Padding
+}
+
+// SheetLabel is one code on a label sheet, rendered with its own options
+// so each label can carry its own caption or Structured Append header.
+type SheetLabel struct {
+	Data    string
+	Options Options
+}
+
+// labelModule returns the bitmap and decoration of a label and the module
+// size in millimetres that fits them, centred, into a w by h label.
+func labelModule(label SheetLabel, w, h float64) ([][]bool, *decoration, float64, error) {
+	bitmap, err := Bitmap(label.Data, label.Options)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	if len(bitmap) == 0 {
+		return nil, nil, 0, fmt.Errorf("empty QR bitmap")
+	}
+	deco, err := newDecoration(label.Options, float64(len(bitmap[0])), float64(len(bitmap)), 1, false)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	unitsW, unitsH := codeUnits(bitmap, deco)
+	return bitmap, deco, math.Min(w/unitsW, h/unitsH), nil
+}
+
+// SheetPDF lays labels out in order onto as many pages of layout as they
+// need, as one vector PDF. Each code is scaled to fill its label.
+func SheetPDF(labels []SheetLabel, layout SheetLayout) ([]byte, error) {
+	if err := layout.Validate(); err != nil {
+		return nil, err
+	}
+
+	doc := newPDFDocument()
+	pageW, pageH := layout.PageWidth*pointsPerMM, layout.PageHeight*pointsPerMM
+	var page *pdfPage
+	for i, label := range labels {
+		if i%layout.PerPage() == 0 {
+			if page != nil {
+				doc.addPage(page)
+			}
+			page = doc.newPage(pageW, pageH)
+		}
+		x, y, w, h := layout.label(i)
+		bitmap, deco, module, err := labelModule(label, w, h)
+		if err != nil {
+			return nil, fmt.Errorf("label %d: %w", i+1, err)
+		}
+		unitsW, unitsH := codeUnits(bitmap, deco)
+		x += (w - unitsW*module) / 2
+		y += (h - unitsH*module) / 2
+		if err := page.drawCode(bitmap, deco, label.Options, x*pointsPerMM, y*pointsPerMM, module*pointsPerMM); err != nil {
+			return nil, fmt.Errorf("label %d: %w", i+1, err)
+		}
+	}
+	if page != nil {
+		doc.addPage(page)
+	}
+	return doc.bytes(), nil
+}
+
+// SheetPNG lays labels out in order onto pages of layout at dpi, returning
+// one PNG per page. Modules are a whole number of pixels, the largest
+// that fits each label.
+func SheetPNG(labels []SheetLabel, layout SheetLayout, dpi int) ([][]byte, error) {
+	if err := layout.Validate(); err != nil {
+		return nil, err
+	}
+	if dpi <= 0 {
+		return nil, errors.New("sheet resolution must be positive")
+	}
+	px := func(mm float64) int {
+		return int(math.Round(mm / 25.4 * float64(dpi)))
+	}
+
+	var pages [][]byte
+	var page *image.RGBA
+	flush := func() error {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, page); err != nil {
+			return err
+		}
+		pages = append(pages, withPHYs(buf.Bytes(), dpi))
+		return nil
+	}
+	for i, label := range labels {
+		if i%layout.PerPage() == 0 {
+			if page != nil {
+				if err := flush(); err != nil {
+					return nil, err
+				}
+			}
+			page = image.NewRGBA(image.Rect(0, 0, px(layout.PageWidth), px(layout.PageHeight)))
+			draw.Draw(page, page.Bounds(), image.White, image.Point{}, draw.Src)
+		}
+
+		x, y, w, h := layout.label(i)
+		bitmap, _, module, err := labelModule(label, w, h)
+		if err != nil {
+			return nil, fmt.Errorf("label %d: %w", i+1, err)
+		}
+		total := max(len(bitmap[0]), len(bitmap))
+		opts := label.Options
+		opts.Size = max(int(module/25.4*float64(dpi)), 1) * total
+		opts.Fit = FitRoundDown
+		opts.PhysicalSize, opts.DPI = 0, 0
+		img, err := renderImage(label.Data, opts)
+		if err != nil {
+			return nil, fmt.Errorf("label %d: %w", i+1, err)
+		}
+		bounds := img.Bounds()
+		at := image.Pt(px(x)+(px(w)-bounds.Dx())/2, px(y)+(px(h)-bounds.Dy())/2)
+		draw.Draw(page, bounds.Add(at), img, image.Point{}, draw.Over)
+	}
+	if page != nil {
+		if err := flush(); err != nil {
+			return nil, err
+		}
+	}
+	return pages, nil
+}
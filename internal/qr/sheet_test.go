@@ -0,0 +1,111 @@
+package qr_test
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/eliaseffects/qr-cli/internal/qr"
+)
+
+// testSheet is a 2 by 2 grid of 50mm labels on a 120mm square page.
+var testSheet = qr.SheetLayout{
+	PageWidth: 120, PageHeight: 120,
+	MarginTop: 10, MarginLeft: 10,
+	Cols: 2, Rows: 2,
+	LabelWidth: 50, LabelHeight: 50,
+	PitchX: 50, PitchY: 50,
+	Padding: 2,
+}
+
+func sheetLabels(n int) []qr.SheetLabel {
+	labels := make([]qr.SheetLabel, n)
+	for i := range labels {
+		opts := qr.DefaultOptions()
+		opts.Caption = fmt.Sprintf("Label %d", i+1)
+		labels[i] = qr.SheetLabel{Data: fmt.Sprintf("SKU-%04d", i+1), Options: opts}
+	}
+	return labels
+}
+
+func TestSheetLayoutValidate(t *testing.T) {
+	if err := testSheet.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	tests := []struct {
+		name   string
+		modify func(*qr.SheetLayout)
+	}{
+		{"no columns", func(l *qr.SheetLayout) { l.Cols = 0 }},
+		{"overlapping labels", func(l *qr.SheetLayout) { l.PitchX = 40 }},
+		{"off the page", func(l *qr.SheetLayout) { l.MarginLeft = 30 }},
+		{"padding too large", func(l *qr.SheetLayout) { l.Padding = 25 }},
+	}
+	for _, tt := range tests {
+		layout := testSheet
+		tt.modify(&layout)
+		if err := layout.Validate(); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+}
+
+func TestSheetPDF(t *testing.T) {
+	pdf, err := qr.SheetPDF(sheetLabels(5), testSheet)
+	if err != nil {
+		t.Fatalf("SheetPDF() error = %v", err)
+	}
+	if !bytes.Contains(pdf, []byte("/Count 2 >>")) {
+		t.Error("five labels on a four-label sheet should take two pages")
+	}
+	streams := pdfStreams(t, pdf)
+	var pages []string
+	for _, s := range streams {
+		if strings.Contains(s, " re\n") {
+			pages = append(pages, s)
+		}
+	}
+	if len(pages) != 2 {
+		t.Fatalf("got %d page streams, want 2", len(pages))
+	}
+	if got := strings.Count(pages[0], " cm\n"); got < 4 {
+		t.Errorf("first page places %d codes, want 4", got)
+	}
+}
+
+func TestSheetPNG(t *testing.T) {
+	const dpi = 150
+	pages, err := qr.SheetPNG(sheetLabels(5), testSheet, dpi)
+	if err != nil {
+		t.Fatalf("SheetPNG() error = %v", err)
+	}
+	if len(pages) != 2 {
+		t.Fatalf("got %d pages, want 2", len(pages))
+	}
+
+	px := func(mm float64) int { return int(mm / 25.4 * dpi) }
+	img := decodeImage(t, pages[0])
+	if want := int(math.Round(120 / 25.4 * dpi)); img.Bounds().Dx() != want || img.Bounds().Dy() != want {
+		t.Fatalf("page is %v, want %d px square", img.Bounds().Size(), want)
+	}
+
+	// Each label on the first page decodes as its own data, in row order.
+	sub := img.(interface {
+		SubImage(image.Rectangle) image.Image
+	})
+	for i := 0; i < 4; i++ {
+		x := 10 + float64(i%2)*50
+		y := 10 + float64(i/2)*50
+		label := sub.SubImage(image.Rect(px(x), px(y), px(x+50), px(y+50)))
+		results, err := qr.DecodeImage(label)
+		if err != nil {
+			t.Fatalf("label %d: DecodeImage() error = %v", i+1, err)
+		}
+		if want := fmt.Sprintf("SKU-%04d", i+1); results[0] != want {
+			t.Errorf("label %d decoded %q, want %q", i+1, results[0], want)
+		}
+	}
+}
@@ -0,0 +1,344 @@
+package qr
+
+import (
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/vector"
+)
+
+// ModuleStyle selects the shape drawn for each dark module.
+type ModuleStyle int
+
+const (
+	// StyleSquare draws every module as a full square.
+	StyleSquare ModuleStyle = iota
+	// StyleDot draws every module as a circle.
+	StyleDot
+	// StyleRounded draws every module as a square with rounded corners.
+	StyleRounded
+	// StyleVerticalBars merges vertical runs of modules into bars with
+	// round ends.
+	StyleVerticalBars
+	// StyleHorizontalBars merges horizontal runs of modules into bars with
+	// round ends.
+	StyleHorizontalBars
+	// StyleConnected rounds only the outer corners of each group of
+	// touching modules, so neighbours flow into one blob.
+	StyleConnected
+)
+
+// roundedCornerRadius is the corner radius of StyleRounded, in modules.
+const roundedCornerRadius = 0.3
+
+func (s ModuleStyle) String() string {
+	switch s {
+	case StyleDot:
+		return "dot"
+	case StyleRounded:
+		return "rounded"
+	case StyleVerticalBars:
+		return "vertical-bars"
+	case StyleHorizontalBars:
+		return "horizontal-bars"
+	case StyleConnected:
+		return "connected"
+	default:
+		return "square"
+	}
+}
+
+// EyeShape selects the shape of a finder pattern's outer frame or inner
+// pupil.
+type EyeShape int
+
+const (
+	// EyeSquare draws the standard square frame or pupil.
+	EyeSquare EyeShape = iota
+	// EyeRounded rounds the corners.
+	EyeRounded
+	// EyeCircle draws a ring or a disc.
+	EyeCircle
+)
+
+func (s EyeShape) String() string {
+	switch s {
+	case EyeRounded:
+		return "rounded"
+	case EyeCircle:
+		return "circle"
+	default:
+		return "square"
+	}
+}
+
+// frameRadius and pupilRadius return the corner radius of the 7-module
+// frame and the 3-module pupil. The hole in the frame uses one module less
+// so the ring keeps an even width.
+func (s EyeShape) frameRadius() float64 {
+	switch s {
+	case EyeRounded:
+		return 2
+	case EyeCircle:
+		return 3.5
+	}
+	return 0
+}
+
+func (s EyeShape) pupilRadius() float64 {
+	switch s {
+	case EyeRounded:
+		return 0.75
+	case EyeCircle:
+		return 1.5
+	}
+	return 0
+}
+
+// roundedRect is a rectangle in module units whose corners are rounded by
+// r, in the order top-left, top-right, bottom-right, bottom-left. Every
+// module style is built from these, so each renderer only needs to draw
+// one kind of shape. A hole is traced counter-clockwise, which cuts it out
+// of a clockwise shape under the nonzero fill rule.
+type roundedRect struct {
+	x, y, w, h float64
+	r          [4]float64
+	hole       bool
+}
+
+// shapeLayer is a set of shapes filled with one colour, or with the
+// foreground gradient when gradient is set.
+type shapeLayer struct {
+	fill     color.Color
+	gradient bool
+	shapes   []roundedRect
+}
+
+// shaped reports whether opts asks for anything besides plain square
+// modules in a single colour, which the renderers draw as filled paths.
+func (o Options) shaped() bool {
+	return o.ModuleStyle != StyleSquare || o.EyeFrame != EyeSquare || o.EyePupil != EyeSquare ||
+		o.EyeFrameColor != nil || o.EyePupilColor != nil
+}
+
+// shapeLayers splits a bordered bitmap into the data modules, drawn in
+// the module style and foreground colour, and the finder patterns, whose
+// frames and pupils take the eye shapes and colours.
+func shapeLayers(bitmap [][]bool, opts Options) []shapeLayer {
+	border := max(opts.BorderSize, 0)
+	width, height := len(bitmap[0])-border*2, len(bitmap)-border*2
+
+	// Micro QR and rMQR symbols carry a single finder pattern in the
+	// top-left corner; rMQR's bottom-right sub-pattern is left alone.
+	origins := [][2]int{{0, 0}}
+	if opts.Symbology == SymbolQR {
+		origins = append(origins, [2]int{width - 7, 0}, [2]int{0, height - 7})
+	}
+
+	modules := make([][]bool, len(bitmap))
+	for y, row := range bitmap {
+		modules[y] = append([]bool(nil), row...)
+	}
+	var frames, pupils []roundedRect
+	for _, o := range origins {
+		x, y := o[0]+border, o[1]+border
+		for dy := 0; dy < 7; dy++ {
+			for dx := 0; dx < 7; dx++ {
+				modules[y+dy][x+dx] = false
+			}
+		}
+
+		outer, pupil := opts.EyeFrame.frameRadius(), opts.EyePupil.pupilRadius()
+		inner := max(outer-1, 0)
+		fx, fy := float64(x), float64(y)
+		frames = append(frames,
+			roundedRect{x: fx, y: fy, w: 7, h: 7, r: [4]float64{outer, outer, outer, outer}},
+			roundedRect{x: fx + 1, y: fy + 1, w: 5, h: 5, r: [4]float64{inner, inner, inner, inner}, hole: true},
+		)
+		pupils = append(pupils, roundedRect{x: fx + 2, y: fy + 2, w: 3, h: 3, r: [4]float64{pupil, pupil, pupil, pupil}})
+	}
+
+	gradient := opts.ForegroundGradient != nil
+	frame := shapeLayer{fill: opts.ForegroundColor, gradient: gradient, shapes: frames}
+	if opts.EyeFrameColor != nil {
+		frame.fill, frame.gradient = opts.EyeFrameColor, false
+	}
+	pupil := shapeLayer{fill: frame.fill, gradient: frame.gradient, shapes: pupils}
+	if opts.EyePupilColor != nil {
+		pupil.fill, pupil.gradient = opts.EyePupilColor, false
+	}
+	return []shapeLayer{
+		{fill: opts.ForegroundColor, gradient: gradient, shapes: moduleShapes(modules, opts.ModuleStyle)},
+		frame,
+		pupil,
+	}
+}
+
+// curved reports whether any shape in the layers has a rounded corner.
+func curved(layers []shapeLayer) bool {
+	for _, layer := range layers {
+		for _, shape := range layer.shapes {
+			if shape.r != [4]float64{} {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// moduleShapes returns the shapes that draw the dark modules of bitmap in
+// the given style.
+func moduleShapes(bitmap [][]bool, style ModuleStyle) []roundedRect {
+	dark := func(x, y int) bool {
+		return y >= 0 && y < len(bitmap) && x >= 0 && x < len(bitmap[y]) && bitmap[y][x]
+	}
+
+	var shapes []roundedRect
+	switch style {
+	case StyleVerticalBars, StyleHorizontalBars:
+		vertical := style == StyleVerticalBars
+		outer, inner := len(bitmap[0]), len(bitmap)
+		if !vertical {
+			outer, inner = inner, outer
+		}
+		at := func(i, j int) bool {
+			if vertical {
+				return dark(i, j)
+			}
+			return dark(j, i)
+		}
+		for i := 0; i < outer; i++ {
+			for j := 0; j < inner; j++ {
+				if !at(i, j) {
+					continue
+				}
+				start := j
+				for j < inner && at(i, j) {
+					j++
+				}
+				bar := roundedRect{x: float64(i), y: float64(start), w: 1, h: float64(j - start), r: [4]float64{0.5, 0.5, 0.5, 0.5}}
+				if !vertical {
+					bar.x, bar.y, bar.w, bar.h = bar.y, bar.x, bar.h, bar.w
+				}
+				shapes = append(shapes, bar)
+			}
+		}
+		return shapes
+	}
+
+	for y, row := range bitmap {
+		for x, on := range row {
+			if !on {
+				continue
+			}
+			shape := roundedRect{x: float64(x), y: float64(y), w: 1, h: 1}
+			switch style {
+			case StyleDot:
+				shape.r = [4]float64{0.5, 0.5, 0.5, 0.5}
+			case StyleRounded:
+				shape.r = [4]float64{roundedCornerRadius, roundedCornerRadius, roundedCornerRadius, roundedCornerRadius}
+			case StyleConnected:
+				up, down := dark(x, y-1), dark(x, y+1)
+				left, right := dark(x-1, y), dark(x+1, y)
+				shape.r = [4]float64{
+					cornerRadius(!up && !left),
+					cornerRadius(!up && !right),
+					cornerRadius(!down && !right),
+					cornerRadius(!down && !left),
+				}
+			}
+			shapes = append(shapes, shape)
+		}
+	}
+	return shapes
+}
+
+func cornerRadius(round bool) float64 {
+	if round {
+		return 0.5
+	}
+	return 0
+}
+
+// svgPath appends the outline of the shape to a path description, using
+// quarter-circle arcs for the rounded corners.
+func (s roundedRect) svgPath(b *strings.Builder) {
+	x0, y0, x1, y1 := s.x, s.y, s.x+s.w, s.y+s.h
+	tl, tr, br, bl := s.r[0], s.r[1], s.r[2], s.r[3]
+
+	b.WriteString("M" + svgNum(x0+tl) + " " + svgNum(y0))
+	if s.hole {
+		svgArc(b, tl, x0, y0+tl, 0)
+		b.WriteString("V" + svgNum(y1-bl))
+		svgArc(b, bl, x0+bl, y1, 0)
+		b.WriteString("H" + svgNum(x1-br))
+		svgArc(b, br, x1, y1-br, 0)
+		b.WriteString("V" + svgNum(y0+tr))
+		svgArc(b, tr, x1-tr, y0, 0)
+		b.WriteString("Z")
+		return
+	}
+	b.WriteString("H" + svgNum(x1-tr))
+	svgArc(b, tr, x1, y0+tr, 1)
+	b.WriteString("V" + svgNum(y1-br))
+	svgArc(b, br, x1-br, y1, 1)
+	b.WriteString("H" + svgNum(x0+bl))
+	svgArc(b, bl, x0, y1-bl, 1)
+	b.WriteString("V" + svgNum(y0+tl))
+	svgArc(b, tl, x0+tl, y0, 1)
+	b.WriteString("Z")
+}
+
+func svgArc(b *strings.Builder, r, x, y float64, sweep int) {
+	if r == 0 {
+		return
+	}
+	b.WriteString("A" + svgNum(r) + " " + svgNum(r) + " 0 0 " + strconv.Itoa(sweep) + " " + svgNum(x) + " " + svgNum(y))
+}
+
+// svgNum formats a coordinate with at most three decimals.
+func svgNum(f float64) string {
+	return strconv.FormatFloat(math.Round(f*1000)/1000, 'f', -1, 64)
+}
+
+// kappa places the control points of a cubic Bézier that approximates a
+// quarter circle.
+const kappa = 0.5522847498
+
+// rasterize adds the outline of the shape to z, scaled by scale pixels per
+// module and offset by (ox, oy) pixels. Corners are approximated with
+// cubic Béziers.
+func (s roundedRect) rasterize(z *vector.Rasterizer, scale, ox, oy float64) {
+	pt := func(x, y float64) (float32, float32) {
+		return float32(ox + x*scale), float32(oy + y*scale)
+	}
+	corner := func(r, cx, cy, fromX, fromY, toX, toY float64) {
+		if r == 0 {
+			z.LineTo(pt(cx, cy))
+			return
+		}
+		z.LineTo(pt(cx+fromX*r, cy+fromY*r))
+		ax, ay := pt(cx+fromX*r*(1-kappa), cy+fromY*r*(1-kappa))
+		bx, by := pt(cx+toX*r*(1-kappa), cy+toY*r*(1-kappa))
+		ex, ey := pt(cx+toX*r, cy+toY*r)
+		z.CubeTo(ax, ay, bx, by, ex, ey)
+	}
+
+	x0, y0, x1, y1 := s.x, s.y, s.x+s.w, s.y+s.h
+	z.MoveTo(pt(x0+s.r[0], y0))
+	if s.hole {
+		corner(s.r[0], x0, y0, 1, 0, 0, 1)
+		corner(s.r[3], x0, y1, 0, -1, 1, 0)
+		corner(s.r[2], x1, y1, -1, 0, 0, -1)
+		corner(s.r[1], x1, y0, 0, 1, -1, 0)
+		z.ClosePath()
+		return
+	}
+	corner(s.r[1], x1, y0, -1, 0, 0, 1)
+	corner(s.r[2], x1, y1, 0, -1, -1, 0)
+	corner(s.r[3], x0, y1, 1, 0, 0, -1)
+	corner(s.r[0], x0, y0, 0, 1, 1, 0)
+	z.ClosePath()
+}
@@ -0,0 +1,103 @@
+package qr_test
+
+import (
+	"encoding/xml"
+	"image/color"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/eliaseffects/qr-cli/internal/qr"
+)
+
+var moduleStyles = []qr.ModuleStyle{
+	qr.StyleSquare,
+	qr.StyleDot,
+	qr.StyleRounded,
+	qr.StyleVerticalBars,
+	qr.StyleHorizontalBars,
+	qr.StyleConnected,
+}
+
+func TestModuleStylesDecode(t *testing.T) {
+	payload := "https://example.com/styled"
+	for _, style := range moduleStyles {
+		for _, size := range []int{256, 512} {
+			opts := qr.DefaultOptions()
+			opts.Size = size
+			opts.ModuleStyle = style
+
+			pngData, err := qr.PNG(payload, opts)
+			if err != nil {
+				t.Fatalf("%s: PNG() error = %v", style, err)
+			}
+			if results := decodePNG(t, pngData); results[0] != payload {
+				t.Fatalf("%s at %dpx: decoded %q, want %q", style, size, results[0], payload)
+			}
+		}
+	}
+}
+
+func TestModuleStylesSVG(t *testing.T) {
+	for _, style := range moduleStyles {
+		opts := qr.DefaultOptions()
+		opts.ModuleStyle = style
+
+		svg, err := qr.SVG("styled", opts)
+		if err != nil {
+			t.Fatalf("%s: SVG() error = %v", style, err)
+		}
+		if err := xml.Unmarshal(svg, new(struct{})); err != nil {
+			t.Fatalf("%s: invalid SVG: %v", style, err)
+		}
+
+		hasArc := regexp.MustCompile(`A\d`).Match(svg)
+		if hasArc != (style != qr.StyleSquare) {
+			t.Errorf("%s: arcs present = %v", style, hasArc)
+		}
+		if style == qr.StyleDot && !strings.Contains(string(svg), "A0.5 0.5 0 0 1") {
+			t.Errorf("dot style emitted no arcs")
+		}
+	}
+}
+
+func TestEyeStylesDecode(t *testing.T) {
+	payload := "https://example.com/eyes"
+	shapes := []qr.EyeShape{qr.EyeSquare, qr.EyeRounded, qr.EyeCircle}
+	for _, frame := range shapes {
+		for _, pupil := range shapes {
+			opts := qr.DefaultOptions()
+			opts.Size = 512
+			opts.ModuleStyle = qr.StyleDot
+			opts.EyeFrame = frame
+			opts.EyePupil = pupil
+			opts.EyeFrameColor = color.RGBA{R: 0x1a, G: 0x4f, B: 0x8b, A: 0xff}
+			opts.EyePupilColor = color.RGBA{R: 0xc0, G: 0x39, B: 0x2b, A: 0xff}
+
+			pngData, err := qr.PNG(payload, opts)
+			if err != nil {
+				t.Fatalf("%s/%s: PNG() error = %v", frame, pupil, err)
+			}
+			if results := decodePNG(t, pngData); results[0] != payload {
+				t.Fatalf("%s/%s: decoded %q, want %q", frame, pupil, results[0], payload)
+			}
+		}
+	}
+}
+
+func TestEyeColorsSVG(t *testing.T) {
+	opts := qr.DefaultOptions()
+	opts.EyeFrame = qr.EyeCircle
+	opts.EyeFrameColor = color.RGBA{R: 0x1a, G: 0x4f, B: 0x8b, A: 0xff}
+	opts.EyePupilColor = color.RGBA{R: 0xc0, G: 0x39, B: 0x2b, A: 0xff}
+
+	svg, err := qr.SVG("eyes", opts)
+	if err != nil {
+		t.Fatalf("SVG() error = %v", err)
+	}
+	for _, want := range []string{`<path fill="#000000"`, `<path fill="#1a4f8b"`, `<path fill="#c0392b"`, "A3.5 3.5 0 0 1", "A2.5 2.5 0 0 0"} {
+		if !strings.Contains(string(svg), want) {
+			t.Errorf("SVG missing %s", want)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package qr_test
+
+import (
+	"encoding/xml"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/eliaseffects/qr-cli/internal/qr"
+)
+
+// svgModules returns the modules an SVG written with the given geometry
+// paints dark, as "x,y" keys counted by how often each is painted.
+func svgModules(t *testing.T, svg []byte, geometry qr.SVGGeometry) map[string]int {
+	t.Helper()
+
+	painted := map[string]int{}
+	fill := func(x, y, w, h string) {
+		x0, _ := strconv.Atoi(x)
+		y0, _ := strconv.Atoi(y)
+		dx, _ := strconv.Atoi(w)
+		dy, _ := strconv.Atoi(h)
+		for j := y0; j < y0+dy; j++ {
+			for i := x0; i < x0+dx; i++ {
+				painted[strconv.Itoa(i)+","+strconv.Itoa(j)]++
+			}
+		}
+	}
+
+	switch geometry {
+	case qr.SVGRects:
+		for _, m := range regexp.MustCompile(`<rect x="(\d+)" y="(\d+)" width="1" height="1"/>`).FindAllSubmatch(svg, -1) {
+			fill(string(m[1]), string(m[2]), "1", "1")
+		}
+	case qr.SVGUse:
+		for _, m := range regexp.MustCompile(`<use href="#qr-m" x="(\d+)" y="(\d+)"/>`).FindAllSubmatch(svg, -1) {
+			fill(string(m[1]), string(m[2]), "1", "1")
+		}
+	default:
+		d := regexp.MustCompile(`<path [^>]*d="([^"]*)"`).FindSubmatch(svg)
+		if d == nil {
+			t.Fatal("SVG has no path")
+		}
+		sub := regexp.MustCompile(`M(\d+) (\d+)h(\d+)v(\d+)h-(\d+)z`)
+		if rest := sub.ReplaceAllString(string(d[1]), ""); rest != "" {
+			t.Fatalf("unexpected path data %q", rest)
+		}
+		for _, m := range sub.FindAllStringSubmatch(string(d[1]), -1) {
+			if m[3] != m[5] {
+				t.Fatalf("subpath %q does not close", m[0])
+			}
+			fill(m[1], m[2], m[3], m[4])
+		}
+	}
+	return painted
+}
+
+func TestSVGGeometry(t *testing.T) {
+	payload := strings.Repeat("https://example.com/compact-svg ", 30)
+	opts := qr.DefaultOptions()
+	opts.SVGGeometry = qr.SVGRects
+	rects, err := qr.SVG(payload, opts)
+	if err != nil {
+		t.Fatalf("SVG() error = %v", err)
+	}
+	want := svgModules(t, rects, qr.SVGRects)
+
+	for _, geometry := range []qr.SVGGeometry{qr.SVGPath, qr.SVGUse} {
+		opts.SVGGeometry = geometry
+		svg, err := qr.SVG(payload, opts)
+		if err != nil {
+			t.Fatalf("%s: SVG() error = %v", geometry, err)
+		}
+		if err := xml.Unmarshal(svg, new(struct{})); err != nil {
+			t.Fatalf("%s: invalid SVG: %v", geometry, err)
+		}
+
+		// Every module painted by the per-module rects is painted exactly
+		// once, and nothing else.
+		got := svgModules(t, svg, geometry)
+		if len(got) != len(want) {
+			t.Errorf("%s: %d dark modules, want %d", geometry, len(got), len(want))
+		}
+		for module, n := range got {
+			if n != 1 || want[module] != 1 {
+				t.Fatalf("%s: module %s painted %d times, want %d", geometry, module, n, want[module])
+			}
+		}
+
+		if geometry == qr.SVGPath && len(svg)*3 > len(rects) {
+			t.Errorf("path SVG is %d bytes, want under a third of the %d byte rects SVG", len(svg), len(rects))
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package qr
+
+import "fmt"
+
+// Symbology selects the family of symbol to encode.
+type Symbology int
+
+const (
+	// SymbolQR is a regular square QR code, versions 1-40.
+	SymbolQR Symbology = iota
+	// SymbolMicro is a Micro QR code, versions M1-M4, with a single
+	// finder pattern.
+	SymbolMicro
+	// SymbolRMQR is a rectangular Micro QR code, versions R7x43-R17x139.
+	SymbolRMQR
+)
+
+func (s Symbology) String() string {
+	switch s {
+	case SymbolMicro:
+		return "micro"
+	case SymbolRMQR:
+		return "rmqr"
+	default:
+		return "qr"
+	}
+}
+
+// QuietZone returns the minimum quiet zone in modules: four for QR codes
+// and two for both Micro QR variants.
+func (s Symbology) QuietZone() int {
+	if s == SymbolQR {
+		return 4
+	}
+	return 2
+}
+
+// versionName formats a version number the way the symbology labels it,
+// e.g. 7, M3 or R13x77.
+func versionName(s Symbology, version int) string {
+	switch s {
+	case SymbolMicro:
+		return fmt.Sprintf("M%d", version)
+	case SymbolRMQR:
+		if version >= 1 && version <= len(rmqrVersions) {
+			v := rmqrVersions[version-1]
+			return fmt.Sprintf("R%dx%d", v.height, v.width)
+		}
+	}
+	return fmt.Sprint(version)
+}
@@ -0,0 +1,274 @@
+package qr_test
+
+import (
+	"bytes"
+	"errors"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/eliaseffects/qr-cli/internal/qr"
+)
+
+func TestMicroCapacity(t *testing.T) {
+	tests := []struct {
+		name    string
+		version int
+		level   qr.RecoveryLevel
+		mode    qr.Mode
+		char    string
+		max     int
+	}{
+		{"M1 numeric", 1, qr.Low, qr.ModeNumeric, "1", 5},
+		{"M2-L alphanumeric", 2, qr.Low, qr.ModeAlphanumeric, "A", 6},
+		{"M3-M byte", 3, qr.Medium, qr.ModeByte, "a", 7},
+		{"M4-L byte", 4, qr.Low, qr.ModeByte, "a", 15},
+		{"M4-Q numeric", 4, qr.High, qr.ModeNumeric, "1", 21},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := qr.DefaultOptions()
+			opts.Symbology = qr.SymbolMicro
+			opts.Version = tt.version
+			opts.Level = tt.level
+			opts.Mode = tt.mode
+
+			code, err := qr.Generate(strings.Repeat(tt.char, tt.max), opts)
+			if err != nil {
+				t.Fatalf("Generate() error = %v", err)
+			}
+			if want := tt.version*2 + 9; code.Size() != want || code.Height() != want {
+				t.Errorf("symbol is %dx%d, want %dx%d", code.Size(), code.Height(), want, want)
+			}
+			if code.VersionName() != "M"+string(rune('0'+tt.version)) {
+				t.Errorf("VersionName() = %q", code.VersionName())
+			}
+
+			if _, err := qr.Generate(strings.Repeat(tt.char, tt.max+1), opts); !errors.Is(err, qr.ErrDataTooLong) {
+				t.Errorf("expected ErrDataTooLong one character over capacity, got %v", err)
+			}
+		})
+	}
+}
+
+func TestMicroLayout(t *testing.T) {
+	opts := qr.DefaultOptions()
+	opts.Symbology = qr.SymbolMicro
+	opts.BorderSize = 0
+	bitmap, err := qr.Bitmap("HELLO", opts)
+	if err != nil {
+		t.Fatalf("Bitmap() error = %v", err)
+	}
+
+	size := len(bitmap)
+	for i := 0; i < 7; i++ {
+		if !bitmap[0][i] || !bitmap[6][i] || !bitmap[i][0] || !bitmap[i][6] {
+			t.Fatalf("finder pattern ring broken at %d", i)
+		}
+	}
+	for i := 8; i < size; i++ {
+		if bitmap[0][i] != (i%2 == 0) || bitmap[i][0] != (i%2 == 0) {
+			t.Fatalf("timing pattern broken at %d", i)
+		}
+	}
+}
+
+func TestMicroUnsupported(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*qr.Options)
+	}{
+		{"level H", func(o *qr.Options) { o.Level = qr.Highest }},
+		{"M1 at level M", func(o *qr.Options) { o.Version = 1 }},
+		{"version 5", func(o *qr.Options) { o.Version = 5 }},
+		{"mask 4", func(o *qr.Options) { o.Mask = 4 }},
+		{"structured append", func(o *qr.Options) { o.Append = qr.StructuredAppend{Index: 0, Total: 2} }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := qr.DefaultOptions()
+			opts.Symbology = qr.SymbolMicro
+			tt.modify(&opts)
+			if _, err := qr.Generate("123", opts); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func TestRMQRCapacity(t *testing.T) {
+	tests := []struct {
+		name    string
+		version int
+		level   qr.RecoveryLevel
+		size    string
+		max     int
+	}{
+		{"R7x43-M", 1, qr.Medium, "R7x43", 12},
+		{"R7x43-H", 1, qr.Highest, "R7x43", 5},
+		{"R17x139-M", 32, qr.Medium, "R17x139", 361},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := qr.DefaultOptions()
+			opts.Symbology = qr.SymbolRMQR
+			opts.Version = tt.version
+			opts.Level = tt.level
+
+			code, err := qr.Generate(strings.Repeat("7", tt.max), opts)
+			if err != nil {
+				t.Fatalf("Generate() error = %v", err)
+			}
+			if code.VersionName() != tt.size {
+				t.Errorf("VersionName() = %q, want %q", code.VersionName(), tt.size)
+			}
+			if _, err := qr.Generate(strings.Repeat("7", tt.max+1), opts); !errors.Is(err, qr.ErrDataTooLong) {
+				t.Errorf("expected ErrDataTooLong one digit over capacity, got %v", err)
+			}
+		})
+	}
+}
+
+func TestRMQRRendering(t *testing.T) {
+	opts := qr.DefaultOptions()
+	opts.Symbology = qr.SymbolRMQR
+	opts.BorderSize = qr.SymbolRMQR.QuietZone()
+	opts.Size = 600
+
+	code, err := qr.Generate("https://example.com", opts)
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if code.Height() >= code.Size() {
+		t.Fatalf("expected a wide symbol, got %dx%d", code.Size(), code.Height())
+	}
+
+	bitmap, err := qr.Bitmap("https://example.com", opts)
+	if err != nil {
+		t.Fatalf("Bitmap() error = %v", err)
+	}
+	if len(bitmap) != code.Height()+4 || len(bitmap[0]) != code.Size()+4 {
+		t.Fatalf("bordered bitmap is %dx%d", len(bitmap[0]), len(bitmap))
+	}
+
+	pngData, err := qr.PNG("https://example.com", opts)
+	if err != nil {
+		t.Fatalf("PNG() error = %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(pngData))
+	if err != nil {
+		t.Fatalf("png.Decode() error = %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != opts.Size || b.Dy() >= b.Dx() {
+		t.Fatalf("PNG is %dx%d, want %d wide and shorter than it is wide", b.Dx(), b.Dy(), opts.Size)
+	}
+
+	svg, err := qr.SVG("https://example.com", opts)
+	if err != nil {
+		t.Fatalf("SVG() error = %v", err)
+	}
+	if !strings.Contains(string(svg), `width="600"`) || strings.Contains(string(svg), `height="600"`) {
+		t.Fatalf("unexpected SVG dimensions: %.120s", svg)
+	}
+}
+
+func TestRMQRUnsupported(t *testing.T) {
+	for _, level := range []qr.RecoveryLevel{qr.Low, qr.High} {
+		opts := qr.DefaultOptions()
+		opts.Symbology = qr.SymbolRMQR
+		opts.Level = level
+		if _, err := qr.Generate("123", opts); err == nil {
+			t.Errorf("expected error for level %s", level)
+		}
+	}
+}
+
+// symbolRows renders a bitmap as rows of '#' for dark and '.' for light
+// modules.
+func symbolRows(bitmap [][]bool) []string {
+	rows := make([]string, len(bitmap))
+	for y, row := range bitmap {
+		line := make([]byte, len(row))
+		for x, dark := range row {
+			line[x] = '.'
+			if dark {
+				line[x] = '#'
+			}
+		}
+		rows[y] = string(line)
+	}
+	return rows
+}
+
+func compareSymbol(t *testing.T, got [][]bool, want []string) {
+	t.Helper()
+	rows := symbolRows(got)
+	if len(rows) != len(want) {
+		t.Fatalf("symbol has %d rows, want %d:\n%s", len(rows), len(want), strings.Join(rows, "\n"))
+	}
+	for y := range want {
+		if rows[y] != want[y] {
+			t.Fatalf("row %d is %s, want %s; symbol:\n%s", y, rows[y], want[y], strings.Join(rows, "\n"))
+		}
+	}
+}
+
+func TestMicroKnownAnswer(t *testing.T) {
+	// The M2-L example of ISO/IEC 18004 Annex I: data codewords
+	// 40 18 AC C3 00, error correction 86 0D 22 AE 30, and mask 01 picked
+	// by the edge score, which fixes the format information in row and
+	// column 8.
+	opts := qr.DefaultOptions()
+	opts.Symbology = qr.SymbolMicro
+	opts.Level = qr.Low
+
+	code, err := qr.Generate("01234567", opts)
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if code.VersionName() != "M2" || code.Mask != 1 {
+		t.Fatalf("got %s with mask %d, want M2 with mask 1", code.VersionName(), code.Mask)
+	}
+	compareSymbol(t, code.Bitmap(), []string{
+		"#######.#.#.#",
+		"#.....#.###.#",
+		"#.###.#..##.#",
+		"#.###.#..####",
+		"#.###.#.###..",
+		"#.....#.#...#",
+		"#######..####",
+		".........##..",
+		"##.#....#...#",
+		".##.#.#.#.#.#",
+		"###..#######.",
+		"...#.#....##.",
+		"###.#..##.###",
+	})
+}
+
+func TestRMQRKnownAnswer(t *testing.T) {
+	// R7x43 at level M holding 123456: data codewords 2C 3D B9 00 EC 11
+	// and error correction BF 8F E9 F4 9B FE 1D. The level and version
+	// indicator sits in columns 8-11 beside the finder and, under the
+	// other mask, in columns 35-40 beside the finder sub-pattern.
+	opts := qr.DefaultOptions()
+	opts.Symbology = qr.SymbolRMQR
+	opts.Version = 1
+
+	code, err := qr.Generate("123456", opts)
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	compareSymbol(t, code.Bitmap(), []string{
+		"#######.#.#.#.#.#.#.###.#.#.#.#.#.#.#.#.###",
+		"#.....#..#.#.....#..#.##....##..##.##...#.#",
+		"#.###.#.#.###...#######.##...##.#.#########",
+		"#.###.#..##...#..#.##.###..#######....#...#",
+		"#.###.#...#.#..####.###...#...###..#..#.#.#",
+		"#.....#.####...###.##.######..#.#####.#...#",
+		"#######.#.#.#.#.#.#.###.#.#.#.#.#.#.#.#####",
+	})
+}